@@ -0,0 +1,789 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Account represents a registered player. The run history is kept apart from the account, so
+// auth lookups copy no more than these fields.
+type Account struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+	SessionEpoch int       `json:"session_epoch"` // bumped on logout to revoke every outstanding session
+}
+
+// storedAccount is an account as read from accounts.json. Older files also hold the account's run
+// history, which moves to the run log when the file is loaded.
+type storedAccount struct {
+	Account
+	Runs []RunRecord `json:"runs,omitempty"`
+}
+
+// RunRecord is one completed quiz in a player's history
+type RunRecord struct {
+	QuizType string    `json:"quiz_type"`
+	Score    int       `json:"score"`
+	Total    int       `json:"total"`
+	When     time.Time `json:"when"`
+}
+
+// accountRun is one line of the run log: a completed quiz and the player it belongs to
+type accountRun struct {
+	Username string `json:"username"`
+	RunRecord
+}
+
+// AccountManager manages registered accounts with thread-safe access
+type AccountManager struct {
+	mu       sync.Mutex
+	accounts map[string]*Account    // keyed by lower-cased username
+	runs     map[string][]RunRecord // each account's most recent runs, keyed like accounts
+}
+
+// Constants for account configuration
+const (
+	accountsFilename    = "accounts.json"
+	accountRunsFilename = "account_runs.jsonl" // run history, appended to as quizzes are completed
+	sessionCookieName   = "session"
+	sessionLifetime     = 30 * 24 * time.Hour
+	MinPasswordLength   = 8
+	MaxRunsPerAccount   = 1000
+	passwordSaltLength  = 16
+	passwordKeyLength   = 32
+)
+
+// passwordIterations is the PBKDF2-HMAC-SHA256 work factor for new hashes (OWASP 2023 guidance)
+// It is a variable so tests can lower it; existing hashes record their own iteration count.
+var passwordIterations = 600000
+
+// accountManager is the global account store
+var accountManager AccountManager
+
+// usernamePattern restricts usernames to characters that are safe in URLs and session cookies
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,20}$`)
+
+// Account errors reported back to users
+var (
+	errInvalidUsername    = errors.New("Username must be 3-20 letters, digits, '-' or '_'")
+	errPasswordTooShort   = fmt.Errorf("Password must be at least %d characters", MinPasswordLength)
+	errUsernameTaken      = errors.New("That username is already taken")
+	errInvalidCredentials = errors.New("Incorrect username or password")
+	errTooManyLogins      = errors.New("Too many failed logins, please try again later")
+)
+
+// Failed-login limits. Each attempt costs a full PBKDF2 hash, so guesses are capped per client
+// address and per username over a sliding window.
+const (
+	loginFailureWindow      = 15 * time.Minute
+	maxLoginFailuresPerIP   = 20
+	maxLoginFailuresPerUser = 5
+)
+
+// loginLimiter counts recent failed logins per client address and per username
+type loginLimiter struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time // keyed "ip:<addr>" or "user:<lower-cased username>"
+	pruned   time.Time
+}
+
+// loginAttempts is the global failed-login limiter
+var loginAttempts loginLimiter
+
+// hashPassword derives a salted PBKDF2-HMAC-SHA256 hash encoded as pbkdf2-sha256$iterations$salt$key
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, passwordKeyLength)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive key: %w", err)
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s",
+		passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// checkPassword verifies a password against a hash produced by hashPassword
+func checkPassword(encoded, password string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(expected))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, expected) == 1
+}
+
+// loadAccounts loads accounts from the JSON file and their history from the run log; a missing
+// file means no accounts yet
+func loadAccounts() error {
+	accountManager.mu.Lock()
+	defer accountManager.mu.Unlock()
+
+	accountManager.accounts = make(map[string]*Account)
+	accountManager.runs = make(map[string][]RunRecord)
+
+	data, err := os.ReadFile(accountsFilename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return loadAccountRunsLocked(nil)
+		}
+		return fmt.Errorf("failed to read accounts file: %w", err)
+	}
+
+	var accounts []storedAccount
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return fmt.Errorf("failed to parse accounts JSON: %w", err)
+	}
+	embedded := make(map[string][]RunRecord)
+	for _, a := range accounts {
+		account := a.Account
+		key := strings.ToLower(account.Username)
+		accountManager.accounts[key] = &account
+		if len(a.Runs) > 0 {
+			embedded[key] = a.Runs
+		}
+	}
+	if err := loadAccountRunsLocked(embedded); err != nil {
+		return err
+	}
+
+	// Drop the history older files kept in accounts.json now that the run log holds it
+	if len(embedded) > 0 {
+		return saveAccountsLocked()
+	}
+	return nil
+}
+
+// loadAccountRunsLocked reads the run log, keeping each account's most recent MaxRunsPerAccount
+// runs, and rewrites it when older runs were dropped. Without a log, the history embedded in an
+// older accounts.json starts it; once the log exists it is the only history read. A line a crash
+// cut short is skipped. The caller must hold accountManager.mu.
+func loadAccountRunsLocked(embedded map[string][]RunRecord) error {
+	data, err := os.ReadFile(accountRunsFilename)
+	if os.IsNotExist(err) {
+		if len(embedded) == 0 {
+			return nil
+		}
+		for key, runs := range embedded {
+			accountManager.runs[key] = capRuns(runs)
+		}
+		return saveAccountRunsLocked()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read run log: %w", err)
+	}
+
+	trimmed := false
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var run accountRun
+		if err := json.Unmarshal(line, &run); err != nil {
+			log.Printf("Skipping unreadable run log entry: %v", err)
+			continue
+		}
+		key := strings.ToLower(run.Username)
+		runs := append(accountManager.runs[key], run.RunRecord)
+		if len(runs) > MaxRunsPerAccount {
+			trimmed = true
+		}
+		accountManager.runs[key] = capRuns(runs)
+	}
+	if trimmed {
+		return saveAccountRunsLocked()
+	}
+	return nil
+}
+
+// saveAccountRunsLocked rewrites the run log with the history held in memory, replacing it in one
+// step so a crash never leaves it half written. The caller must hold accountManager.mu.
+func saveAccountRunsLocked() error {
+	keys := make([]string, 0, len(accountManager.runs))
+	for key := range accountManager.runs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, key := range keys {
+		username := key
+		if account, ok := accountManager.accounts[key]; ok {
+			username = account.Username
+		}
+		for _, run := range accountManager.runs[key] {
+			data, err := json.Marshal(accountRun{Username: username, RunRecord: run})
+			if err != nil {
+				return fmt.Errorf("failed to marshal run: %w", err)
+			}
+			buf.Write(append(data, '\n'))
+		}
+	}
+
+	// Like accounts.json, the history is per player, so keep the file private
+	tmp := accountRunsFilename + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write run log: %w", err)
+	}
+	if err := os.Rename(tmp, accountRunsFilename); err != nil {
+		return fmt.Errorf("failed to write run log: %w", err)
+	}
+	return nil
+}
+
+// capRuns keeps the most recent MaxRunsPerAccount runs
+func capRuns(runs []RunRecord) []RunRecord {
+	if len(runs) > MaxRunsPerAccount {
+		return runs[len(runs)-MaxRunsPerAccount:]
+	}
+	return runs
+}
+
+// saveAccountsLocked persists all accounts; the caller must hold accountManager.mu
+func saveAccountsLocked() error {
+	accounts := make([]*Account, 0, len(accountManager.accounts))
+	for _, a := range accountManager.accounts {
+		accounts = append(accounts, a)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].CreatedAt.Before(accounts[j].CreatedAt)
+	})
+
+	data, err := json.MarshalIndent(accounts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal accounts: %w", err)
+	}
+	// Accounts hold password hashes, so keep the file private
+	if err := os.WriteFile(accountsFilename, data, 0600); err != nil {
+		return fmt.Errorf("failed to write accounts file: %w", err)
+	}
+	return nil
+}
+
+// createAccount registers a new account after validating the username and password
+func createAccount(username, password string) (*Account, error) {
+	username = strings.TrimSpace(username)
+	if !usernamePattern.MatchString(username) {
+		return nil, errInvalidUsername
+	}
+	if len(password) < MinPasswordLength {
+		return nil, errPasswordTooShort
+	}
+
+	// Hash outside the lock; PBKDF2 is deliberately slow
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	accountManager.mu.Lock()
+	defer accountManager.mu.Unlock()
+
+	if accountManager.accounts == nil {
+		accountManager.accounts = make(map[string]*Account)
+	}
+	key := strings.ToLower(username)
+	if _, exists := accountManager.accounts[key]; exists {
+		return nil, errUsernameTaken
+	}
+
+	account := &Account{
+		Username:     username,
+		PasswordHash: hash,
+		CreatedAt:    time.Now(),
+	}
+	accountManager.accounts[key] = account
+	if err := saveAccountsLocked(); err != nil {
+		delete(accountManager.accounts, key)
+		return nil, err
+	}
+
+	copied := *account
+	return &copied, nil
+}
+
+// authenticate checks a username and password and returns a copy of the account
+func authenticate(username, password string) (*Account, error) {
+	account, ok := getAccount(username)
+	if !ok {
+		// Burn comparable time so response timing doesn't reveal which usernames exist
+		checkPassword(dummyPasswordHash(), password)
+		return nil, errInvalidCredentials
+	}
+	if !checkPassword(account.PasswordHash, password) {
+		return nil, errInvalidCredentials
+	}
+	return account, nil
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// dummyPasswordHash returns a throwaway hash used to equalize login timing
+func dummyPasswordHash() string {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = hashPassword(randomToken(16))
+	})
+	return dummyHash
+}
+
+// getAccount returns a copy of the named account
+func getAccount(username string) (*Account, bool) {
+	accountManager.mu.Lock()
+	defer accountManager.mu.Unlock()
+
+	account, ok := accountManager.accounts[strings.ToLower(strings.TrimSpace(username))]
+	if !ok {
+		return nil, false
+	}
+	copied := *account
+	return &copied, true
+}
+
+// accountRuns returns a copy of the named account's run history, oldest first
+func accountRuns(username string) []RunRecord {
+	accountManager.mu.Lock()
+	defer accountManager.mu.Unlock()
+
+	return append([]RunRecord{}, accountManager.runs[strings.ToLower(username)]...)
+}
+
+// accountExists reports whether a username (case-insensitively) belongs to a registered account
+func accountExists(username string) bool {
+	_, ok := getAccount(username)
+	return ok
+}
+
+// recordRun appends a completed quiz to a player's history, keeping the most recent MaxRunsPerAccount
+// runs. The run is appended to the run log as a line of JSON; accounts.json is not rewritten.
+func recordRun(username string, run RunRecord) error {
+	accountManager.mu.Lock()
+	defer accountManager.mu.Unlock()
+
+	key := strings.ToLower(username)
+	account, ok := accountManager.accounts[key]
+	if !ok {
+		return fmt.Errorf("unknown account %q", username)
+	}
+
+	data, err := json.Marshal(accountRun{Username: account.Username, RunRecord: run})
+	if err != nil {
+		return fmt.Errorf("failed to marshal run: %w", err)
+	}
+	file, err := os.OpenFile(accountRunsFilename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open run log: %w", err)
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write run log: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write run log: %w", err)
+	}
+
+	if accountManager.runs == nil {
+		accountManager.runs = make(map[string][]RunRecord)
+	}
+	accountManager.runs[key] = capRuns(append(accountManager.runs[key], run))
+	return nil
+}
+
+// revokeSessions invalidates every session cookie issued for the account
+func revokeSessions(username string) error {
+	accountManager.mu.Lock()
+	defer accountManager.mu.Unlock()
+
+	account, ok := accountManager.accounts[strings.ToLower(username)]
+	if !ok {
+		return nil
+	}
+	account.SessionEpoch++
+	return saveAccountsLocked()
+}
+
+// signSession computes the session MAC over the username, expiry and the account's session epoch
+func signSession(username string, expires int64, epoch int) string {
+	mac := hmac.New(sha256.New, keys.session)
+	fmt.Fprintf(mac, "session|%s|%d|%d", strings.ToLower(username), expires, epoch)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// setSessionCookie logs the account in on this browser
+func setSessionCookie(w http.ResponseWriter, account *Account, now time.Time) {
+	expires := now.Add(sessionLifetime)
+	value := fmt.Sprintf("%s.%d.%s", account.Username, expires.Unix(), signSession(account.Username, expires.Unix(), account.SessionEpoch))
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearSessionCookie logs this browser out
+func clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// currentAccount returns the logged-in account for the request, if its session cookie is valid
+func currentAccount(r *http.Request) (*Account, bool) {
+	parts := strings.Split(cookieValue(r, sessionCookieName), ".")
+	if len(parts) != 3 {
+		return nil, false
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return nil, false
+	}
+
+	account, ok := getAccount(parts[0])
+	if !ok {
+		return nil, false
+	}
+	expected := signSession(account.Username, expires, account.SessionEpoch)
+	if !hmac.Equal([]byte(parts[2]), []byte(expected)) {
+		return nil, false
+	}
+	return account, true
+}
+
+// AccountPageData represents the data passed to the account.html template
+type AccountPageData struct {
+	Mode     string // "login" or "signup"
+	Username string
+	Next     string
+	Error    string
+}
+
+// renderAccountPage renders the login or signup form
+func renderAccountPage(w http.ResponseWriter, status int, data AccountPageData) {
+	// Account pages are not translated yet, so they render in the default language
+	pageTemplates.render(w, status, "account", defaultLanguage, data)
+}
+
+// safeNext only allows local redirect targets after login
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/profile"
+	}
+	return next
+}
+
+// signupHandler handles GET and POST requests to /signup
+func signupHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		renderAccountPage(w, http.StatusOK, AccountPageData{Mode: "signup", Next: safeNext(r.URL.Query().Get("next"))})
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		username := r.FormValue("username")
+		next := safeNext(r.FormValue("next"))
+		if r.FormValue("password") != r.FormValue("confirm") {
+			renderAccountPage(w, http.StatusBadRequest, AccountPageData{Mode: "signup", Username: username, Next: next, Error: "Passwords do not match"})
+			return
+		}
+
+		account, err := createAccount(username, r.FormValue("password"))
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, errUsernameTaken) {
+				status = http.StatusConflict
+			} else if !errors.Is(err, errInvalidUsername) && !errors.Is(err, errPasswordTooShort) {
+				log.Printf("Error creating account: %v", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			renderAccountPage(w, status, AccountPageData{Mode: "signup", Username: username, Next: next, Error: err.Error()})
+			return
+		}
+
+		setSessionCookie(w, account, time.Now())
+		http.Redirect(w, r, next, http.StatusSeeOther)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// begin claims a login attempt for the client and username, counting it as a failure until
+// succeeded is called. It reports false, without claiming, once either has failed too often
+// within loginFailureWindow; claiming before the password is checked keeps concurrent guesses
+// from slipping past the limit.
+func (l *loginLimiter) begin(ip, username string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.failures == nil {
+		l.failures = make(map[string][]time.Time)
+	}
+	if now.Sub(l.pruned) > loginFailureWindow {
+		for key := range l.failures {
+			l.recentLocked(key, now)
+		}
+		l.pruned = now
+	}
+
+	ipKey, userKey := "ip:"+ip, "user:"+strings.ToLower(strings.TrimSpace(username))
+	if len(l.recentLocked(ipKey, now)) >= maxLoginFailuresPerIP || len(l.recentLocked(userKey, now)) >= maxLoginFailuresPerUser {
+		return false
+	}
+	l.failures[ipKey] = append(l.failures[ipKey], now)
+	l.failures[userKey] = append(l.failures[userKey], now)
+	return true
+}
+
+// succeeded withdraws the attempt claimed by begin and clears the username's failures
+func (l *loginLimiter) succeeded(ip, username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ipKey := "ip:" + ip
+	if failures := l.failures[ipKey]; len(failures) > 0 {
+		l.failures[ipKey] = failures[:len(failures)-1]
+	}
+	delete(l.failures, "user:"+strings.ToLower(strings.TrimSpace(username)))
+}
+
+// recentLocked drops a key's failures older than loginFailureWindow and returns the rest.
+// The caller must hold l.mu.
+func (l *loginLimiter) recentLocked(key string, now time.Time) []time.Time {
+	failures := l.failures[key]
+	i := 0
+	for i < len(failures) && now.Sub(failures[i]) >= loginFailureWindow {
+		i++
+	}
+	if i == len(failures) {
+		delete(l.failures, key)
+		return nil
+	}
+	l.failures[key] = failures[i:]
+	return failures[i:]
+}
+
+// loginHandler handles GET and POST requests to /login
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		renderAccountPage(w, http.StatusOK, AccountPageData{Mode: "login", Next: safeNext(r.URL.Query().Get("next"))})
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		username := r.FormValue("username")
+		next := safeNext(r.FormValue("next"))
+		ip := clientIP(r)
+		if !loginAttempts.begin(ip, username, time.Now()) {
+			renderAccountPage(w, http.StatusTooManyRequests, AccountPageData{Mode: "login", Username: username, Next: next, Error: errTooManyLogins.Error()})
+			return
+		}
+		account, err := authenticate(username, r.FormValue("password"))
+		if err != nil {
+			renderAccountPage(w, http.StatusUnauthorized, AccountPageData{Mode: "login", Username: username, Next: next, Error: err.Error()})
+			return
+		}
+		loginAttempts.succeeded(ip, username)
+
+		setSessionCookie(w, account, time.Now())
+		http.Redirect(w, r, next, http.StatusSeeOther)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// logoutHandler handles POST requests to /logout, revoking all of the account's sessions
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if account, ok := currentAccount(r); ok {
+		if err := revokeSessions(account.Username); err != nil {
+			log.Printf("Error revoking sessions for %s: %v", account.Username, err)
+		}
+	}
+	clearSessionCookie(w)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// RunView is a run formatted for the profile page
+type RunView struct {
+	Date       string
+	Score      int
+	Total      int
+	Percentage float64
+}
+
+// QuizTypeStats summarizes a player's history for one quiz type
+type QuizTypeStats struct {
+	QuizType        string
+	Runs            []RunView // newest first
+	BestScore       int
+	BestTotal       int
+	BestPercentage  float64
+	AverageAccuracy float64
+	RecentAccuracy  float64 // average of the last profileTrendWindow runs
+	TrendPoints     string  // SVG polyline points for the accuracy sparkline, oldest to newest
+}
+
+// ProfilePageData represents the data passed to the profile.html template
+type ProfilePageData struct {
+	Username  string
+	CreatedAt string
+	TotalRuns int
+	Stats     []QuizTypeStats
+}
+
+// Sparkline geometry for the accuracy trend
+const (
+	profileTrendWindow = 10
+	sparklineWidth     = 200
+	sparklineHeight    = 40
+)
+
+// runPercentage returns a run's accuracy as a percentage
+func runPercentage(run RunRecord) float64 {
+	if run.Total == 0 {
+		return 0
+	}
+	return float64(run.Score) / float64(run.Total) * 100.0
+}
+
+// buildProfileStats groups runs by quiz type and computes best scores and accuracy trends
+func buildProfileStats(runs []RunRecord) []QuizTypeStats {
+	byType := make(map[string][]RunRecord)
+	for _, run := range runs {
+		byType[run.QuizType] = append(byType[run.QuizType], run)
+	}
+
+	stats := make([]QuizTypeStats, 0, len(byType))
+	for quizType, typeRuns := range byType {
+		sort.SliceStable(typeRuns, func(i, j int) bool { return typeRuns[i].When.Before(typeRuns[j].When) })
+
+		s := QuizTypeStats{QuizType: quizType, BestPercentage: -1}
+		var sum float64
+		for _, run := range typeRuns {
+			pct := runPercentage(run)
+			sum += pct
+			if pct > s.BestPercentage || (pct == s.BestPercentage && run.Score > s.BestScore) {
+				s.BestPercentage = pct
+				s.BestScore = run.Score
+				s.BestTotal = run.Total
+			}
+		}
+		s.AverageAccuracy = sum / float64(len(typeRuns))
+
+		// Sparkline and recent accuracy over the most recent runs
+		recent := typeRuns
+		if len(recent) > profileTrendWindow {
+			recent = recent[len(recent)-profileTrendWindow:]
+		}
+		var recentSum float64
+		points := make([]string, len(recent))
+		for i, run := range recent {
+			pct := runPercentage(run)
+			recentSum += pct
+			x := 0.0
+			if len(recent) > 1 {
+				x = float64(i) * sparklineWidth / float64(len(recent)-1)
+			}
+			y := sparklineHeight - pct/100.0*sparklineHeight
+			points[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+		}
+		s.RecentAccuracy = recentSum / float64(len(recent))
+		s.TrendPoints = strings.Join(points, " ")
+
+		for i := len(typeRuns) - 1; i >= 0; i-- {
+			run := typeRuns[i]
+			s.Runs = append(s.Runs, RunView{
+				Date:       run.When.Format("Jan 02, 2006 15:04"),
+				Score:      run.Score,
+				Total:      run.Total,
+				Percentage: runPercentage(run),
+			})
+		}
+		stats = append(stats, s)
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].QuizType < stats[j].QuizType })
+	return stats
+}
+
+// profileHandler handles GET requests to /profile for the logged-in player
+func profileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	account, ok := currentAccount(r)
+	if !ok {
+		http.Redirect(w, r, "/login?next=/profile", http.StatusSeeOther)
+		return
+	}
+
+	runs := accountRuns(account.Username)
+	data := ProfilePageData{
+		Username:  account.Username,
+		CreatedAt: account.CreatedAt.Format("Jan 02, 2006"),
+		TotalRuns: len(runs),
+		Stats:     buildProfileStats(runs),
+	}
+
+	renderPage(w, "profile", defaultLanguage, data)
+}
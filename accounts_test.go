@@ -0,0 +1,517 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupAccountsTest runs the test in a temp directory with fresh account and leaderboard state
+// and a cheap password work factor
+func setupAccountsTest(t *testing.T) {
+	t.Helper()
+	originalWd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	originalIterations := passwordIterations
+	passwordIterations = 1000
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() {
+		os.Chdir(originalWd)
+		passwordIterations = originalIterations
+		log.SetOutput(os.Stderr)
+	})
+
+	accountManager = AccountManager{accounts: make(map[string]*Account)}
+	loginAttempts = loginLimiter{}
+	leaderboardManager = LeaderboardManager{entries: []LeaderboardEntry{}}
+}
+
+// loginCookie signs up a user and returns their session cookie
+func loginCookie(t *testing.T, username, password string) *http.Cookie {
+	t.Helper()
+	account, err := createAccount(username, password)
+	if err != nil {
+		t.Fatalf("createAccount(%q) failed: %v", username, err)
+	}
+	w := httptest.NewRecorder()
+	setSessionCookie(w, account, time.Now())
+	return w.Result().Cookies()[0]
+}
+
+func TestPasswordHashing(t *testing.T) {
+	setupAccountsTest(t)
+
+	hash, err := hashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("hashPassword failed: %v", err)
+	}
+	if !strings.HasPrefix(hash, "pbkdf2-sha256$1000$") {
+		t.Errorf("unexpected hash format: %s", hash)
+	}
+	if strings.Contains(hash, "correct horse") {
+		t.Error("hash contains the plaintext password")
+	}
+
+	other, _ := hashPassword("correct horse")
+	if other == hash {
+		t.Error("expected different salts to produce different hashes")
+	}
+
+	tests := []struct {
+		name     string
+		encoded  string
+		password string
+		want     bool
+	}{
+		{"correct password", hash, "correct horse", true},
+		{"wrong password", hash, "battery staple", false},
+		{"empty password", hash, "", false},
+		{"malformed hash", "not-a-hash", "correct horse", false},
+		{"unknown scheme", strings.Replace(hash, "pbkdf2-sha256", "md5", 1), "correct horse", false},
+		{"bad iterations", "pbkdf2-sha256$x$c2FsdA$a2V5", "correct horse", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkPassword(tt.encoded, tt.password); got != tt.want {
+				t.Errorf("checkPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateAccount_Validation(t *testing.T) {
+	setupAccountsTest(t)
+
+	if _, err := createAccount("Stargazer", "password1"); err != nil {
+		t.Fatalf("createAccount failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		wantErr  error
+	}{
+		{"too short username", "ab", "password1", errInvalidUsername},
+		{"too long username", strings.Repeat("a", 21), "password1", errInvalidUsername},
+		{"invalid characters", "star gazer", "password1", errInvalidUsername},
+		{"short password", "newplayer", "short", errPasswordTooShort},
+		{"duplicate username", "Stargazer", "password1", errUsernameTaken},
+		{"duplicate ignoring case", "STARGAZER", "password1", errUsernameTaken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := createAccount(tt.username, tt.password); err != tt.wantErr {
+				t.Errorf("createAccount() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAccounts_Persistence(t *testing.T) {
+	setupAccountsTest(t)
+
+	if _, err := createAccount("Stargazer", "password1"); err != nil {
+		t.Fatalf("createAccount failed: %v", err)
+	}
+	if err := recordRun("stargazer", RunRecord{QuizType: "tarot", Score: 3, Total: 5, When: time.Now()}); err != nil {
+		t.Fatalf("recordRun failed: %v", err)
+	}
+
+	info, err := os.Stat(accountsFilename)
+	if err != nil {
+		t.Fatalf("accounts file not written: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("accounts file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	accountManager = AccountManager{}
+	if err := loadAccounts(); err != nil {
+		t.Fatalf("loadAccounts failed: %v", err)
+	}
+
+	account, err := authenticate("STARGAZER", "password1")
+	if err != nil {
+		t.Fatalf("authenticate after reload failed: %v", err)
+	}
+	if account.Username != "Stargazer" {
+		t.Errorf("Username = %q, want %q", account.Username, "Stargazer")
+	}
+	if runs := accountRuns("Stargazer"); len(runs) != 1 || runs[0].Score != 3 {
+		t.Errorf("runs = %+v, want one tarot run scoring 3", runs)
+	}
+
+	if _, err := authenticate("Stargazer", "wrong-password"); err != errInvalidCredentials {
+		t.Errorf("wrong password error = %v, want %v", err, errInvalidCredentials)
+	}
+	if _, err := authenticate("nobody", "password1"); err != errInvalidCredentials {
+		t.Errorf("unknown user error = %v, want %v", err, errInvalidCredentials)
+	}
+}
+
+func TestRecordRun_CapsHistory(t *testing.T) {
+	setupAccountsTest(t)
+
+	if _, err := createAccount("Stargazer", "password1"); err != nil {
+		t.Fatalf("createAccount failed: %v", err)
+	}
+	for i := 0; i < MaxRunsPerAccount; i++ {
+		if err := recordRun("Stargazer", RunRecord{QuizType: "astrology", Score: 1, Total: 5}); err != nil {
+			t.Fatalf("recordRun failed: %v", err)
+		}
+	}
+	if err := recordRun("Stargazer", RunRecord{QuizType: "astrology", Score: 5, Total: 5}); err != nil {
+		t.Fatalf("recordRun failed: %v", err)
+	}
+
+	runs := accountRuns("Stargazer")
+	if len(runs) != MaxRunsPerAccount {
+		t.Errorf("len(runs) = %d, want %d", len(runs), MaxRunsPerAccount)
+	}
+	if last := runs[len(runs)-1]; last.Score != 5 {
+		t.Errorf("newest run not kept: %+v", last)
+	}
+
+	// The log is trimmed to the kept runs when it is next loaded
+	accountManager = AccountManager{}
+	if err := loadAccounts(); err != nil {
+		t.Fatalf("loadAccounts failed: %v", err)
+	}
+	if runs := accountRuns("Stargazer"); len(runs) != MaxRunsPerAccount || runs[len(runs)-1].Score != 5 {
+		t.Errorf("after reload: %d runs, want %d ending with the newest", len(runs), MaxRunsPerAccount)
+	}
+	data, _ := os.ReadFile(accountRunsFilename)
+	if lines := strings.Count(string(data), "\n"); lines != MaxRunsPerAccount {
+		t.Errorf("run log has %d lines after reload, want %d", lines, MaxRunsPerAccount)
+	}
+}
+
+// TestRecordRun_KeepsAccountsFile tests that a run is appended to the run log without rewriting accounts.json
+func TestRecordRun_KeepsAccountsFile(t *testing.T) {
+	setupAccountsTest(t)
+
+	if _, err := createAccount("Stargazer", "password1"); err != nil {
+		t.Fatalf("createAccount failed: %v", err)
+	}
+	before, _ := os.ReadFile(accountsFilename)
+	if err := recordRun("Stargazer", RunRecord{QuizType: "tarot", Score: 2, Total: 3}); err != nil {
+		t.Fatalf("recordRun failed: %v", err)
+	}
+	if after, _ := os.ReadFile(accountsFilename); string(after) != string(before) {
+		t.Error("recordRun should not rewrite accounts.json")
+	}
+	if info, err := os.Stat(accountRunsFilename); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("run log = %v %v, want a private file", info, err)
+	}
+}
+
+// TestLoadAccounts_EmbeddedRuns tests that history stored in an older accounts.json moves to the run log
+func TestLoadAccounts_EmbeddedRuns(t *testing.T) {
+	setupAccountsTest(t)
+
+	old := `[{"username": "Stargazer", "password_hash": "x", "created_at": "2026-01-01T00:00:00Z", "session_epoch": 0,
+		"runs": [{"quiz_type": "tarot", "score": 3, "total": 5, "when": "2026-01-02T00:00:00Z"}]}]`
+	os.WriteFile(accountsFilename, []byte(old), 0600)
+
+	if err := loadAccounts(); err != nil {
+		t.Fatalf("loadAccounts failed: %v", err)
+	}
+	if runs := accountRuns("stargazer"); len(runs) != 1 || runs[0].Score != 3 {
+		t.Errorf("runs = %+v, want the embedded tarot run", runs)
+	}
+	if data, _ := os.ReadFile(accountsFilename); strings.Contains(string(data), `"runs"`) {
+		t.Errorf("accounts.json still holds the history: %s", data)
+	}
+
+	// Reloading reads the history from the log alone
+	accountManager = AccountManager{}
+	if err := loadAccounts(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if runs := accountRuns("stargazer"); len(runs) != 1 {
+		t.Errorf("runs after reload = %+v, want the one moved run", runs)
+	}
+}
+
+func TestCurrentAccount_Sessions(t *testing.T) {
+	setupAccountsTest(t)
+
+	cookie := loginCookie(t, "Stargazer", "password1")
+	if !cookie.HttpOnly {
+		t.Error("session cookie should be HttpOnly")
+	}
+
+	requestWith := func(value string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: value})
+		return req
+	}
+
+	if account, ok := currentAccount(requestWith(cookie.Value)); !ok || account.Username != "Stargazer" {
+		t.Fatalf("valid session not accepted: %v %v", account, ok)
+	}
+
+	parts := strings.Split(cookie.Value, ".")
+	expired := time.Now().Add(-time.Hour).Unix()
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"empty", ""},
+		{"garbage", "not-a-session"},
+		{"forged username", "Other." + parts[1] + "." + parts[2]},
+		{"extended expiry", parts[0] + ".99999999999." + parts[2]},
+		{"expired", fmt.Sprintf("Stargazer.%d.%s", expired, signSession("Stargazer", expired, 0))},
+		{"bad signature", parts[0] + "." + parts[1] + "." + strings.Repeat("0", 64)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := currentAccount(requestWith(tt.value)); ok {
+				t.Errorf("session %q should be rejected", tt.value)
+			}
+		})
+	}
+
+	// Logging out revokes every outstanding session
+	req := requestWith(cookie.Value)
+	req.Method = http.MethodPost
+	w := httptest.NewRecorder()
+	logoutHandler(w, req)
+	if w.Code != http.StatusSeeOther {
+		t.Errorf("logout status = %d, want %d", w.Code, http.StatusSeeOther)
+	}
+	if _, ok := currentAccount(requestWith(cookie.Value)); ok {
+		t.Error("session still valid after logout")
+	}
+}
+
+func TestSignupAndLoginHandlers(t *testing.T) {
+	setupAccountsTest(t)
+
+	post := func(handler http.HandlerFunc, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		form       url.Values
+		wantStatus int
+		wantBody   string
+		wantCookie bool
+	}{
+		{"signup success", signupHandler, url.Values{"username": {"Stargazer"}, "password": {"password1"}, "confirm": {"password1"}, "next": {"/leaderboard"}}, http.StatusSeeOther, "", true},
+		{"signup taken", signupHandler, url.Values{"username": {"stargazer"}, "password": {"password1"}, "confirm": {"password1"}}, http.StatusConflict, "already taken", false},
+		{"signup mismatch", signupHandler, url.Values{"username": {"Moonchild"}, "password": {"password1"}, "confirm": {"password2"}}, http.StatusBadRequest, "do not match", false},
+		{"signup short password", signupHandler, url.Values{"username": {"Moonchild"}, "password": {"short"}, "confirm": {"short"}}, http.StatusBadRequest, "at least 8", false},
+		{"login success", loginHandler, url.Values{"username": {"Stargazer"}, "password": {"password1"}}, http.StatusSeeOther, "", true},
+		{"login wrong password", loginHandler, url.Values{"username": {"Stargazer"}, "password": {"nope-nope"}}, http.StatusUnauthorized, "Incorrect username or password", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(tt.handler, tt.form)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body missing %q", tt.wantBody)
+			}
+			if hasCookie := len(w.Result().Cookies()) > 0; hasCookie != tt.wantCookie {
+				t.Errorf("session cookie set = %v, want %v", hasCookie, tt.wantCookie)
+			}
+		})
+	}
+
+	// Open redirects are not allowed
+	w := post(loginHandler, url.Values{"username": {"Stargazer"}, "password": {"password1"}, "next": {"//evil.example"}})
+	if loc := w.Header().Get("Location"); loc != "/profile" {
+		t.Errorf("Location = %q, want /profile", loc)
+	}
+}
+
+func TestLoginHandler_Throttled(t *testing.T) {
+	setupAccountsTest(t)
+	if _, err := createAccount("Stargazer", "password1"); err != nil {
+		t.Fatalf("createAccount failed: %v", err)
+	}
+
+	login := func(ip, username, password string) int {
+		form := url.Values{"username": {username}, "password": {password}}
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		loginHandler(w, req)
+		return w.Code
+	}
+
+	// A successful login clears the username's failures
+	for i := 0; i < maxLoginFailuresPerUser-1; i++ {
+		login("192.0.2.1", "Stargazer", "wrong-password")
+	}
+	if code := login("192.0.2.1", "stargazer", "password1"); code != http.StatusSeeOther {
+		t.Fatalf("login after %d failures = %d, want %d", maxLoginFailuresPerUser-1, code, http.StatusSeeOther)
+	}
+
+	// Guesses at one username are capped from any address
+	for i := 0; i < maxLoginFailuresPerUser; i++ {
+		if code := login(fmt.Sprintf("198.51.100.%d", i), "Stargazer", "wrong-password"); code != http.StatusUnauthorized {
+			t.Fatalf("failure %d = %d, want %d", i, code, http.StatusUnauthorized)
+		}
+	}
+	if code := login("203.0.113.1", "Stargazer", "password1"); code != http.StatusTooManyRequests {
+		t.Errorf("login for a throttled username = %d, want %d", code, http.StatusTooManyRequests)
+	}
+
+	// Guesses from one address are capped across usernames
+	for i := 0; i < maxLoginFailuresPerIP; i++ {
+		login("192.0.2.9", fmt.Sprintf("player%d", i), "wrong-password")
+	}
+	if code := login("192.0.2.9", "someone-else", "password1"); code != http.StatusTooManyRequests {
+		t.Errorf("login from a throttled address = %d, want %d", code, http.StatusTooManyRequests)
+	}
+
+	// Failures expire after the window
+	var limiter loginLimiter
+	now := time.Now()
+	for i := 0; i < maxLoginFailuresPerUser; i++ {
+		limiter.begin("192.0.2.1", "Stargazer", now)
+	}
+	if limiter.begin("192.0.2.1", "Stargazer", now) {
+		t.Error("attempt over the limit should be refused")
+	}
+	if !limiter.begin("192.0.2.1", "Stargazer", now.Add(loginFailureWindow)) {
+		t.Error("attempt after the window should be allowed")
+	}
+}
+
+func TestQuizLeaderboardPost_VerifiedEntries(t *testing.T) {
+	setupAccountsTest(t)
+
+	cookie := loginCookie(t, "Stargazer", "password1")
+
+	// Each submission is a separate finished run
+	submit := func(name string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		state := QuizState{QuestionIDs: []string{"a", "b"}, CurrentIndex: 2, Score: 2, QuizType: "astrology", RunID: newQuizRunID()}
+		token, _ := encodeStateToken(state)
+		form := url.Values{"state": {token}, "name": {name}}
+		req := httptest.NewRequest(http.MethodPost, "/quiz/leaderboard", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		quizLeaderboardPostHandler(w, req)
+		return w
+	}
+
+	// Guests cannot post under a registered name, in any case
+	if w := submit("stargazer", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("impersonation status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	// Logged-in players post under their account name regardless of the form field
+	if w := submit("Someone Else", cookie); w.Code != http.StatusSeeOther {
+		t.Fatalf("verified submit status = %d, want %d", w.Code, http.StatusSeeOther)
+	}
+	if w := submit("Guest", nil); w.Code != http.StatusSeeOther {
+		t.Fatalf("guest submit status = %d, want %d", w.Code, http.StatusSeeOther)
+	}
+
+	entries := getLeaderboard()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	verified := map[string]bool{}
+	for _, e := range entries {
+		verified[e.Name] = e.Verified
+	}
+	if v, ok := verified["Stargazer"]; !ok || !v {
+		t.Errorf("expected verified entry for Stargazer, got %+v", entries)
+	}
+	if v, ok := verified["Guest"]; !ok || v {
+		t.Errorf("expected unverified entry for Guest, got %+v", entries)
+	}
+}
+
+func TestBuildProfileStats(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	runs := []RunRecord{
+		{QuizType: "astrology", Score: 2, Total: 5, When: base},
+		{QuizType: "tarot", Score: 4, Total: 4, When: base.Add(time.Hour)},
+		{QuizType: "astrology", Score: 5, Total: 5, When: base.Add(2 * time.Hour)},
+		{QuizType: "astrology", Score: 3, Total: 5, When: base.Add(3 * time.Hour)},
+	}
+
+	stats := buildProfileStats(runs)
+	if len(stats) != 2 || stats[0].QuizType != "astrology" || stats[1].QuizType != "tarot" {
+		t.Fatalf("unexpected grouping: %+v", stats)
+	}
+
+	astrology := stats[0]
+	if astrology.BestScore != 5 || astrology.BestTotal != 5 {
+		t.Errorf("best = %d/%d, want 5/5", astrology.BestScore, astrology.BestTotal)
+	}
+	if want := (40.0 + 100.0 + 60.0) / 3; astrology.AverageAccuracy != want {
+		t.Errorf("AverageAccuracy = %v, want %v", astrology.AverageAccuracy, want)
+	}
+	if astrology.Runs[0].Score != 3 {
+		t.Errorf("runs should be newest first, got %+v", astrology.Runs)
+	}
+	if astrology.TrendPoints != "0.0,24.0 100.0,0.0 200.0,16.0" {
+		t.Errorf("TrendPoints = %q", astrology.TrendPoints)
+	}
+
+	if tarot := stats[1]; tarot.TrendPoints != "0.0,0.0" || tarot.AverageAccuracy != 100 {
+		t.Errorf("unexpected tarot stats: %+v", tarot)
+	}
+}
+
+func TestProfileHandler(t *testing.T) {
+	setupAccountsTest(t)
+
+	// Anonymous visitors are sent to log in
+	w := httptest.NewRecorder()
+	profileHandler(w, httptest.NewRequest(http.MethodGet, "/profile", nil))
+	if w.Code != http.StatusSeeOther || !strings.HasPrefix(w.Header().Get("Location"), "/login") {
+		t.Errorf("anonymous profile: status %d, location %q", w.Code, w.Header().Get("Location"))
+	}
+
+	cookie := loginCookie(t, "Stargazer", "password1")
+	recordRun("Stargazer", RunRecord{QuizType: "tarot", Score: 3, Total: 4, When: time.Now()})
+
+	req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+	req.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	profileHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	for _, want := range []string{"Stargazer", "tarot", "3/4", "75.0%", "<polyline"} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("profile page missing %q", want)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	profileHandler(w, httptest.NewRequest(http.MethodPost, "/profile", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
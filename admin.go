@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// adminUsernames holds the lower-cased usernames allowed into /admin, set by the -admins flag
+var adminUsernames = map[string]bool{}
+
+// setAdmins parses the comma-separated -admins flag
+func setAdmins(list string) {
+	adminUsernames = map[string]bool{}
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			adminUsernames[strings.ToLower(name)] = true
+		}
+	}
+}
+
+// requireAdmin returns the logged-in admin, or responds and returns false: guests are sent to
+// log in and other players are refused
+func requireAdmin(w http.ResponseWriter, r *http.Request) (*Account, bool) {
+	account, ok := currentAccount(r)
+	if !ok {
+		http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+		return nil, false
+	}
+	if !adminUsernames[strings.ToLower(account.Username)] {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, false
+	}
+	return account, true
+}
+
+// QuestionReportPageData represents the data passed to the admin_questions.html template
+type QuestionReportPageData struct {
+	QuizType  string
+	Mode      string
+	Sort      string
+	QuizTypes []string
+	Modes     []string
+	Questions []QuestionStats
+	CSVURL    string
+	Enabled   bool // false when the server runs without an answer log
+}
+
+// questionReportFromRequest builds the report selected by the type, mode and sort query parameters
+func questionReportFromRequest(r *http.Request) ([]QuestionStats, error) {
+	var events []AnswerEvent
+	if answerEvents != nil {
+		var err error
+		if events, err = answerEvents.Events(); err != nil {
+			return nil, err
+		}
+	}
+	query := r.URL.Query()
+	return buildQuestionReport(events, query.Get("type"), query.Get("mode"), query.Get("sort")), nil
+}
+
+// adminQuestionsHandler handles GET requests to /admin/questions, the question analytics report
+func adminQuestionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	report, err := questionReportFromRequest(r)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Error building question report: %v", err)
+		return
+	}
+
+	query := r.URL.Query()
+	data := QuestionReportPageData{
+		QuizType:  query.Get("type"),
+		Mode:      query.Get("mode"),
+		Sort:      query.Get("sort"),
+		Modes:     []string{"quiz", "practice", "study"},
+		Questions: report,
+		CSVURL:    "/admin/questions.csv?" + query.Encode(),
+		Enabled:   answerEvents != nil,
+	}
+	if data.Sort == "" {
+		data.Sort = sortByAccuracy
+	}
+	for quizType := range questionSets {
+		data.QuizTypes = append(data.QuizTypes, quizType)
+	}
+	sort.Strings(data.QuizTypes)
+
+	renderPage(w, "admin_questions", defaultLanguage, data)
+}
+
+// adminQuestionsCSVHandler handles GET requests to /admin/questions.csv, the report as a spreadsheet
+func adminQuestionsCSVHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	report, err := questionReportFromRequest(r)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Error building question report: %v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="question-report.csv"`)
+	if err := writeQuestionReportCSV(w, report); err != nil {
+		log.Printf("Error writing question report CSV: %v", err)
+	}
+}
+
+// writeQuestionReportCSV writes one row per question. Choice counts are listed in choice order,
+// separated by semicolons; the discrimination cell is empty when there were too few runs.
+func writeQuestionReportCSV(w io.Writer, report []QuestionStats) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{
+		"quiz_type", "question_id", "question", "answers", "correct", "accuracy_pct",
+		"median_seconds", "discrimination", "no_answer", "choice_counts",
+		"top_distractor", "top_distractor_pct",
+	})
+	for _, q := range report {
+		discrimination := ""
+		if q.HasDiscrimination {
+			discrimination = strconv.FormatFloat(q.Discrimination, 'f', 2, 64)
+		}
+		counts := make([]string, len(q.ChoiceCounts))
+		for i, count := range q.ChoiceCounts {
+			counts[i] = strconv.Itoa(count)
+		}
+		distractor := ""
+		if q.TopDistractor >= 0 {
+			distractor = q.Choices[q.TopDistractor]
+		}
+		out.Write([]string{
+			q.QuizType, q.QuestionID, q.Question,
+			strconv.Itoa(q.Answers), strconv.Itoa(q.Correct),
+			fmt.Sprintf("%.1f", q.Accuracy),
+			fmt.Sprintf("%.1f", q.MedianSeconds),
+			discrimination,
+			strconv.Itoa(q.NoAnswer),
+			strings.Join(counts, ";"),
+			distractor,
+			fmt.Sprintf("%.1f", q.TopDistractorShare),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// FunnelPageData represents the data passed to the admin_funnel.html template
+type FunnelPageData struct {
+	QuizType      string
+	Mode          string
+	Days          int
+	QuizTypes     []string
+	Modes         []string
+	Rows          []FunnelRow
+	Steps         []int // 1-based question numbers for the step columns
+	RetentionDays int
+	Enabled       bool // false when the server runs without a funnel log
+}
+
+// Funnel report window
+const defaultFunnelDays = 30
+
+// adminFunnelHandler handles GET requests to /admin/funnel, the run funnel report. ?days= picks
+// how many days back to look, up to the retention policy.
+func adminFunnelHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	retentionDays := int(funnelRetention / (24 * time.Hour))
+	days, err := strconv.Atoi(query.Get("days"))
+	if err != nil || days < 1 {
+		days = defaultFunnelDays
+	}
+	days = min(days, max(retentionDays, 1))
+
+	var events []RunEvent
+	if runEvents != nil {
+		if events, err = runEvents.Events(); err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			log.Printf("Error reading run events: %v", err)
+			return
+		}
+	}
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-days)
+	if cutoff := time.Now().Add(-funnelRetention); since.Before(cutoff) {
+		since = cutoff
+	}
+	rows, steps := buildFunnelReport(events, query.Get("type"), query.Get("mode"), since)
+
+	data := FunnelPageData{
+		QuizType:      query.Get("type"),
+		Mode:          query.Get("mode"),
+		Days:          days,
+		Modes:         []string{"quiz", "practice", "study"},
+		Rows:          rows,
+		RetentionDays: retentionDays,
+		Enabled:       runEvents != nil,
+	}
+	for quizType := range questionSets {
+		data.QuizTypes = append(data.QuizTypes, quizType)
+	}
+	sort.Strings(data.QuizTypes)
+	for i := 1; i <= steps; i++ {
+		data.Steps = append(data.Steps, i)
+	}
+
+	renderPage(w, "admin_funnel", defaultLanguage, data)
+}
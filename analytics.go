@@ -0,0 +1,422 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AnswerEvent is one graded answer, recorded for question analytics
+type AnswerEvent struct {
+	When        time.Time `json:"when"`
+	RunID       string    `json:"run_id,omitempty"` // empty for runs started before analytics existed
+	QuizType    string    `json:"quiz_type"`
+	QuestionID  string    `json:"question_id"`
+	Choice      int       `json:"choice"` // -1 when no valid answer was given (e.g. the timer ran out)
+	Correct     bool      `json:"correct"`
+	TimeTakenMS int64     `json:"time_taken_ms,omitempty"` // 0 when unknown
+	Mode        string    `json:"mode"`                    // "quiz", "practice" or "study"
+}
+
+// Timestamp reports when the answer was graded
+func (e AnswerEvent) Timestamp() time.Time {
+	return e.When
+}
+
+// timestamped is implemented by events an EventLog can prune by age
+type timestamped interface {
+	Timestamp() time.Time
+}
+
+// EventLog appends events to a JSON Lines file
+type EventLog[T timestamped] struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// Analytics configuration
+const (
+	defaultAnalyticsFilename = "answers.jsonl"
+	discriminationGroup      = 0.27 // share of runs in each of the upper and lower groups
+	discriminationMinRuns    = 10   // fewer runs than this make the index too noisy to report
+)
+
+// answerEvents is the global answer log; nil disables recording
+var answerEvents *EventLog[AnswerEvent]
+
+// openEventLog opens (creating if needed) an event log for appending
+func openEventLog[T timestamped](path string) (*EventLog[T], error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event log: %w", err)
+	}
+	return &EventLog[T]{path: path, file: file}, nil
+}
+
+// Record appends one event
+func (l *EventLog[T]) Record(event T) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	return nil
+}
+
+// Events reads every recorded event, skipping lines that cannot be parsed
+func (l *EventLog[T]) Events() ([]T, error) {
+	l.mu.Lock()
+	data, err := os.ReadFile(l.path)
+	l.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read event log: %w", err)
+	}
+	events, _ := parseEventLines[T](data)
+	return events, nil
+}
+
+// parseEventLines decodes JSON Lines, logging and skipping malformed lines. It also returns
+// the raw line of each decoded event.
+func parseEventLines[T timestamped](data []byte) ([]T, [][]byte) {
+	var events []T
+	var lines [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event T
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			log.Printf("Skipping malformed event on line %d: %v", line, err)
+			continue
+		}
+		events = append(events, event)
+		lines = append(lines, bytes.Clone(scanner.Bytes()))
+	}
+	return events, lines
+}
+
+// Prune rewrites the log without events from before the cutoff (and without malformed lines)
+// and reports how many events were removed. The file is replaced atomically, so a crash
+// leaves either the old log or the pruned one.
+func (l *EventLog[T]) Prune(before time.Time) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read event log: %w", err)
+	}
+	events, lines := parseEventLines[T](data)
+	var kept bytes.Buffer
+	removed := 0
+	for i, event := range events {
+		if event.Timestamp().Before(before) {
+			removed++
+			continue
+		}
+		kept.Write(lines[i])
+		kept.WriteByte('\n')
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create pruned event log: %w", err)
+	}
+	if _, err := tmp.Write(kept.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return 0, fmt.Errorf("failed to write pruned event log: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return 0, fmt.Errorf("failed to write pruned event log: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		os.Remove(tmp.Name())
+		return 0, fmt.Errorf("failed to replace event log: %w", err)
+	}
+
+	// Later events go to the new file
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return removed, fmt.Errorf("failed to reopen event log: %w", err)
+	}
+	l.file.Close()
+	l.file = file
+	return removed, nil
+}
+
+// Close closes the underlying file
+func (l *EventLog[T]) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// recordAnswer logs a graded answer when analytics are enabled; failures are logged, never shown to players
+func recordAnswer(state *QuizState, q Question, answerStr string, correct bool, now time.Time) {
+	if answerEvents == nil {
+		return
+	}
+
+	choice, err := strconv.Atoi(answerStr)
+	if err != nil || choice < 0 || choice >= len(q.Choices) {
+		choice = -1
+	}
+	event := AnswerEvent{
+		When:       now,
+		RunID:      state.RunID,
+		QuizType:   state.QuizType,
+		QuestionID: q.ID,
+		Choice:     choice,
+		Correct:    correct,
+		Mode:       runMode(state),
+	}
+	if state.AskedAt > 0 {
+		event.TimeTakenMS = now.UnixMilli() - state.AskedAt
+	}
+	if err := answerEvents.Record(event); err != nil {
+		log.Printf("Error recording answer event: %v", err)
+	}
+}
+
+// runMode names the kind of run a state belongs to
+func runMode(state *QuizState) string {
+	switch {
+	case state.Study:
+		return "study"
+	case state.Practice:
+		return "practice"
+	default:
+		return "quiz"
+	}
+}
+
+// QuestionStats summarizes every recorded answer to one question
+type QuestionStats struct {
+	QuizType       string
+	QuestionID     string
+	Question       string
+	Choices        []string
+	AnswerIndex    int
+	Answers        int
+	Correct        int
+	NoAnswer       int     // answers without a valid choice
+	Accuracy       float64 // percentage of answers that were correct
+	MedianSeconds  float64 // over answers with a known time; 0 when none
+	Discrimination float64 // upper-group minus lower-group accuracy, from -1 to 1; see HasDiscrimination
+	// HasDiscrimination is false when too few runs answered the question for a meaningful index
+	HasDiscrimination bool
+	ChoiceCounts      []int
+	// TopDistractor is the most chosen wrong choice (-1 if no wrong choice was picked) and
+	// TopDistractorShare its percentage of all answers
+	TopDistractor      int
+	TopDistractorShare float64
+}
+
+// Question report sort orders
+const (
+	sortByAccuracy       = "accuracy"       // hardest first
+	sortByDiscrimination = "discrimination" // least discriminating first; questions without an index last
+	sortByDistractor     = "distractor"     // most popular distractor first
+)
+
+// buildQuestionReport summarizes events for every question in the loaded banks, optionally
+// restricted to one quiz type and run mode, in the given sort order
+func buildQuestionReport(events []AnswerEvent, quizType, mode, sortBy string) []QuestionStats {
+	filtered := events[:0:0]
+	for _, e := range events {
+		if (quizType == "" || e.QuizType == quizType) && (mode == "" || e.Mode == mode) {
+			filtered = append(filtered, e)
+		}
+	}
+
+	byQuestion := make(map[string][]AnswerEvent)
+	for _, e := range filtered {
+		key := studyCardKey(e.QuizType, e.QuestionID)
+		byQuestion[key] = append(byQuestion[key], e)
+	}
+	discrimination := discriminationIndexes(filtered)
+
+	var report []QuestionStats
+	for qt, questions := range questionSets {
+		if quizType != "" && qt != quizType {
+			continue
+		}
+		for _, q := range questions {
+			key := studyCardKey(qt, q.ID)
+			stats := QuestionStats{
+				QuizType:      qt,
+				QuestionID:    q.ID,
+				Question:      q.Question,
+				Choices:       q.Choices,
+				AnswerIndex:   q.AnswerIndex,
+				ChoiceCounts:  make([]int, len(q.Choices)),
+				TopDistractor: -1,
+			}
+			var times []float64
+			for _, e := range byQuestion[key] {
+				stats.Answers++
+				if e.Correct {
+					stats.Correct++
+				}
+				if e.Choice >= 0 && e.Choice < len(q.Choices) {
+					stats.ChoiceCounts[e.Choice]++
+				} else {
+					stats.NoAnswer++
+				}
+				if e.TimeTakenMS > 0 {
+					times = append(times, float64(e.TimeTakenMS)/1000)
+				}
+			}
+			if stats.Answers > 0 {
+				stats.Accuracy = float64(stats.Correct) / float64(stats.Answers) * 100
+			}
+			stats.MedianSeconds = median(times)
+			stats.Discrimination, stats.HasDiscrimination = discrimination[key]
+			for choice, count := range stats.ChoiceCounts {
+				if choice != q.AnswerIndex && count > 0 &&
+					(stats.TopDistractor < 0 || count > stats.ChoiceCounts[stats.TopDistractor]) {
+					stats.TopDistractor = choice
+				}
+			}
+			if stats.TopDistractor >= 0 {
+				stats.TopDistractorShare = float64(stats.ChoiceCounts[stats.TopDistractor]) / float64(stats.Answers) * 100
+			}
+			report = append(report, stats)
+		}
+	}
+
+	sortQuestionReport(report, sortBy)
+	return report
+}
+
+// sortQuestionReport orders a report, breaking ties by quiz type and question ID
+func sortQuestionReport(report []QuestionStats, sortBy string) {
+	sort.Slice(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		switch sortBy {
+		case sortByDiscrimination:
+			if a.HasDiscrimination != b.HasDiscrimination {
+				return a.HasDiscrimination
+			}
+			if a.Discrimination != b.Discrimination {
+				return a.Discrimination < b.Discrimination
+			}
+		case sortByDistractor:
+			if a.TopDistractorShare != b.TopDistractorShare {
+				return a.TopDistractorShare > b.TopDistractorShare
+			}
+		default:
+			// Unanswered questions have no accuracy yet, so they go last
+			if (a.Answers == 0) != (b.Answers == 0) {
+				return a.Answers > 0
+			}
+			if a.Accuracy != b.Accuracy {
+				return a.Accuracy < b.Accuracy
+			}
+		}
+		if a.QuizType != b.QuizType {
+			return a.QuizType < b.QuizType
+		}
+		return a.QuestionID < b.QuestionID
+	})
+}
+
+// discriminationIndexes computes the classical upper-lower discrimination index for each
+// question, keyed by studyCardKey. For a question, the runs that answered it are ranked by
+// their accuracy on their other questions; the index is the question's accuracy in the top
+// 27% of those runs minus its accuracy in the bottom 27%. Events without a run ID are ignored.
+func discriminationIndexes(events []AnswerEvent) map[string]float64 {
+	// The last answer per run and question wins
+	runs := make(map[string]map[string]bool)
+	for _, e := range events {
+		if e.RunID == "" {
+			continue
+		}
+		if runs[e.RunID] == nil {
+			runs[e.RunID] = make(map[string]bool)
+		}
+		runs[e.RunID][studyCardKey(e.QuizType, e.QuestionID)] = e.Correct
+	}
+
+	type runResult struct {
+		rest    float64 // accuracy on the run's other questions
+		correct bool
+	}
+	byQuestion := make(map[string][]runResult)
+	for _, answers := range runs {
+		if len(answers) < 2 {
+			continue
+		}
+		total := 0
+		for _, correct := range answers {
+			if correct {
+				total++
+			}
+		}
+		for key, correct := range answers {
+			rest := total
+			if correct {
+				rest--
+			}
+			byQuestion[key] = append(byQuestion[key], runResult{
+				rest:    float64(rest) / float64(len(answers)-1),
+				correct: correct,
+			})
+		}
+	}
+
+	indexes := make(map[string]float64)
+	for key, results := range byQuestion {
+		if len(results) < discriminationMinRuns {
+			continue
+		}
+		sort.Slice(results, func(i, j int) bool { return results[i].rest < results[j].rest })
+		group := int(math.Round(discriminationGroup * float64(len(results))))
+		lower, upper := 0, 0
+		for i := 0; i < group; i++ {
+			if results[i].correct {
+				lower++
+			}
+			if results[len(results)-1-i].correct {
+				upper++
+			}
+		}
+		indexes[key] = float64(upper-lower) / float64(group)
+	}
+	return indexes
+}
+
+// median returns the middle value of a sample, or 0 for an empty one
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
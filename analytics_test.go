@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useAnswerLog records answers to a fresh log in a temporary directory for the rest of the test
+func useAnswerLog(t *testing.T) *EventLog[AnswerEvent] {
+	t.Helper()
+	eventLog, err := openEventLog[AnswerEvent](filepath.Join(t.TempDir(), "answers.jsonl"))
+	if err != nil {
+		t.Fatalf("openEventLog failed: %v", err)
+	}
+	original := answerEvents
+	answerEvents = eventLog
+	t.Cleanup(func() {
+		answerEvents = original
+		eventLog.Close()
+	})
+	return eventLog
+}
+
+func TestAnswerEventLog(t *testing.T) {
+	eventLog := useAnswerLog(t)
+	when := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	want := []AnswerEvent{
+		{When: when, RunID: "r1", QuizType: "astrology", QuestionID: "q1", Choice: 2, Correct: true, TimeTakenMS: 4200, Mode: "quiz"},
+		{When: when, QuizType: "tarot", QuestionID: "t1", Choice: -1, Mode: "practice"},
+	}
+	if err := eventLog.Record(want[0]); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	// A torn write from a crash must not hide the events around it
+	f, _ := os.OpenFile(eventLog.path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("{\"when\":\n")
+	f.Close()
+	if err := eventLog.Record(want[1]); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	got, err := eventLog.Events()
+	if err != nil {
+		t.Fatalf("Events failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Events() = %+v, want %+v", got, want)
+	}
+}
+
+// reportQuestions installs a bank for the report tests
+func reportQuestions() {
+	questionSets = map[string][]Question{
+		"astrology": {
+			{ID: "easy", Question: "Easy?", Choices: []string{"A", "B", "C"}, AnswerIndex: 0},
+			{ID: "hard", Question: "Hard?", Choices: []string{"A", "B", "C"}, AnswerIndex: 0},
+			{ID: "unseen", Question: "Unseen?", Choices: []string{"A", "B"}, AnswerIndex: 1},
+		},
+	}
+}
+
+func TestBuildQuestionReport(t *testing.T) {
+	reportQuestions()
+	events := []AnswerEvent{
+		{QuizType: "astrology", QuestionID: "easy", Choice: 0, Correct: true, TimeTakenMS: 2000, Mode: "quiz"},
+		{QuizType: "astrology", QuestionID: "easy", Choice: 0, Correct: true, TimeTakenMS: 4000, Mode: "quiz"},
+		{QuizType: "astrology", QuestionID: "easy", Choice: 1, TimeTakenMS: 9000, Mode: "practice"},
+		{QuizType: "astrology", QuestionID: "hard", Choice: 2, TimeTakenMS: 20000, Mode: "quiz"},
+		{QuizType: "astrology", QuestionID: "hard", Choice: 2, Mode: "quiz"},
+		{QuizType: "astrology", QuestionID: "hard", Choice: 1, Mode: "quiz"},
+		{QuizType: "astrology", QuestionID: "hard", Choice: -1, Mode: "quiz"},
+		{QuizType: "tarot", QuestionID: "gone", Choice: 0, Correct: true, Mode: "quiz"},
+	}
+
+	report := buildQuestionReport(events, "", "", "")
+	var ids []string
+	for _, q := range report {
+		ids = append(ids, q.QuestionID)
+	}
+	if want := []string{"hard", "easy", "unseen"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("accuracy order = %v, want %v (questions no longer loaded are left out)", ids, want)
+	}
+
+	hard := report[0]
+	if hard.Answers != 4 || hard.Correct != 0 || hard.NoAnswer != 1 || !reflect.DeepEqual(hard.ChoiceCounts, []int{0, 1, 2}) {
+		t.Errorf("hard stats = %+v", hard)
+	}
+	if hard.TopDistractor != 2 || hard.TopDistractorShare != 50 || hard.MedianSeconds != 20 {
+		t.Errorf("hard distractor %d (%.1f%%), median %.1fs; want 2 (50%%), 20s", hard.TopDistractor, hard.TopDistractorShare, hard.MedianSeconds)
+	}
+	easy := report[1]
+	if easy.Accuracy < 66.6 || easy.Accuracy > 66.7 || easy.MedianSeconds != 4 || easy.TopDistractor != 1 {
+		t.Errorf("easy stats = %+v", easy)
+	}
+	if unseen := report[2]; unseen.Answers != 0 || unseen.TopDistractor != -1 || unseen.HasDiscrimination {
+		t.Errorf("unseen stats = %+v", unseen)
+	}
+
+	if report := buildQuestionReport(events, "astrology", "quiz", sortByDistractor); report[0].QuestionID != "hard" || report[1].Answers != 2 {
+		t.Errorf("quiz-mode distractor report = %+v", report)
+	}
+}
+
+func TestDiscriminationIndexes(t *testing.T) {
+	// Strong runs get x and y right, weak runs get them wrong. "good" follows the strong runs;
+	// "bad" is answered correctly only by weak runs, as an ambiguous question might be.
+	var events []AnswerEvent
+	for i := 0; i < 10; i++ {
+		strong := i < 5
+		run := string(rune('a' + i))
+		for id, correct := range map[string]bool{"x": strong, "y": strong, "good": strong, "bad": !strong} {
+			events = append(events, AnswerEvent{RunID: run, QuizType: "astrology", QuestionID: id, Correct: correct})
+		}
+	}
+	// Answers without a run cannot be ranked and are ignored
+	events = append(events, AnswerEvent{QuizType: "astrology", QuestionID: "good"})
+
+	indexes := discriminationIndexes(events)
+	if indexes["astrology/good"] != 1 || indexes["astrology/bad"] != -1 {
+		t.Errorf("indexes = %v, want good 1 and bad -1", indexes)
+	}
+
+	if indexes := discriminationIndexes(events[:4*(discriminationMinRuns-1)]); len(indexes) != 0 {
+		t.Errorf("too few runs should give no index, got %v", indexes)
+	}
+}
+
+func TestAdminQuestionReport(t *testing.T) {
+	setupAccountsTest(t)
+	reportQuestions()
+	useAnswerLog(t)
+	setAdmins("Alice")
+	t.Cleanup(func() { setAdmins("") })
+	answerEvents.Record(AnswerEvent{QuizType: "astrology", QuestionID: "hard", Choice: 2, Mode: "quiz"})
+
+	admin := loginCookie(t, "alice", "password123")
+	player := loginCookie(t, "bob", "password123")
+
+	tests := []struct {
+		name       string
+		target     string
+		cookie     *http.Cookie
+		wantStatus int
+	}{
+		{"guest is sent to log in", "/admin/questions", nil, http.StatusSeeOther},
+		{"players are refused", "/admin/questions", player, http.StatusForbidden},
+		{"players cannot export", "/admin/questions.csv", player, http.StatusForbidden},
+		{"admin sees the report", "/admin/questions?sort=distractor", admin, http.StatusOK},
+		{"admin exports the report", "/admin/questions.csv?type=astrology", admin, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			w := httptest.NewRecorder()
+			setupRoutes().ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("GET %s = %d, want %d", tt.target, w.Code, tt.wantStatus)
+			}
+			if w.Code == http.StatusOK && !strings.Contains(w.Body.String(), "Hard?") {
+				t.Error("report should list the questions")
+			}
+		})
+	}
+
+	// The CSV has a header and one row per loaded question
+	req := httptest.NewRequest(http.MethodGet, "/admin/questions.csv", nil)
+	req.AddCookie(admin)
+	w := httptest.NewRecorder()
+	setupRoutes().ServeHTTP(w, req)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("Content-Type = %q, want text/csv", ct)
+	}
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil || len(rows) != 4 {
+		t.Fatalf("CSV rows = %v (%v), want header and 3 questions", rows, err)
+	}
+	if rows[0][0] != "quiz_type" || rows[1][1] != "hard" || rows[1][10] != "C" || rows[1][9] != "0;0;1" {
+		t.Errorf("unexpected CSV: %v", rows[:2])
+	}
+}
+
+func TestQuizPostHandler_RecordsAnswers(t *testing.T) {
+	eventLog := useAnswerLog(t)
+	questionSets = map[string][]Question{
+		"astrology": {{ID: "q1", Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 1}},
+	}
+
+	state := QuizState{QuestionIDs: []string{"q1"}, QuizType: "astrology", RunID: newQuizRunID(), AskedAt: time.Now().Add(-3 * time.Second).UnixMilli()}
+	token, _ := encodeStateToken(state)
+	form := url.Values{"state": {token}, "answer": {"0"}}
+	req := httptest.NewRequest(http.MethodPost, "/quiz", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	quizPostHandler(httptest.NewRecorder(), req)
+
+	events, _ := eventLog.Events()
+	if len(events) != 1 {
+		t.Fatalf("recorded %d events, want 1", len(events))
+	}
+	e := events[0]
+	if e.RunID != state.RunID || e.QuestionID != "q1" || e.Choice != 0 || e.Correct || e.Mode != "quiz" {
+		t.Errorf("event = %+v", e)
+	}
+	if e.TimeTakenMS < 3000 || e.TimeTakenMS > 10000 {
+		t.Errorf("time taken = %dms, want about 3s", e.TimeTakenMS)
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// embedCompleteMessage is the postMessage type an embedded quiz sends its parent page on completion
+const embedCompleteMessage = "quiz:complete"
+
+// embedOrigins holds the extra frame-ancestors sources allowed to embed quizzes, set by the
+// -embed-origins flag. The site itself may always embed them.
+var embedOrigins []string
+
+// setEmbedOrigins parses the comma-separated -embed-origins flag. Each source is "*" or an
+// origin such as https://partner.example or https://*.partner.example.
+func setEmbedOrigins(list string) error {
+	var origins []string
+	for _, origin := range strings.Split(list, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin == "" {
+			continue
+		}
+		if origin != "*" {
+			u, err := url.Parse(origin)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+				(u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+				return fmt.Errorf("invalid embed origin %q (want scheme://host[:port] or *)", origin)
+			}
+			origin = u.Scheme + "://" + u.Host
+		}
+		origins = append(origins, origin)
+	}
+	embedOrigins = origins
+	return nil
+}
+
+// embedParentOrigin returns the origin of the page embedding a new run: the ?parent= origin the
+// snippet passes or else the origin of the Referer, the page that loaded the frame. It returns ""
+// unless -embed-origins allows that origin.
+func embedParentOrigin(r *http.Request) string {
+	parent := r.URL.Query().Get("parent")
+	if parent == "" {
+		parent = r.Referer()
+	}
+	return allowedEmbedOrigin(parent)
+}
+
+// allowedEmbedOrigin returns the origin of a URL when an -embed-origins source covers it, or ""
+// otherwise. "*" covers every origin and https://*.partner.example covers the subdomains of
+// partner.example, as they do in frame-ancestors.
+func allowedEmbedOrigin(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	host := strings.ToLower(u.Host)
+	for _, source := range embedOrigins {
+		if source == "*" {
+			return u.Scheme + "://" + host
+		}
+		allowed, err := url.Parse(source)
+		if err != nil || allowed.Scheme != u.Scheme {
+			continue
+		}
+		allowedHost := strings.ToLower(allowed.Host)
+		domain, wildcard := strings.CutPrefix(allowedHost, "*.")
+		if host == allowedHost || (wildcard && strings.HasSuffix(host, "."+domain)) {
+			return u.Scheme + "://" + host
+		}
+	}
+	return ""
+}
+
+// embedFrameAncestors returns the Content-Security-Policy directive for embeddable pages
+func embedFrameAncestors() string {
+	return strings.Join(append([]string{"frame-ancestors", "'self'"}, embedOrigins...), " ")
+}
+
+// EmbedResult is a finished embedded run, also sent to the parent page
+type EmbedResult struct {
+	Type       string  `json:"type"`
+	QuizType   string  `json:"quizType"`
+	Score      int     `json:"score"`
+	Total      int     `json:"total"`
+	Percentage float64 `json:"percentage"`
+}
+
+// EmbedPageData represents the data passed to the embed.html template
+type EmbedPageData struct {
+	Theme          Theme
+	QuizType       string
+	Question       Question
+	CurrentIndex   int // 1-based
+	TotalQuestions int
+	Score          int
+	StateToken     string
+	Step           int
+	Result         *EmbedResult // set once the run is finished
+	ParentOrigin   string       // the verified origin of the embedding page, the only one told the result
+}
+
+// embedPath returns the path of an embedded quiz page, carrying the state token when given
+func embedPath(quizType, page, token string) string {
+	path := "/embed/" + url.PathEscape(quizType) + page
+	if token != "" {
+		path += "?state=" + url.QueryEscape(token)
+	}
+	return path
+}
+
+// renderEmbed renders an embedded quiz page that the configured origins may frame. The
+// allowlist replaces the site's framing policy, which X-Frame-Options cannot express.
+func renderEmbed(w http.ResponseWriter, r *http.Request, data EmbedPageData) {
+	h := w.Header()
+	h.Del("X-Frame-Options")
+	h.Set("Content-Security-Policy", withDirective(h.Get("Content-Security-Policy"), embedFrameAncestors()))
+	renderPage(w, "embed", requestLanguage(w, r), data)
+}
+
+// embedQuizHandler handles /embed/{type}: GET starts a compact, iframe-friendly quiz and POST
+// answers its current question. Embedded runs carry their state in the page rather than in
+// cookies, which browsers withhold from third-party frames.
+func embedQuizHandler(w http.ResponseWriter, r *http.Request) {
+	quizType := r.PathValue("type")
+	questions, exists := questionSets[quizType]
+	if !exists || len(questions) == 0 {
+		http.Error(w, "Quiz type not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		now := time.Now()
+		state := &QuizState{
+			QuestionIDs: selectQuestionIDs(questions, NumQuestions),
+			QuizType:    quizType,
+			RunID:       newQuizRunID(),
+			AskedAt:     now.UnixMilli(),
+			StartedAt:   now.UnixMilli(),
+			EmbedOrigin: embedParentOrigin(r),
+		}
+		answeredSteps.begin(state.RunID, now)
+		recordRunEvent(state, runStarted, now)
+		renderEmbedQuestion(w, r, state)
+	case http.MethodPost:
+		embedAnswerHandler(w, r, quizType, questions)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// embedAnswerHandler grades an embedded run's current question and redirects to the next
+// question or the results
+func embedAnswerHandler(w http.ResponseWriter, r *http.Request, quizType string, questions []Question) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	state, valid := decodeStateToken(r.FormValue("state"))
+	if !valid || state.QuizType != quizType || state.RunID == "" || state.Practice {
+		http.Redirect(w, r, embedPath(quizType, "", ""), http.StatusSeeOther)
+		return
+	}
+	token := r.FormValue("state")
+	if state.CurrentIndex >= len(state.QuestionIDs) {
+		http.Redirect(w, r, embedPath(quizType, "/results", token), http.StatusSeeOther)
+		return
+	}
+
+	currentQuestion, found := findQuestion(questions, state.QuestionIDs[state.CurrentIndex])
+	if !found {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Question ID %s not found", state.QuestionIDs[state.CurrentIndex])
+		return
+	}
+
+	// A resubmitted old state (double click, back button) moves on to the run's newest state
+	if step := r.FormValue("step"); step != strconv.Itoa(state.CurrentIndex) ||
+		!gradeStep(r, state, currentQuestion, r.FormValue("answer"), time.Now()) {
+		if latest, ok := answeredSteps.latestToken(state.RunID); ok {
+			token = latest
+		}
+		http.Redirect(w, r, embedPath(quizType, "/play", token), http.StatusSeeOther)
+		return
+	}
+
+	token, err := encodeStateToken(*state)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Error storing quiz state: %v", err)
+		return
+	}
+	answeredSteps.remember(state.RunID, token)
+
+	page := "/play"
+	if state.CurrentIndex >= len(state.QuestionIDs) {
+		page = "/results"
+	}
+	http.Redirect(w, r, embedPath(quizType, page, token), http.StatusSeeOther)
+}
+
+// embedPlayHandler handles GET /embed/{type}/play, the embedded page each answer redirects to
+func embedPlayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	quizType := r.PathValue("type")
+	state, valid := decodeStateToken(r.URL.Query().Get("state"))
+	if !valid || state.QuizType != quizType || state.Practice {
+		http.Redirect(w, r, embedPath(quizType, "", ""), http.StatusSeeOther)
+		return
+	}
+	if state.CurrentIndex >= len(state.QuestionIDs) {
+		http.Redirect(w, r, embedPath(quizType, "/results", r.URL.Query().Get("state")), http.StatusSeeOther)
+		return
+	}
+	renderEmbedQuestion(w, r, state)
+}
+
+// renderEmbedQuestion shows an embedded run's current question
+func renderEmbedQuestion(w http.ResponseWriter, r *http.Request, state *QuizState) {
+	question, found := findQuestion(questionSets[state.QuizType], state.QuestionIDs[state.CurrentIndex])
+	if !found {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Question ID %s not found", state.QuestionIDs[state.CurrentIndex])
+		return
+	}
+	token, err := encodeStateToken(*state)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Error storing quiz state: %v", err)
+		return
+	}
+
+	lang := requestLanguage(w, r)
+	renderEmbed(w, r, EmbedPageData{
+		Theme:          themeFor(state.QuizType, lang),
+		QuizType:       state.QuizType,
+		Question:       localizeQuestion(question, lang),
+		CurrentIndex:   state.CurrentIndex + 1,
+		TotalQuestions: len(state.QuestionIDs),
+		Score:          state.Score,
+		StateToken:     token,
+		Step:           state.CurrentIndex,
+	})
+}
+
+// embedResultsHandler handles GET /embed/{type}/results, which shows the score and tells the
+// parent page the run is complete
+func embedResultsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	quizType := r.PathValue("type")
+	state, valid := decodeStateToken(r.URL.Query().Get("state"))
+	if !valid || state.QuizType != quizType || state.Practice {
+		http.Redirect(w, r, embedPath(quizType, "", ""), http.StatusSeeOther)
+		return
+	}
+	if state.CurrentIndex < len(state.QuestionIDs) {
+		http.Redirect(w, r, embedPath(quizType, "/play", r.URL.Query().Get("state")), http.StatusSeeOther)
+		return
+	}
+	recordRunEvent(state, runResultsViewed, time.Now())
+
+	result := &EmbedResult{
+		Type:     embedCompleteMessage,
+		QuizType: state.QuizType,
+		Score:    state.Score,
+		Total:    len(state.QuestionIDs),
+	}
+	if result.Total > 0 {
+		result.Percentage = float64(result.Score) / float64(result.Total) * 100.0
+	}
+	renderEmbed(w, r, EmbedPageData{
+		Theme:        themeFor(state.QuizType, requestLanguage(w, r)),
+		QuizType:     state.QuizType,
+		Result:       result,
+		ParentOrigin: allowedEmbedOrigin(state.EmbedOrigin),
+	})
+}
+
+// embedSnippetTemplate is the HTML a partner site pastes to embed a quiz
+var embedSnippetTemplate = template.Must(template.New("snippet").Parse(`<iframe src="{{.Src}}" title="{{.Title}}" width="{{.Width}}" height="{{.Height}}" style="border: 0; max-width: 100%;" loading="lazy"></iframe>
+<script>
+  window.addEventListener('message', function (event) {
+    if (event.origin !== {{.Origin}} || !event.data || event.data.type !== {{.Message}}) {
+      return;
+    }
+    // Handle the finished run here. event.data carries quizType, score, total and percentage.
+  });
+</script>
+`))
+
+// runEmbedSnippet implements `helloworld embed-snippet [flags] type`, printing the HTML that
+// embeds a quiz type. It returns the process exit code: 0 on success and 2 on usage errors.
+func runEmbedSnippet(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("embed-snippet", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	base := fs.String("base", "http://localhost:8080", "Public URL of this server")
+	width := fs.String("width", "100%", "Frame width (pixels or a percentage)")
+	height := fs.String("height", "560", "Frame height in pixels")
+	parent := fs.String("parent", "", "Origin of the embedding page, told the result even when the browser sends no Referer")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: helloworld embed-snippet [-base URL] [-width W] [-height H] [-parent ORIGIN] type")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 || fs.Arg(0) == "" {
+		fs.Usage()
+		return 2
+	}
+	baseURL, err := url.Parse(strings.TrimSuffix(*base, "/"))
+	if err != nil || (baseURL.Scheme != "http" && baseURL.Scheme != "https") || baseURL.Host == "" {
+		fmt.Fprintf(stderr, "invalid -base %q (want an http or https URL)\n", *base)
+		return 2
+	}
+
+	quizType := fs.Arg(0)
+	src := baseURL.String() + embedPath(quizType, "", "")
+	if *parent != "" {
+		parentURL, err := url.Parse(*parent)
+		if err != nil || (parentURL.Scheme != "http" && parentURL.Scheme != "https") || parentURL.Host == "" {
+			fmt.Fprintf(stderr, "invalid -parent %q (want an http or https origin)\n", *parent)
+			return 2
+		}
+		src += "?parent=" + url.QueryEscape(parentURL.Scheme+"://"+parentURL.Host)
+	}
+
+	title := quizType
+	if theme, ok := themes[quizType]; ok {
+		title = theme.Title
+	}
+	err = embedSnippetTemplate.Execute(stdout, map[string]string{
+		"Src":     src,
+		"Title":   title,
+		"Width":   *width,
+		"Height":  *height,
+		"Origin":  baseURL.Scheme + "://" + baseURL.Host,
+		"Message": embedCompleteMessage,
+	})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
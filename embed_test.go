@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// embedStateField matches the state token an embedded quiz page carries in its form
+var embedStateField = regexp.MustCompile(`name="state" value="([^"]+)"`)
+
+// sendEmbed sends a request through the routes without cookies, as a third-party frame would,
+// with optional extra headers
+func sendEmbed(mux *http.ServeMux, method, target string, form url.Values, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
+
+func TestSetEmbedOrigins(t *testing.T) {
+	defer func() { embedOrigins = nil }()
+
+	tests := []struct {
+		list    string
+		want    string
+		wantErr bool
+	}{
+		{"", "frame-ancestors 'self'", false},
+		{"https://blog.example", "frame-ancestors 'self' https://blog.example", false},
+		{" https://blog.example/ , http://localhost:3000", "frame-ancestors 'self' https://blog.example http://localhost:3000", false},
+		{"https://*.partner.example", "frame-ancestors 'self' https://*.partner.example", false},
+		{"*", "frame-ancestors 'self' *", false},
+		{"blog.example", "", true},
+		{"ftp://blog.example", "", true},
+		{"https://blog.example/quiz", "", true},
+		{"https://blog.example; script-src *", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.list, func(t *testing.T) {
+			embedOrigins = nil
+			err := setEmbedOrigins(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setEmbedOrigins(%q) error = %v, wantErr %v", tt.list, err, tt.wantErr)
+			}
+			if !tt.wantErr && embedFrameAncestors() != tt.want {
+				t.Errorf("embedFrameAncestors() = %q, want %q", embedFrameAncestors(), tt.want)
+			}
+		})
+	}
+}
+
+// TestEmbedQuiz_Run tests a whole embedded run without cookies, ending with the completion message
+func TestEmbedQuiz_Run(t *testing.T) {
+	setupAccountsTest(t)
+	practiceQuestions()
+	// Server-side sessions need a cookie, which third-party frames don't get; embeds must not use them
+	useQuizSessions(t, newMemorySessionStore())
+	defer func() { embedOrigins = nil }()
+	if err := setEmbedOrigins("https://blog.example"); err != nil {
+		t.Fatal(err)
+	}
+	mux := setupRoutes()
+
+	w := sendEmbed(mux, http.MethodGet, "/embed/astrology", nil, "Referer", "https://blog.example/posts/quiz-night")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); csp != "frame-ancestors 'self' https://blog.example" {
+		t.Errorf("Content-Security-Policy = %q", csp)
+	}
+	if body := w.Body.String(); !strings.Contains(body, `action="/embed/astrology"`) || strings.Contains(body, "onchange") {
+		t.Error("expected a compact form posting back to the embed")
+	}
+	if body := w.Body.String(); !strings.Contains(body, `data-label="Time: {seconds}s">Time: 20s</div>`) {
+		t.Error("expected the timer label to carry a {seconds} placeholder")
+	}
+
+	for step := 0; step < NumQuestions; step++ {
+		match := embedStateField.FindStringSubmatch(w.Body.String())
+		if match == nil {
+			t.Fatalf("step %d: no state token in page", step)
+		}
+		w = sendEmbed(mux, http.MethodPost, "/embed/astrology", url.Values{"state": {match[1]}, "step": {strconv.Itoa(step)}, "answer": {"0"}})
+		if w.Code != http.StatusSeeOther {
+			t.Fatalf("step %d: expected a redirect, got %d", step, w.Code)
+		}
+		if c := w.Result().Cookies(); len(c) != 0 {
+			t.Fatalf("step %d: embedded runs must not rely on cookies, got %v", step, c)
+		}
+		w = sendEmbed(mux, http.MethodGet, w.Header().Get("Location"), nil)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the results page, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{`<div class="score-display">3 / 3</div>`, `"type":"quiz:complete"`, `"quizType":"astrology"`, `"score":3`, `"percentage":100`, `postMessage(`, `"https://blog.example")`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected results to contain %q", want)
+		}
+	}
+	if strings.Contains(body, `name="name"`) {
+		t.Error("embedded results must not offer leaderboard submission")
+	}
+	if got := w.Header().Get("Content-Security-Policy"); !strings.HasPrefix(got, "frame-ancestors") {
+		t.Errorf("expected results to be frameable, got CSP %q", got)
+	}
+}
+
+// TestEmbedQuiz_ResubmittedState tests that replaying a graded state moves on instead of grading twice
+func TestEmbedQuiz_ResubmittedState(t *testing.T) {
+	setupAccountsTest(t)
+	practiceQuestions()
+	mux := setupRoutes()
+
+	w := sendEmbed(mux, http.MethodGet, "/embed/astrology", nil)
+	first := embedStateField.FindStringSubmatch(w.Body.String())[1]
+	answer := url.Values{"state": {first}, "step": {"0"}, "answer": {"0"}}
+
+	next := sendEmbed(mux, http.MethodPost, "/embed/astrology", answer).Header().Get("Location")
+	replay := sendEmbed(mux, http.MethodPost, "/embed/astrology", answer)
+	if replay.Code != http.StatusSeeOther || replay.Header().Get("Location") != next {
+		t.Fatalf("expected the replay to redirect to %q, got %d %q", next, replay.Code, replay.Header().Get("Location"))
+	}
+
+	u, _ := url.Parse(next)
+	state, ok := decodeStateToken(u.Query().Get("state"))
+	if !ok || state.Score != 1 || state.CurrentIndex != 1 {
+		t.Errorf("expected the answer to count once, got %+v", state)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		form   url.Values
+		code   int
+		loc    string
+	}{
+		{"unknown quiz", http.MethodGet, "/embed/palmistry", nil, http.StatusNotFound, ""},
+		{"forged state restarts", http.MethodPost, "/embed/astrology", url.Values{"state": {"forged"}, "step": {"0"}}, http.StatusSeeOther, "/embed/astrology"},
+		{"state for another quiz restarts", http.MethodGet, "/embed/tarot/play?state=" + url.QueryEscape(first), nil, http.StatusSeeOther, "/embed/tarot"},
+		{"unfinished run has no results", http.MethodGet, "/embed/astrology/results?state=" + url.QueryEscape(first), nil, http.StatusSeeOther, "/embed/astrology/play?state=" + url.QueryEscape(first)},
+		{"method not allowed", http.MethodDelete, "/embed/astrology", nil, http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := sendEmbed(mux, tt.method, tt.target, tt.form)
+			if w.Code != tt.code || w.Header().Get("Location") != tt.loc {
+				t.Errorf("expected %d %q, got %d %q", tt.code, tt.loc, w.Code, w.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestAllowedEmbedOrigin(t *testing.T) {
+	defer func() { embedOrigins = nil }()
+
+	tests := []struct {
+		origins string
+		url     string
+		want    string
+	}{
+		{"https://blog.example", "https://blog.example/posts/1", "https://blog.example"},
+		{"https://blog.example", "https://BLOG.example", "https://blog.example"},
+		{"https://blog.example", "http://blog.example/", ""},
+		{"https://blog.example", "https://evil.example/", ""},
+		{"https://blog.example", "https://blog.example.evil.example/", ""},
+		{"https://*.partner.example", "https://shop.partner.example/p", "https://shop.partner.example"},
+		{"https://*.partner.example", "https://partner.example/", ""},
+		{"https://*.partner.example", "https://evilpartner.example/", ""},
+		{"*", "http://localhost:3000/page", "http://localhost:3000"},
+		{"", "https://blog.example/", ""},
+		{"https://blog.example", "", ""},
+		{"https://blog.example", "javascript:alert(1)", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origins+" "+tt.url, func(t *testing.T) {
+			if err := setEmbedOrigins(tt.origins); err != nil {
+				t.Fatal(err)
+			}
+			if got := allowedEmbedOrigin(tt.url); got != tt.want {
+				t.Errorf("allowedEmbedOrigin(%q) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEmbedQuiz_ParentOrigin tests that only a run started by an allowed page posts its result, and only to that page
+func TestEmbedQuiz_ParentOrigin(t *testing.T) {
+	setupAccountsTest(t)
+	practiceQuestions()
+	defer func() { embedOrigins = nil }()
+	if err := setEmbedOrigins("https://blog.example"); err != nil {
+		t.Fatal(err)
+	}
+	mux := setupRoutes()
+
+	tests := []struct {
+		name    string
+		target  string
+		referer string
+		want    string
+	}{
+		{"referer", "/embed/astrology", "https://blog.example/post", "https://blog.example"},
+		{"parent parameter", "/embed/astrology?parent=" + url.QueryEscape("https://blog.example"), "", "https://blog.example"},
+		{"unlisted referer", "/embed/astrology", "https://evil.example/", ""},
+		{"no referer", "/embed/astrology", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var headers []string
+			if tt.referer != "" {
+				headers = []string{"Referer", tt.referer}
+			}
+			w := sendEmbed(mux, http.MethodGet, tt.target, nil, headers...)
+			for step := 0; step < NumQuestions; step++ {
+				token := embedStateField.FindStringSubmatch(w.Body.String())[1]
+				w = sendEmbed(mux, http.MethodPost, "/embed/astrology", url.Values{"state": {token}, "step": {strconv.Itoa(step)}, "answer": {"0"}})
+				w = sendEmbed(mux, http.MethodGet, w.Header().Get("Location"), nil)
+			}
+
+			body := w.Body.String()
+			if tt.want == "" {
+				if strings.Contains(body, "postMessage") {
+					t.Error("expected no result message for an unverified parent")
+				}
+				return
+			}
+			if !strings.Contains(body, `"`+tt.want+`")`) || strings.Contains(body, `'*'`) {
+				t.Errorf("expected the result posted to %s only", tt.want)
+			}
+		})
+	}
+}
+
+func TestRunEmbedSnippet(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runEmbedSnippet([]string{"-base", "https://quiz.example/", "-height", "480", "tarot"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{`src="https://quiz.example/embed/tarot"`, `title="Tarot Quiz"`, `height="480"`, `event.origin !== "https://quiz.example"`, `event.data.type !== "quiz:complete"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected snippet to contain %q\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "console.log") {
+		t.Errorf("expected a handler stub, not debug logging\n%s", out)
+	}
+
+	stdout.Reset()
+	if code := runEmbedSnippet([]string{"-base", "https://quiz.example", "-parent", "https://blog.example/posts", "tarot"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if want := `src="https://quiz.example/embed/tarot?parent=https%3A%2F%2Fblog.example"`; !strings.Contains(stdout.String(), want) {
+		t.Errorf("expected snippet to contain %q\n%s", want, stdout.String())
+	}
+
+	for _, args := range [][]string{nil, {"a", "b"}, {"-base", "quiz.example", "tarot"}, {"-parent", "blog.example", "tarot"}, {"-nope"}} {
+		stderr.Reset()
+		if code := runEmbedSnippet(args, &stdout, &stderr); code != 2 || stderr.Len() == 0 {
+			t.Errorf("runEmbedSnippet(%q) = %d, want 2 with usage", args, code)
+		}
+	}
+}
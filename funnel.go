@@ -0,0 +1,204 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// Run lifecycle stages recorded for the funnel report
+const (
+	runStarted       = "started"
+	runAnswered      = "answered"
+	runResultsViewed = "results"
+	runSubmitted     = "submitted"
+)
+
+// RunEvent is one step of a quiz run's lifecycle. Runs are identified only by their random
+// run ID, never by player.
+type RunEvent struct {
+	When     time.Time `json:"when"`
+	RunID    string    `json:"run_id"`
+	QuizType string    `json:"quiz_type"`
+	Mode     string    `json:"mode"`
+	Stage    string    `json:"stage"`
+	Step     int       `json:"step,omitempty"`  // answered: how many questions have now been answered
+	Total    int       `json:"total,omitempty"` // started: how many questions the run has
+}
+
+// Timestamp reports when the event happened
+func (e RunEvent) Timestamp() time.Time {
+	return e.When
+}
+
+// Funnel configuration
+const (
+	defaultFunnelFilename  = "funnel.jsonl"
+	defaultFunnelRetention = 90 * 24 * time.Hour
+	funnelPruneEvery       = time.Hour
+	funnelMaxSteps         = 10 // longer runs (e.g. practice) only show their first steps
+)
+
+var (
+	// runEvents is the global run lifecycle log; nil disables recording
+	runEvents *EventLog[RunEvent]
+
+	// funnelRetention is how long run events are kept, set by the -funnel-retention flag
+	funnelRetention = defaultFunnelRetention
+)
+
+// recordRunEvent logs a lifecycle stage of a run when funnel analytics are enabled. Legacy
+// runs without a run ID cannot be followed and are skipped.
+func recordRunEvent(state *QuizState, stage string, now time.Time) {
+	if runEvents == nil || state.RunID == "" {
+		return
+	}
+
+	event := RunEvent{
+		When:     now,
+		RunID:    state.RunID,
+		QuizType: state.QuizType,
+		Mode:     runMode(state),
+		Stage:    stage,
+	}
+	switch stage {
+	case runStarted:
+		event.Total = len(state.QuestionIDs)
+	case runAnswered:
+		event.Step = state.CurrentIndex
+	}
+	if err := runEvents.Record(event); err != nil {
+		log.Printf("Error recording run event: %v", err)
+	}
+}
+
+// pruneEventLog removes events older than retention now and then every interval until stop is closed
+func pruneEventLog[T timestamped](eventLog *EventLog[T], retention, every time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		removed, err := eventLog.Prune(time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("Error pruning %s: %v", eventLog.path, err)
+		} else if removed > 0 {
+			log.Printf("Pruned %d events older than %v from %s", removed, retention, eventLog.path)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// FunnelRow counts how far the runs started on one day (or on all days) got
+type FunnelRow struct {
+	Day           string // YYYY-MM-DD in UTC, or "" for the total over every day
+	QuizType      string
+	Started       int
+	Reached       []int // Reached[i] is how many runs answered at least i+1 questions
+	Completed     int   // runs that answered every question
+	ViewedResults int
+	Submitted     int
+}
+
+// Percent returns n as a percentage of the runs started
+func (r FunnelRow) Percent(n int) float64 {
+	if r.Started == 0 {
+		return 0
+	}
+	return float64(n) / float64(r.Started) * 100
+}
+
+// funnelRun is what the events say about one run
+type funnelRun struct {
+	started  RunEvent
+	answered int
+	results  bool
+	submit   bool
+}
+
+// buildFunnelReport aggregates run events per quiz type and start day, optionally restricted to
+// one quiz type and run mode. Events before since are ignored, as are runs whose start was not
+// recorded (they began before the retention window or before recording was enabled). Each
+// quiz type's total row comes first, followed by its days, newest first. It also returns how
+// many step columns the rows have.
+func buildFunnelReport(events []RunEvent, quizType, mode string, since time.Time) ([]FunnelRow, int) {
+	runs := make(map[string]*funnelRun)
+	for _, e := range events {
+		if e.When.Before(since) {
+			continue
+		}
+		run := runs[e.RunID]
+		if run == nil {
+			run = &funnelRun{}
+			runs[e.RunID] = run
+		}
+		switch e.Stage {
+		case runStarted:
+			run.started = e
+		case runAnswered:
+			run.answered = max(run.answered, e.Step)
+		case runResultsViewed:
+			run.results = true
+		case runSubmitted:
+			run.submit = true
+		}
+	}
+
+	var matching []*funnelRun
+	steps := 0
+	for _, run := range runs {
+		start := run.started
+		if start.Stage != runStarted || (quizType != "" && start.QuizType != quizType) || (mode != "" && start.Mode != mode) {
+			continue
+		}
+		matching = append(matching, run)
+		steps = max(steps, start.Total)
+	}
+	steps = min(steps, funnelMaxSteps)
+
+	rows := make(map[[2]string]*FunnelRow)
+	row := func(day, quizType string) *FunnelRow {
+		key := [2]string{day, quizType}
+		if rows[key] == nil {
+			rows[key] = &FunnelRow{Day: day, QuizType: quizType, Reached: make([]int, steps)}
+		}
+		return rows[key]
+	}
+	for _, run := range matching {
+		start := run.started
+		for _, r := range []*FunnelRow{row(start.When.UTC().Format("2006-01-02"), start.QuizType), row("", start.QuizType)} {
+			r.Started++
+			for i := 0; i < min(run.answered, steps); i++ {
+				r.Reached[i]++
+			}
+			if start.Total > 0 && run.answered >= start.Total {
+				r.Completed++
+			}
+			if run.results {
+				r.ViewedResults++
+			}
+			if run.submit {
+				r.Submitted++
+			}
+		}
+	}
+
+	report := make([]FunnelRow, 0, len(rows))
+	for _, r := range rows {
+		report = append(report, *r)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		if a.QuizType != b.QuizType {
+			return a.QuizType < b.QuizType
+		}
+		if (a.Day == "") != (b.Day == "") {
+			return a.Day == ""
+		}
+		return a.Day > b.Day
+	})
+	return report, steps
+}
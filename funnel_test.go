@@ -0,0 +1,152 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useRunLog records run events to a fresh log in a temporary directory for the rest of the test
+func useRunLog(t *testing.T) *EventLog[RunEvent] {
+	t.Helper()
+	eventLog, err := openEventLog[RunEvent](filepath.Join(t.TempDir(), "funnel.jsonl"))
+	if err != nil {
+		t.Fatalf("openEventLog failed: %v", err)
+	}
+	original := runEvents
+	runEvents = eventLog
+	t.Cleanup(func() {
+		runEvents = original
+		eventLog.Close()
+	})
+	return eventLog
+}
+
+func TestEventLog_Prune(t *testing.T) {
+	eventLog := useRunLog(t)
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+	for _, age := range []time.Duration{100 * 24 * time.Hour, 91 * 24 * time.Hour, time.Hour} {
+		eventLog.Record(RunEvent{When: now.Add(-age), RunID: "r", Stage: runStarted})
+	}
+
+	removed, err := eventLog.Prune(now.Add(-defaultFunnelRetention))
+	if err != nil || removed != 2 {
+		t.Fatalf("Prune() = %d, %v; want 2 removed", removed, err)
+	}
+	if removed, _ := eventLog.Prune(now.Add(-defaultFunnelRetention)); removed != 0 {
+		t.Errorf("second Prune() removed %d, want 0", removed)
+	}
+
+	// Recording continues into the pruned file
+	eventLog.Record(RunEvent{When: now, RunID: "r", Stage: runAnswered, Step: 1})
+	events, _ := eventLog.Events()
+	if len(events) != 2 || events[0].When != now.Add(-time.Hour) || events[1].Stage != runAnswered {
+		t.Errorf("events after pruning = %+v", events)
+	}
+}
+
+func TestBuildFunnelReport(t *testing.T) {
+	day1 := time.Date(2026, 5, 1, 23, 0, 0, 0, time.UTC)
+	day2 := day1.Add(2 * time.Hour)
+	// run builds the events of one run that started at start and got as far as answered
+	run := func(id, quizType, mode string, start time.Time, total, answered int, results, submitted bool) []RunEvent {
+		events := []RunEvent{{When: start, RunID: id, QuizType: quizType, Mode: mode, Stage: runStarted, Total: total}}
+		for step := 1; step <= answered; step++ {
+			events = append(events, RunEvent{When: start.Add(time.Minute), RunID: id, QuizType: quizType, Mode: mode, Stage: runAnswered, Step: step})
+		}
+		if results {
+			// Viewing results twice still counts the run once
+			events = append(events,
+				RunEvent{When: start.Add(time.Hour), RunID: id, Stage: runResultsViewed},
+				RunEvent{When: start.Add(time.Hour), RunID: id, Stage: runResultsViewed})
+		}
+		if submitted {
+			events = append(events, RunEvent{When: start.Add(time.Hour), RunID: id, Stage: runSubmitted})
+		}
+		return events
+	}
+
+	var events []RunEvent
+	events = append(events, run("a", "astrology", "quiz", day1, 3, 3, true, true)...)
+	events = append(events, run("b", "astrology", "quiz", day1, 3, 1, false, false)...)
+	events = append(events, run("c", "astrology", "quiz", day2, 3, 3, true, false)...)
+	events = append(events, run("d", "tarot", "practice", day2, 20, 12, true, false)...)
+	// A run whose start fell outside the window is left out, even though it was answered later
+	events = append(events, RunEvent{When: day2, RunID: "e", QuizType: "astrology", Mode: "quiz", Stage: runAnswered, Step: 2})
+
+	rows, steps := buildFunnelReport(events, "", "", day1.Add(-time.Hour))
+	if steps != funnelMaxSteps {
+		t.Errorf("steps = %d, want the cap %d", steps, funnelMaxSteps)
+	}
+	var keys []string
+	for _, r := range rows {
+		keys = append(keys, r.QuizType+" "+r.Day)
+	}
+	if want := []string{"astrology ", "astrology 2026-05-02", "astrology 2026-05-01", "tarot ", "tarot 2026-05-02"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("rows = %q, want %q", keys, want)
+	}
+
+	total := rows[0]
+	if total.Started != 3 || total.Completed != 2 || total.ViewedResults != 2 || total.Submitted != 1 {
+		t.Errorf("astrology total = %+v", total)
+	}
+	if want := []int{3, 2, 2, 0, 0, 0, 0, 0, 0, 0}; !reflect.DeepEqual(total.Reached, want) {
+		t.Errorf("astrology reached = %v, want %v", total.Reached, want)
+	}
+	if p := total.Percent(total.Completed); p < 66.6 || p > 66.7 {
+		t.Errorf("completion = %.1f%%, want 66.7%%", p)
+	}
+	if tarot := rows[3]; tarot.Reached[funnelMaxSteps-1] != 1 || tarot.Completed != 0 {
+		t.Errorf("tarot total = %+v", tarot)
+	}
+
+	rows, steps = buildFunnelReport(events, "astrology", "quiz", day2.Add(-time.Minute))
+	if steps != 3 || len(rows) != 2 || rows[0].Started != 1 {
+		t.Errorf("filtered report = %+v with %d steps", rows, steps)
+	}
+}
+
+func TestFunnel_RecordsRunLifecycle(t *testing.T) {
+	setupAccountsTest(t)
+	eventLog := useRunLog(t)
+	questionSets = map[string][]Question{
+		"astrology": {
+			{ID: "q1", Question: "Q1?", Choices: []string{"A", "B"}},
+			{ID: "q2", Question: "Q2?", Choices: []string{"A", "B"}},
+		},
+	}
+	setAdmins("alice")
+	t.Cleanup(func() { setAdmins("") })
+	send := newBrowser(loginCookie(t, "alice", "password123"))
+
+	send(http.MethodGet, "/quiz?new=1", nil)
+	send(http.MethodPost, "/quiz", url.Values{"step": {"0"}, "answer": {"0"}})
+	w := send(http.MethodPost, "/quiz", url.Values{"step": {"1"}, "answer": {"0"}})
+	results, _ := url.Parse(w.Header().Get("Location"))
+	send(http.MethodGet, results.String(), nil)
+	send(http.MethodPost, "/quiz/leaderboard", url.Values{"state": {results.Query().Get("state")}})
+
+	events, _ := eventLog.Events()
+	var stages []string
+	for _, e := range events {
+		stages = append(stages, e.Stage)
+		if e.RunID != events[0].RunID || e.RunID == "" {
+			t.Errorf("event %+v does not belong to the run", e)
+		}
+	}
+	if want := []string{runStarted, runAnswered, runAnswered, runResultsViewed, runSubmitted}; !reflect.DeepEqual(stages, want) {
+		t.Fatalf("stages = %v, want %v", stages, want)
+	}
+	if strings.Contains(events[0].RunID, "alice") {
+		t.Error("run IDs must not identify the player")
+	}
+
+	body := send(http.MethodGet, "/admin/funnel?type=astrology&days=7", nil).Body.String()
+	if !strings.Contains(body, "astrology (all days)") || !strings.Contains(body, "<td>1</td>") || !strings.Contains(body, "1 <span class=\"muted\">100%</span>") {
+		t.Error("funnel report should show the completed and submitted run")
+	}
+}
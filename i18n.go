@@ -0,0 +1,242 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Message catalogs, one JSON object of key -> message per language, named <lang>.json
+//
+//go:embed locales/*.json
+var localeFS embed.FS
+
+// Language negotiation settings
+const (
+	defaultLanguage  = "en"
+	langCookieName   = "lang"
+	langQueryParam   = "lang"
+	langCookieMaxAge = 365 * 24 * 60 * 60
+)
+
+// messageCatalogs maps a language code to its messages
+var messageCatalogs = mustLoadCatalogs()
+
+// mustLoadCatalogs parses the embedded catalogs; they ship with the binary, so a bad one is a build defect
+func mustLoadCatalogs() map[string]map[string]string {
+	catalogs, err := loadCatalogs()
+	if err != nil {
+		panic(err)
+	}
+	return catalogs
+}
+
+// loadCatalogs reads every embedded locales/<lang>.json file
+func loadCatalogs() (map[string]map[string]string, error) {
+	files, err := localeFS.ReadDir("locales")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read locales: %w", err)
+	}
+
+	catalogs := make(map[string]map[string]string, len(files))
+	for _, f := range files {
+		data, err := localeFS.ReadFile(path.Join("locales", f.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f.Name(), err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", f.Name(), err)
+		}
+		catalogs[strings.TrimSuffix(f.Name(), ".json")] = messages
+	}
+	if _, ok := catalogs[defaultLanguage]; !ok {
+		return nil, fmt.Errorf("missing catalog for default language %q", defaultLanguage)
+	}
+	return catalogs, nil
+}
+
+// supportedLanguages returns the languages with a message catalog, sorted by code
+func supportedLanguages() []string {
+	langs := make([]string, 0, len(messageCatalogs))
+	for lang := range messageCatalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// matchLanguage maps a language tag such as "es-MX" to a supported language code
+func matchLanguage(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if _, ok := messageCatalogs[tag]; ok {
+		return tag, true
+	}
+	if base, _, found := strings.Cut(tag, "-"); found {
+		if _, ok := messageCatalogs[base]; ok {
+			return base, true
+		}
+	}
+	return "", false
+}
+
+// parseAcceptLanguage returns the tags of an Accept-Language header ordered by preference.
+// Tags with q=0 are dropped; equal weights keep header order.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		tags = append(tags, weighted{tag, q})
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
+
+// requestLanguage picks the display language: a ?lang override (remembered in a cookie),
+// then the lang cookie, then the Accept-Language header, then the default language
+func requestLanguage(w http.ResponseWriter, r *http.Request) string {
+	if override := r.URL.Query().Get(langQueryParam); override != "" {
+		if lang, ok := matchLanguage(override); ok {
+			http.SetCookie(w, &http.Cookie{
+				Name:     langCookieName,
+				Value:    lang,
+				Path:     "/",
+				MaxAge:   langCookieMaxAge,
+				SameSite: http.SameSiteLaxMode,
+			})
+			return lang
+		}
+	}
+	if lang, ok := matchLanguage(cookieValue(r, langCookieName)); ok {
+		return lang
+	}
+	for _, tag := range parseAcceptLanguage(r.Header.Get("Accept-Language")) {
+		if tag == "*" {
+			break
+		}
+		if lang, ok := matchLanguage(tag); ok {
+			return lang
+		}
+	}
+	return defaultLanguage
+}
+
+// translate looks up a message in the language's catalog, falling back to the default
+// language and finally to the key itself, and formats it with any arguments
+func translate(lang, key string, args ...any) string {
+	msg, ok := messageCatalogs[lang][key]
+	if !ok {
+		msg, ok = messageCatalogs[defaultLanguage][key]
+	}
+	if !ok {
+		msg = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// formatNumber writes an integer with the language's thousands separator, e.g. 2,450 or 2.450
+func formatNumber(lang string, n int) string {
+	digits := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	separator := translate(lang, "number.group_separator")
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(separator)
+		}
+		b.WriteRune(d)
+	}
+	return sign + b.String()
+}
+
+// LanguageOption is one entry of the language switcher
+type LanguageOption struct {
+	Code    string
+	Name    string
+	Current bool
+}
+
+// translationFuncs returns the template functions for rendering a page in lang:
+// T translates a catalog key, number formats an integer, lang returns the language code and
+// languages lists the switcher options
+func translationFuncs(lang string) template.FuncMap {
+	return template.FuncMap{
+		"T": func(key string, args ...any) string {
+			return translate(lang, key, args...)
+		},
+		"number": func(n int) string {
+			return formatNumber(lang, n)
+		},
+		"lang": func() string {
+			return lang
+		},
+		"languages": func() []LanguageOption {
+			var options []LanguageOption
+			for _, code := range supportedLanguages() {
+				options = append(options, LanguageOption{
+					Code:    code,
+					Name:    translate(code, "lang.name"),
+					Current: code == lang,
+				})
+			}
+			return options
+		},
+	}
+}
+
+// localizeQuestion returns the question with its text, choices and explanation in lang.
+// Each field falls back to the default language when the translation leaves it empty;
+// translated choices are only used when they match the original choices one for one,
+// since answers are checked by index.
+func localizeQuestion(q Question, lang string) Question {
+	t, ok := q.Translations[lang]
+	if !ok {
+		return q
+	}
+	if t.Question != "" {
+		q.Question = t.Question
+	}
+	if len(t.Choices) == len(q.Choices) {
+		q.Choices = t.Choices
+	}
+	if t.Explanation != "" {
+		q.Explanation = t.Explanation
+	}
+	return q
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestFormatNumber tests thousands separators per language
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		lang string
+		n    int
+		want string
+	}{
+		{"en", 7, "7"},
+		{"en", 999, "999"},
+		{"en", 2450, "2,450"},
+		{"en", 1234567, "1,234,567"},
+		{"en", -1234, "-1,234"},
+		{"es", 2450, "2.450"},
+		{"es", 100000, "100.000"},
+	}
+	for _, tt := range tests {
+		if got := formatNumber(tt.lang, tt.n); got != tt.want {
+			t.Errorf("formatNumber(%q, %d) = %q, want %q", tt.lang, tt.n, got, tt.want)
+		}
+	}
+}
+
+// TestMessageCatalogs_Complete tests that every catalog translates exactly the default language's keys
+func TestMessageCatalogs_Complete(t *testing.T) {
+	keys := func(catalog map[string]string) []string {
+		var result []string
+		for k := range catalog {
+			result = append(result, k)
+		}
+		sort.Strings(result)
+		return result
+	}
+
+	want := keys(messageCatalogs[defaultLanguage])
+	for _, lang := range supportedLanguages() {
+		if got := keys(messageCatalogs[lang]); !reflect.DeepEqual(got, want) {
+			t.Errorf("catalog %s keys differ from %s:\ngot  %v\nwant %v", lang, defaultLanguage, got, want)
+		}
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected []string
+	}{
+		{"", []string{}},
+		{"es", []string{"es"}},
+		{"fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5", []string{"fr-CH", "fr", "en", "de", "*"}},
+		{"en;q=0.5, es-MX", []string{"es-MX", "en"}},
+		{"de;q=0, es;q=0.3", []string{"es"}},
+		{"es;q=abc, en", []string{"en"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := parseAcceptLanguage(tt.header); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseAcceptLanguage(%q) = %v, want %v", tt.header, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRequestLanguage(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		acceptLanguage string
+		cookie         string
+		expected       string
+		setsCookie     bool
+	}{
+		{"default", "/", "", "", "en", false},
+		{"accept-language", "/", "es-ES,es;q=0.9,en;q=0.8", "", "es", false},
+		{"unsupported accept-language", "/", "de-DE, ja", "", "en", false},
+		{"wildcard stops negotiation", "/", "de, *, es", "", "en", false},
+		{"cookie beats header", "/", "en", "es", "es", false},
+		{"query overrides everything", "/?lang=es", "en", "en", "es", true},
+		{"region in query", "/?lang=ES-mx", "", "", "es", true},
+		{"unsupported query is ignored", "/?lang=xx", "es", "", "es", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: langCookieName, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+
+			if got := requestLanguage(w, req); got != tt.expected {
+				t.Errorf("requestLanguage() = %q, want %q", got, tt.expected)
+			}
+			cookies := w.Result().Cookies()
+			if (len(cookies) > 0) != tt.setsCookie {
+				t.Errorf("cookie set = %v, want %v", len(cookies) > 0, tt.setsCookie)
+			}
+			if tt.setsCookie && cookies[0].Value != tt.expected {
+				t.Errorf("cookie value = %q, want %q", cookies[0].Value, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	original := messageCatalogs
+	defer func() { messageCatalogs = original }()
+	messageCatalogs = map[string]map[string]string{
+		"en": {"greeting": "Hello", "count": "%d of %d", "only.en": "English only"},
+		"es": {"greeting": "Hola", "count": "%d de %d"},
+	}
+
+	tests := []struct {
+		lang     string
+		key      string
+		args     []any
+		expected string
+	}{
+		{"es", "greeting", nil, "Hola"},
+		{"en", "greeting", nil, "Hello"},
+		{"es", "count", []any{1, 5}, "1 de 5"},
+		{"es", "only.en", nil, "English only"},
+		{"fr", "greeting", nil, "Hello"},
+		{"es", "missing.key", nil, "missing.key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang+"/"+tt.key, func(t *testing.T) {
+			if got := translate(tt.lang, tt.key, tt.args...); got != tt.expected {
+				t.Errorf("translate() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLocalizeQuestion(t *testing.T) {
+	q := Question{
+		ID:          "q1",
+		Question:    "Which planet rules Cancer?",
+		Choices:     []string{"Mars", "Moon"},
+		AnswerIndex: 1,
+		Explanation: "The Moon.",
+		Translations: map[string]QuestionTranslation{
+			"es": {Question: "¿Qué planeta rige Cáncer?", Choices: []string{"Marte", "Luna"}},
+			"fr": {Question: "Quelle planète ?", Choices: []string{"Mars"}},
+		},
+	}
+
+	tests := []struct {
+		lang            string
+		wantQuestion    string
+		wantChoices     []string
+		wantExplanation string
+	}{
+		{"en", "Which planet rules Cancer?", []string{"Mars", "Moon"}, "The Moon."},
+		{"es", "¿Qué planeta rige Cáncer?", []string{"Marte", "Luna"}, "The Moon."},
+		// Misaligned choices would change which index is correct, so the originals are kept
+		{"fr", "Quelle planète ?", []string{"Mars", "Moon"}, "The Moon."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			got := localizeQuestion(q, tt.lang)
+			if got.Question != tt.wantQuestion || !reflect.DeepEqual(got.Choices, tt.wantChoices) || got.Explanation != tt.wantExplanation {
+				t.Errorf("localizeQuestion() = %q %v %q", got.Question, got.Choices, got.Explanation)
+			}
+			if got.AnswerIndex != q.AnswerIndex || got.ID != q.ID {
+				t.Error("localizeQuestion() must not change the id or answer index")
+			}
+		})
+	}
+}
+
+func TestValidateTranslations(t *testing.T) {
+	q := Question{ID: "q1", Choices: []string{"A", "B"}, Translations: map[string]QuestionTranslation{
+		"es": {Choices: []string{"A"}},
+	}}
+	if err := validateTranslations(0, q); err == nil || !strings.Contains(err.Error(), "es choices") {
+		t.Errorf("expected a choice count error, got %v", err)
+	}
+
+	q.Translations["es"] = QuestionTranslation{Question: "Q-es"}
+	if err := validateTranslations(0, q); err != nil {
+		t.Errorf("translation without choices should be valid, got %v", err)
+	}
+}
+
+// TestLocalizedPages tests that the pages render in the negotiated language
+func TestLocalizedPages(t *testing.T) {
+	questionSets = map[string][]Question{
+		"astrology": {{ID: "q1", Question: "Which planet rules Cancer?", Choices: []string{"Mars", "Moon"}, AnswerIndex: 1,
+			Translations: map[string]QuestionTranslation{"es": {Question: "¿Qué planeta rige Cáncer?", Choices: []string{"Marte", "Luna"}}}}},
+	}
+	leaderboardManager = LeaderboardManager{entries: []LeaderboardEntry{}}
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		url      string
+		header   string
+		contains []string
+	}{
+		{"home in Spanish", homeHandler, "/?lang=es", "", []string{`<html lang="es">`, "Empezar quiz", `href="?lang=en"`}},
+		{"home in English", homeHandler, "/", "", []string{`<html lang="en">`, "Start Quiz", "Español"}},
+		{"quiz question in Spanish", quizGetHandler, "/quiz", "es", []string{"Pregunta 1 de 1", "¿Qué planeta rige Cáncer?", "Luna", "Tiempo: 20s", `data-label="Tiempo: {seconds}s"`}},
+		{"leaderboard in Spanish", leaderboardGetHandler, "/leaderboard", "es-AR", []string{"Mejores puntuaciones", "Aún no hay puntuaciones"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.header != "" {
+				req.Header.Set("Accept-Language", tt.header)
+			}
+			w := httptest.NewRecorder()
+			tt.handler(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("body missing %q", want)
+				}
+			}
+		})
+	}
+}
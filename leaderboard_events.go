@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Constants for live leaderboard streaming
+const (
+	leaderboardSubscriberBuffer = 4
+	MaxLeaderboardSubscribers   = 1000
+)
+
+// leaderboardRow is one row of a live leaderboard update, formatted as leaderboard.html shows it
+type leaderboardRow struct {
+	Rank       int    `json:"rank"`
+	Name       string `json:"name"`
+	Score      int    `json:"score"`
+	Total      int    `json:"total"`
+	Percentage string `json:"percentage"`
+	Date       string `json:"date"`
+	QuizType   string `json:"quiz_type"`
+	Verified   bool   `json:"verified"`
+	Duration   string `json:"duration"` // empty when the run's time is unknown
+	ID         string `json:"id,omitempty"`
+}
+
+// leaderboardUpdate is the payload of a "leaderboard" server-sent event
+type leaderboardUpdate struct {
+	Version uint64                      `json:"version"`
+	Entries []leaderboardRow            `json:"entries"` // the all-time board
+	Windows map[string][]leaderboardRow `json:"windows"` // every board, keyed by window name
+}
+
+// leaderboardEventsLocked returns the live update broadcaster, creating it on first use.
+// The caller must hold leaderboardManager.mu.
+func leaderboardEventsLocked() *broadcaster {
+	if leaderboardManager.events == nil {
+		leaderboardManager.events = newSnapshotBroadcaster(leaderboardSubscriberBuffer)
+	}
+	return leaderboardManager.events
+}
+
+// leaderboardSnapshotLocked builds an update event for the current top entries of every window.
+// The caller must hold leaderboardManager.mu.
+func leaderboardSnapshotLocked() (sseEvent, error) {
+	now := time.Now()
+	update := leaderboardUpdate{
+		Version: leaderboardManager.version,
+		Windows: make(map[string][]leaderboardRow, len(leaderboardWindows)),
+	}
+	for _, window := range leaderboardWindows {
+		update.Windows[window] = leaderboardRows(topEntriesLocked(window, "", MaxLeaderboardSize, now))
+	}
+	update.Entries = update.Windows[windowAllTime]
+
+	data, err := json.Marshal(update)
+	if err != nil {
+		return sseEvent{}, fmt.Errorf("failed to marshal leaderboard update: %w", err)
+	}
+	return sseEvent{Name: "leaderboard", Data: data}, nil
+}
+
+// leaderboardRows converts ranked entries into rows for live viewers
+func leaderboardRows(entries []LeaderboardEntry) []leaderboardRow {
+	rows := make([]leaderboardRow, len(entries))
+	for i, e := range rankLeaderboardEntries(entries) {
+		var percentage float64
+		if e.Total > 0 {
+			percentage = float64(e.Score) * 100.0 / float64(e.Total)
+		}
+		rows[i] = leaderboardRow{
+			Rank:       e.Rank,
+			Name:       e.Name,
+			Score:      e.Score,
+			Total:      e.Total,
+			Percentage: fmt.Sprintf("%.1f", percentage),
+			Date:       e.When.Format("Jan 02, 2006"),
+			Verified:   e.Verified,
+			QuizType:   e.QuizType,
+			Duration:   e.DurationText(),
+			ID:         e.ID,
+		}
+	}
+	return rows
+}
+
+// publishLeaderboardLocked pushes the current ranking to every live viewer.
+// The caller must hold leaderboardManager.mu.
+func publishLeaderboardLocked() {
+	ev, err := leaderboardSnapshotLocked()
+	if err != nil {
+		log.Printf("Error publishing leaderboard: %v", err)
+		return
+	}
+	leaderboardEventsLocked().publish(ev)
+}
+
+// subscribeLeaderboard registers a live viewer and returns the current snapshot taken atomically
+// with the subscription, so no update can slip in between
+func subscribeLeaderboard() (<-chan sseEvent, func(), sseEvent, error) {
+	leaderboardManager.mu.Lock()
+	defer leaderboardManager.mu.Unlock()
+
+	b := leaderboardEventsLocked()
+	if b.subscriberCount() >= MaxLeaderboardSubscribers {
+		return nil, nil, sseEvent{}, fmt.Errorf("too many live leaderboard viewers")
+	}
+
+	snapshot, err := leaderboardSnapshotLocked()
+	if err != nil {
+		return nil, nil, sseEvent{}, err
+	}
+	events, unsubscribe := b.subscribe()
+	return events, unsubscribe, snapshot, nil
+}
+
+// leaderboardEventsHandler handles GET /leaderboard/events, streaming ranking changes over SSE
+func leaderboardEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	events, unsubscribe, snapshot, err := subscribeLeaderboard()
+	if err != nil {
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		log.Printf("Rejected leaderboard subscriber: %v", err)
+		return
+	}
+	defer unsubscribe()
+
+	serveSSE(w, r, events, snapshot)
+}
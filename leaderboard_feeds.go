@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// leaderboardFeedMaxPerPage caps the JSON feed's ?per_page=
+const leaderboardFeedMaxPerPage = 100
+
+// leaderboardFeedEntry is one ranked entry in the JSON feed
+type leaderboardFeedEntry struct {
+	Rank       int       `json:"rank"`
+	ID         string    `json:"id,omitempty"`
+	Name       string    `json:"name"`
+	Score      int       `json:"score"`
+	Total      int       `json:"total"`
+	Percentage float64   `json:"percentage"`
+	DurationMS int64     `json:"duration_ms,omitempty"`
+	QuizType   string    `json:"quiz_type"`
+	Verified   bool      `json:"verified"`
+	When       time.Time `json:"when"`
+}
+
+// leaderboardFeedPage is one page of the JSON feed
+type leaderboardFeedPage struct {
+	Window   string                 `json:"window"`
+	QuizType string                 `json:"quiz_type,omitempty"`
+	Version  uint64                 `json:"version"`
+	Page     int                    `json:"page"`
+	PerPage  int                    `json:"per_page"`
+	Total    int                    `json:"total"` // entries ranked in the window
+	Pages    int                    `json:"pages"`
+	Next     string                 `json:"next,omitempty"`
+	Prev     string                 `json:"prev,omitempty"`
+	Entries  []leaderboardFeedEntry `json:"entries"`
+}
+
+// leaderboardFeedSnapshot is every entry a feed request ranks, with the state it reflects
+type leaderboardFeedSnapshot struct {
+	Window   string
+	QuizType string
+	Entries  []RankedEntry
+	Version  uint64
+	ETag     string
+	Modified time.Time
+}
+
+// leaderboardFeed derives the caching validators for the request's ?window= (default all-time)
+// and ?type= quiz type feed from the leaderboard's version and the query, then ranks every entry
+// in it. A client whose copy is still current is answered 304 Not Modified before anything is
+// ranked, and leaderboardFeed reports false.
+func leaderboardFeed(w http.ResponseWriter, r *http.Request, now time.Time) (leaderboardFeedSnapshot, bool) {
+	query := r.URL.Query()
+	feed := leaderboardFeedSnapshot{
+		Window:   parseLeaderboardWindow(query.Get("window")),
+		QuizType: query.Get("type"),
+	}
+
+	leaderboardManager.mu.Lock()
+	feed.Version = leaderboardManager.version
+	modified := leaderboardManager.modified
+
+	// Windows also change when a new day, week or month starts
+	start := leaderboardWindowStart(feed.Window, now)
+	feed.Modified = modified
+	if start.After(modified) {
+		feed.Modified = start
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s?%s|%d|%d", r.URL.Path, query.Encode(), modified.UnixNano(), start.Unix())))
+	feed.ETag = fmt.Sprintf(`"%d-%x"`, feed.Version, sum[:8])
+	if leaderboardFeedNotModified(r, feed) {
+		leaderboardManager.mu.Unlock()
+		setLeaderboardFeedHeaders(w, feed)
+		w.WriteHeader(http.StatusNotModified)
+		return feed, false
+	}
+
+	entries := topEntriesLocked(feed.Window, feed.QuizType, len(leaderboardManager.entries), now)
+	leaderboardManager.mu.Unlock()
+	feed.Entries = rankLeaderboardEntries(entries)
+	return feed, true
+}
+
+// leaderboardFeedNotModified reports whether a conditional request's copy of a feed is current:
+// its If-None-Match lists the feed's ETag or, without If-None-Match, its If-Modified-Since is
+// no earlier than the feed's last change
+func leaderboardFeedNotModified(r *http.Request, feed leaderboardFeedSnapshot) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == feed.ETag || tag == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !feed.Modified.IsZero() && !feed.Modified.Truncate(time.Second).After(since)
+}
+
+// setLeaderboardFeedHeaders sets a feed's validators and sharing headers. Feeds are public, so
+// any site may fetch them.
+func setLeaderboardFeedHeaders(w http.ResponseWriter, feed leaderboardFeedSnapshot) {
+	w.Header().Set("ETag", feed.ETag)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !feed.Modified.IsZero() {
+		w.Header().Set("Last-Modified", feed.Modified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// serveLeaderboardFeed writes a feed body with its validators
+func serveLeaderboardFeed(w http.ResponseWriter, r *http.Request, feed leaderboardFeedSnapshot, contentType string, body []byte) {
+	w.Header().Set("Content-Type", contentType)
+	setLeaderboardFeedHeaders(w, feed)
+	http.ServeContent(w, r, "", feed.Modified, bytes.NewReader(body))
+}
+
+// csvCell defuses a value a spreadsheet would read as a formula by prefixing it with a quote
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// entryPercentage returns an entry's score as a percentage rounded to one decimal place
+func entryPercentage(e LeaderboardEntry) float64 {
+	if e.Total <= 0 {
+		return 0
+	}
+	return math.Round(float64(e.Score)*1000/float64(e.Total)) / 10
+}
+
+// leaderboardJSONHandler handles GET requests to /leaderboard.json, one page of the ranking.
+// ?page= (from 1) and ?per_page= (up to 100) select the page.
+func leaderboardJSONHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	feed, ok := leaderboardFeed(w, r, time.Now())
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = MaxLeaderboardSize
+	}
+	perPage = min(perPage, leaderboardFeedMaxPerPage)
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	data := leaderboardFeedPage{
+		Window:   feed.Window,
+		QuizType: feed.QuizType,
+		Version:  feed.Version,
+		Page:     page,
+		PerPage:  perPage,
+		Total:    len(feed.Entries),
+		Pages:    (len(feed.Entries) + perPage - 1) / perPage,
+		Entries:  []leaderboardFeedEntry{},
+	}
+	pageURL := func(n int) string {
+		values := url.Values{}
+		for key, v := range query {
+			values[key] = v
+		}
+		values.Set("page", strconv.Itoa(n))
+		return r.URL.Path + "?" + values.Encode()
+	}
+	if page < data.Pages {
+		data.Next = pageURL(page + 1)
+	}
+	if page > 1 {
+		data.Prev = pageURL(min(page-1, max(data.Pages, 1)))
+	}
+	if start := (page - 1) * perPage; start < len(feed.Entries) {
+		for _, e := range feed.Entries[start:min(start+perPage, len(feed.Entries))] {
+			data.Entries = append(data.Entries, leaderboardFeedEntry{
+				Rank:       e.Rank,
+				ID:         e.ID,
+				Name:       e.Name,
+				Score:      e.Score,
+				Total:      e.Total,
+				Percentage: entryPercentage(e.LeaderboardEntry),
+				DurationMS: e.DurationMS,
+				QuizType:   e.QuizType,
+				Verified:   e.Verified,
+				When:       e.When,
+			})
+		}
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Error encoding leaderboard JSON: %v", err)
+		return
+	}
+	serveLeaderboardFeed(w, r, feed, "application/json", body)
+}
+
+// leaderboardCSVHandler handles GET requests to /leaderboard.csv, the whole ranking as a spreadsheet
+func leaderboardCSVHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	feed, ok := leaderboardFeed(w, r, time.Now())
+	if !ok {
+		return
+	}
+	var body bytes.Buffer
+	out := csv.NewWriter(&body)
+	out.Write([]string{"rank", "name", "score", "total", "percentage", "duration_seconds", "quiz_type", "verified", "when", "id"})
+	for _, e := range feed.Entries {
+		duration := ""
+		if e.DurationMS > 0 {
+			duration = strconv.FormatFloat(float64(e.DurationMS)/1000, 'f', 1, 64)
+		}
+		out.Write([]string{
+			strconv.Itoa(e.Rank), csvCell(e.Name),
+			strconv.Itoa(e.Score), strconv.Itoa(e.Total),
+			strconv.FormatFloat(entryPercentage(e.LeaderboardEntry), 'f', 1, 64),
+			duration, csvCell(e.QuizType), strconv.FormatBool(e.Verified),
+			e.When.UTC().Format(time.RFC3339), csvCell(e.ID),
+		})
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Error writing leaderboard CSV: %v", err)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="leaderboard-%s.csv"`, feed.Window))
+	serveLeaderboardFeed(w, r, feed, "text/csv; charset=utf-8", body.Bytes())
+}
+
+// atomFeed is an Atom 1.0 feed document (RFC 4287)
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomPerson  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// atomLink is an Atom link element
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+// atomPerson is an Atom author element
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+// atomEntry is one high score in the Atom feed
+type atomEntry struct {
+	Title   string     `xml:"title"`
+	ID      string     `xml:"id"`
+	Updated string     `xml:"updated"`
+	Link    atomLink   `xml:"link"`
+	Author  atomPerson `xml:"author"`
+	Summary string     `xml:"summary"`
+}
+
+// atomEntryID returns a permanent Atom ID for an entry; entries from before IDs were assigned
+// are identified by what they record
+func atomEntryID(e LeaderboardEntry) string {
+	if e.ID != "" {
+		return "urn:quiz-leaderboard:" + e.ID
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%d|%d", e.Name, e.QuizType, e.Score, e.Total, e.When.UnixNano())))
+	return fmt.Sprintf("urn:quiz-leaderboard:%x", sum[:8])
+}
+
+// leaderboardAtomHandler handles GET requests to /leaderboard.atom, the window's top scores as
+// an Atom feed with the newest first, so subscribers hear about each new high score
+func leaderboardAtomHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	feed, ok := leaderboardFeed(w, r, time.Now())
+	if !ok {
+		return
+	}
+	top := slices.Clone(feed.Entries[:min(len(feed.Entries), MaxLeaderboardSize)])
+	sort.SliceStable(top, func(i, j int) bool { return top[i].When.After(top[j].When) })
+
+	boardPath := "/leaderboard?" + url.Values{"window": {feed.Window}, "type": {feed.QuizType}}.Encode()
+	title := "High Scores"
+	if feed.QuizType != "" {
+		title = themeFor(feed.QuizType, defaultLanguage).Title + " " + title
+	}
+	updated := feed.Modified
+	if len(top) > 0 && top[0].When.After(updated) {
+		updated = top[0].When
+	}
+	doc := atomFeed{
+		Title:   title,
+		ID:      absoluteURL(r, r.URL.RequestURI()),
+		Updated: updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: absoluteURL(r, r.URL.RequestURI())},
+			{Rel: "alternate", Type: "text/html", Href: absoluteURL(r, boardPath)},
+		},
+		Author: atomPerson{Name: title},
+	}
+	for _, e := range top {
+		quizTitle := themeFor(e.QuizType, defaultLanguage).Title
+		link := "/leaderboard?" + url.Values{"window": {feed.Window}, "type": {e.QuizType}, "entry": {e.ID}}.Encode()
+		doc.Entries = append(doc.Entries, atomEntry{
+			Title:   fmt.Sprintf("%s scored %d/%d on %s", e.Name, e.Score, e.Total, quizTitle),
+			ID:      atomEntryID(e.LeaderboardEntry),
+			Updated: e.When.UTC().Format(time.RFC3339),
+			Link:    atomLink{Rel: "alternate", Type: "text/html", Href: absoluteURL(r, link)},
+			Author:  atomPerson{Name: e.Name},
+			Summary: fmt.Sprintf("#%d with %.1f%% on %s", e.Rank, entryPercentage(e.LeaderboardEntry), quizTitle),
+		})
+	}
+
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Error encoding leaderboard feed: %v", err)
+		return
+	}
+	serveLeaderboardFeed(w, r, feed, "application/atom+xml; charset=utf-8", append([]byte(xml.Header), body...))
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupFeedTest fills the leaderboard with n astrology scores from n down to 1 out of n,
+// plus one tarot score from last year
+func setupFeedTest(t *testing.T, n int) {
+	t.Helper()
+	setupAccountsTest(t)
+	leaderboardManager.mu.Lock()
+	defer leaderboardManager.mu.Unlock()
+	now := time.Now()
+	for i := 0; i < n; i++ {
+		insertLeaderboardEntryLocked(LeaderboardEntry{ID: fmt.Sprintf("a%d", i), Name: fmt.Sprintf("Player%d", i), Score: n - i, Total: n, QuizType: "astrology", When: now.Add(-time.Duration(i) * time.Minute)})
+	}
+	insertLeaderboardEntryLocked(LeaderboardEntry{ID: "t0", Name: "Oracle", Score: 1, Total: 1, QuizType: "tarot", When: now.AddDate(-1, 0, 0), DurationMS: 4200})
+	leaderboardManager.version = 7
+	leaderboardManager.modified = now.Add(-time.Hour)
+}
+
+// getFeed sends a GET request to a feed handler with optional extra headers
+func getFeed(handler http.HandlerFunc, target string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func TestLeaderboardJSONHandler(t *testing.T) {
+	setupFeedTest(t, 25)
+
+	tests := []struct {
+		name      string
+		target    string
+		wantTotal int
+		wantPages int
+		wantRanks []int
+		wantNext  string
+		wantPrev  string
+	}{
+		{"first page", "/leaderboard.json", 26, 2, []int{1, 2, 3}, "/leaderboard.json?page=2", ""},
+		{"last page", "/leaderboard.json?page=3&per_page=10", 26, 3, []int{21, 22, 23, 24, 25, 26}, "", "/leaderboard.json?page=2&per_page=10"},
+		{"past the end", "/leaderboard.json?page=9&per_page=10", 26, 3, nil, "", "/leaderboard.json?page=3&per_page=10"},
+		{"quiz type filter", "/leaderboard.json?type=tarot", 1, 1, []int{1}, "", ""},
+		{"window filter", "/leaderboard.json?window=month&per_page=5", 25, 5, []int{1, 2, 3, 4, 5}, "/leaderboard.json?page=2&per_page=5&window=month", ""},
+		{"invalid paging falls back to defaults", "/leaderboard.json?page=x&per_page=-3&type=astrology", 25, 2, []int{1, 2, 3}, "/leaderboard.json?page=2&per_page=-3&type=astrology", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := getFeed(leaderboardJSONHandler, tt.target)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+
+			var page leaderboardFeedPage
+			if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if page.Total != tt.wantTotal || page.Pages != tt.wantPages || page.Version != 7 {
+				t.Errorf("total %d, pages %d, version %d; want %d, %d, 7", page.Total, page.Pages, page.Version, tt.wantTotal, tt.wantPages)
+			}
+			if page.Next != tt.wantNext || page.Prev != tt.wantPrev {
+				t.Errorf("next %q, prev %q; want %q, %q", page.Next, page.Prev, tt.wantNext, tt.wantPrev)
+			}
+			if page.Entries == nil {
+				t.Error("expected an empty list rather than null")
+			}
+			for i, want := range tt.wantRanks {
+				if i >= len(page.Entries) || page.Entries[i].Rank != want {
+					t.Errorf("entries = %+v, want ranks starting %v", page.Entries, tt.wantRanks)
+					break
+				}
+			}
+			if tt.wantRanks == nil && len(page.Entries) != 0 {
+				t.Errorf("expected no entries, got %d", len(page.Entries))
+			}
+		})
+	}
+
+	// Per-page requests are capped
+	var page leaderboardFeedPage
+	json.Unmarshal(getFeed(leaderboardJSONHandler, "/leaderboard.json?per_page=1000").Body.Bytes(), &page)
+	if page.PerPage != leaderboardFeedMaxPerPage {
+		t.Errorf("per_page = %d, want %d", page.PerPage, leaderboardFeedMaxPerPage)
+	}
+
+	// Entries carry everything a dashboard needs
+	json.Unmarshal(getFeed(leaderboardJSONHandler, "/leaderboard.json?type=tarot").Body.Bytes(), &page)
+	if e := page.Entries[0]; e.ID != "t0" || e.Name != "Oracle" || e.Percentage != 100 || e.DurationMS != 4200 || e.QuizType != "tarot" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+}
+
+func TestLeaderboardCSVHandler(t *testing.T) {
+	setupFeedTest(t, 3)
+
+	w := getFeed(leaderboardCSVHandler, "/leaderboard.csv?window=week")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "leaderboard-week.csv") {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(rows) != 4 || rows[0][0] != "rank" || rows[1][1] != "Player0" || rows[3][0] != "3" || rows[3][4] != "33.3" {
+		t.Errorf("unexpected rows %v", rows)
+	}
+
+	rows, _ = csv.NewReader(getFeed(leaderboardCSVHandler, "/leaderboard.csv?type=tarot").Body).ReadAll()
+	if len(rows) != 2 || rows[1][1] != "Oracle" || rows[1][5] != "4.2" {
+		t.Errorf("unexpected tarot rows %v", rows)
+	}
+}
+
+func TestCSVCell(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Player", "Player"},
+		{"", ""},
+		{"=HYPERLINK(\"http://evil.example\")", "'=HYPERLINK(\"http://evil.example\")"},
+		{"+1", "'+1"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+		{"a=1", "a=1"},
+	}
+	for _, tt := range tests {
+		if got := csvCell(tt.value); got != tt.want {
+			t.Errorf("csvCell(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestLeaderboardCSVHandler_FormulaNames(t *testing.T) {
+	setupFeedTest(t, 0)
+	if err := saveScore("=cmd|' /C calc'!A0", 1, 1, "astrology"); err != nil {
+		t.Fatalf("saveScore() failed: %v", err)
+	}
+
+	rows, err := csv.NewReader(getFeed(leaderboardCSVHandler, "/leaderboard.csv?type=astrology").Body).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(rows) != 2 || rows[1][1] != "'=cmd|' /C calc'!A0" {
+		t.Errorf("expected the name defused with a quote, got %v", rows)
+	}
+}
+
+func TestLeaderboardAtomHandler(t *testing.T) {
+	setupFeedTest(t, 25)
+
+	w := getFeed(leaderboardAtomHandler, "/leaderboard.atom?type=astrology")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/atom+xml") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	var feed atomFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("invalid Atom: %v", err)
+	}
+	if len(feed.Entries) != MaxLeaderboardSize {
+		t.Fatalf("expected the top %d scores, got %d", MaxLeaderboardSize, len(feed.Entries))
+	}
+	if first := feed.Entries[0]; first.Title != "Player0 scored 25/25 on Astrology Quiz" || first.ID != "urn:quiz-leaderboard:a0" {
+		t.Errorf("expected the newest high score first, got %+v", first)
+	}
+	if !strings.HasPrefix(feed.Entries[0].Link.Href, "http://example.com/leaderboard?") || !strings.Contains(feed.Entries[0].Link.Href, "entry=a0") {
+		t.Errorf("expected an absolute link to the entry, got %q", feed.Entries[0].Link.Href)
+	}
+	if feed.ID != "http://example.com/leaderboard.atom?type=astrology" || feed.Links[0].Rel != "self" {
+		t.Errorf("unexpected feed identity %q %+v", feed.ID, feed.Links)
+	}
+}
+
+// TestLeaderboardFeeds_Caching tests conditional requests against the leaderboard version
+func TestLeaderboardFeeds_Caching(t *testing.T) {
+	setupFeedTest(t, 3)
+
+	handlers := map[string]http.HandlerFunc{
+		"/leaderboard.json": leaderboardJSONHandler,
+		"/leaderboard.csv":  leaderboardCSVHandler,
+		"/leaderboard.atom": leaderboardAtomHandler,
+	}
+	for path, handler := range handlers {
+		t.Run(path, func(t *testing.T) {
+			first := getFeed(handler, path)
+			etag, lastModified := first.Header().Get("ETag"), first.Header().Get("Last-Modified")
+			if etag == "" || lastModified == "" {
+				t.Fatalf("expected validators, got ETag %q and Last-Modified %q", etag, lastModified)
+			}
+			if first.Header().Get("Access-Control-Allow-Origin") != "*" {
+				t.Error("expected feeds to be readable from other sites")
+			}
+
+			if w := getFeed(handler, path, "If-None-Match", etag); w.Code != http.StatusNotModified {
+				t.Errorf("If-None-Match: expected 304, got %d", w.Code)
+			} else if w.Body.Len() != 0 || w.Header().Get("ETag") != etag || w.Header().Get("Access-Control-Allow-Origin") != "*" {
+				t.Errorf("If-None-Match: expected an empty 304 with the feed's headers, got %q %v", w.Body.String(), w.Header())
+			}
+			if w := getFeed(handler, path, "If-None-Match", `"0-stale", W/`+etag); w.Code != http.StatusNotModified {
+				t.Errorf("If-None-Match list: expected 304, got %d", w.Code)
+			}
+			if w := getFeed(handler, path, "If-Modified-Since", lastModified); w.Code != http.StatusNotModified {
+				t.Errorf("If-Modified-Since: expected 304, got %d", w.Code)
+			}
+			if w := getFeed(handler, path+"?window=day", "If-None-Match", etag); w.Code != http.StatusOK {
+				t.Errorf("another filter must not match the ETag, got %d", w.Code)
+			}
+		})
+	}
+
+	// A new score changes every feed
+	etag := getFeed(leaderboardJSONHandler, "/leaderboard.json").Header().Get("ETag")
+	if err := saveScore("Newcomer", 1, 3, "astrology"); err != nil {
+		t.Fatalf("saveScore() failed: %v", err)
+	}
+	w := getFeed(leaderboardJSONHandler, "/leaderboard.json", "If-None-Match", etag)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("expected a fresh response after a new score, got %d with ETag %q", w.Code, w.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+// Leaderboard time windows, each ranked separately with its own top MaxLeaderboardSize
+const (
+	windowAllTime = "all"
+	windowMonth   = "month"
+	windowWeek    = "week"
+	windowDay     = "day"
+)
+
+// leaderboardWindows lists the windows in the order the leaderboard page offers them
+var leaderboardWindows = []string{windowAllTime, windowMonth, windowWeek, windowDay}
+
+// Leaderboard storage policy
+const (
+	defaultLeaderboardHistory = 400 * 24 * time.Hour
+	minLeaderboardHistory     = 31 * 24 * time.Hour // the monthly board needs at least a month of history
+	MaxLeaderboardHistory     = 50000               // cap on stored entries, applied whenever the history is compacted
+)
+
+// leaderboardHistory is how long scores are kept, set by the -leaderboard-history flag. All-time
+// top scores and each quiz type's best score are kept regardless.
+var leaderboardHistory = defaultLeaderboardHistory
+
+// parseLeaderboardWindow returns the named window, defaulting to all-time for unknown names
+func parseLeaderboardWindow(name string) string {
+	if slices.Contains(leaderboardWindows, name) {
+		return name
+	}
+	return windowAllTime
+}
+
+// leaderboardWindowStart returns the earliest time a window includes, in now's time zone:
+// midnight today, Monday of this week or the first of this month. All-time has no start.
+func leaderboardWindowStart(window string, now time.Time) time.Time {
+	year, month, day := now.Date()
+	switch window {
+	case windowMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+	case windowWeek:
+		sinceMonday := (int(now.Weekday()) + 6) % 7
+		return time.Date(year, month, day-sinceMonday, 0, 0, 0, 0, now.Location())
+	case windowDay:
+		return time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	default:
+		return time.Time{}
+	}
+}
+
+// leaderboardWindowTop is a window's top MaxLeaderboardSize entries across quiz types, kept so
+// boards are read, and new entries placed on them, without scanning the history
+type leaderboardWindowTop struct {
+	start   time.Time // the window's start when the index was built
+	entries []LeaderboardEntry
+}
+
+// add places a new entry on the board and reports whether it made the top. Once the board is
+// full its lowest entry is the window's cutoff, and only entries ranked above it get in.
+func (top *leaderboardWindowTop) add(entry LeaderboardEntry) bool {
+	if entry.When.Before(top.start) {
+		return false
+	}
+	i := sort.Search(len(top.entries), func(i int) bool { return leaderboardRankLess(entry, top.entries[i]) })
+	if i >= MaxLeaderboardSize {
+		return false
+	}
+	top.entries = slices.Insert(top.entries, i, entry)
+	if len(top.entries) > MaxLeaderboardSize {
+		top.entries = top.entries[:MaxLeaderboardSize]
+	}
+	return true
+}
+
+// leaderboardWindowTopLocked returns a window's board, rebuilding it from the history when the
+// window has moved on (a new day, week or month) since it was built.
+// The caller must hold leaderboardManager.mu.
+func leaderboardWindowTopLocked(window string, now time.Time) *leaderboardWindowTop {
+	start := leaderboardWindowStart(window, now)
+	top := leaderboardManager.tops[window]
+	if top == nil || !top.start.Equal(start) {
+		if leaderboardManager.tops == nil {
+			leaderboardManager.tops = make(map[string]*leaderboardWindowTop)
+		}
+		top = &leaderboardWindowTop{start: start, entries: scanTopEntriesLocked(start, "", MaxLeaderboardSize)}
+		leaderboardManager.tops[window] = top
+	}
+	return top
+}
+
+// insertLeaderboardEntryLocked adds an entry in rank order, after any entries it ties with, and
+// reports whether it made one of the windows' boards built so far. History that is out of order
+// is re-sorted first. The caller must hold leaderboardManager.mu.
+func insertLeaderboardEntryLocked(entry LeaderboardEntry) bool {
+	entries := leaderboardManager.entries
+	if !sort.SliceIsSorted(entries, func(i, j int) bool { return leaderboardRankLess(entries[i], entries[j]) }) {
+		sort.SliceStable(entries, func(i, j int) bool { return leaderboardRankLess(entries[i], entries[j]) })
+		leaderboardManager.tops = nil
+	}
+	i := sort.Search(len(entries), func(i int) bool { return leaderboardRankLess(entry, entries[i]) })
+	leaderboardManager.entries = slices.Insert(entries, i, entry)
+
+	onBoard := false
+	for _, top := range leaderboardManager.tops {
+		if top.add(entry) {
+			onBoard = true
+		}
+	}
+	return onBoard
+}
+
+// topEntriesLocked returns up to n of a window's best entries, optionally for one quiz type.
+// The boards across quiz types are kept; anything else is scanned.
+// The caller must hold leaderboardManager.mu.
+func topEntriesLocked(window, quizType string, n int, now time.Time) []LeaderboardEntry {
+	if quizType == "" && n <= MaxLeaderboardSize {
+		top := leaderboardWindowTopLocked(window, now).entries
+		return append([]LeaderboardEntry{}, top[:min(n, len(top))]...)
+	}
+	return scanTopEntriesLocked(leaderboardWindowStart(window, now), quizType, n)
+}
+
+// scanTopEntriesLocked returns up to n of the best entries since start, optionally for one quiz
+// type. Entries are kept in rank order, so the scan stops at the nth match.
+// The caller must hold leaderboardManager.mu.
+func scanTopEntriesLocked(start time.Time, quizType string, n int) []LeaderboardEntry {
+	top := []LeaderboardEntry{}
+	for _, e := range leaderboardManager.entries {
+		if len(top) == n {
+			break
+		}
+		if e.When.Before(start) || (quizType != "" && e.QuizType != quizType) {
+			continue
+		}
+		top = append(top, e)
+	}
+	return top
+}
+
+// topLeaderboard returns up to n of a window's best entries, optionally for one quiz type
+func topLeaderboard(window, quizType string, n int, now time.Time) []LeaderboardEntry {
+	leaderboardManager.mu.Lock()
+	defer leaderboardManager.mu.Unlock()
+
+	return topEntriesLocked(window, quizType, n, now)
+}
+
+// pruneLeaderboardLocked applies the storage policy: entries older than leaderboardHistory are
+// dropped, and beyond MaxLeaderboardHistory entries the oldest go first. The all-time top
+// MaxLeaderboardSize and each quiz type's best entry are always kept.
+// The caller must hold leaderboardManager.mu.
+func pruneLeaderboardLocked(now time.Time) {
+	cutoff := now.Add(-max(leaderboardHistory, minLeaderboardHistory))
+	entries := leaderboardManager.entries
+
+	protected := make([]bool, len(entries))
+	seenType := make(map[string]bool)
+	var removable []int
+	for i, e := range entries {
+		protected[i] = i < MaxLeaderboardSize || !seenType[e.QuizType]
+		seenType[e.QuizType] = true
+		if !protected[i] {
+			removable = append(removable, i)
+		}
+	}
+
+	remove := make(map[int]bool)
+	for _, i := range removable {
+		if entries[i].When.Before(cutoff) {
+			remove[i] = true
+		}
+	}
+	kept := len(entries) - len(remove)
+	if kept > MaxLeaderboardHistory {
+		sort.SliceStable(removable, func(a, b int) bool { return entries[removable[a]].When.Before(entries[removable[b]].When) })
+		for _, i := range removable {
+			if kept <= MaxLeaderboardHistory {
+				break
+			}
+			if !remove[i] {
+				remove[i] = true
+				kept--
+			}
+		}
+	}
+	if len(remove) == 0 {
+		return
+	}
+
+	pruned := make([]LeaderboardEntry, 0, kept)
+	for i, e := range entries {
+		if !remove[i] {
+			pruned = append(pruned, e)
+		}
+	}
+	leaderboardManager.entries = pruned
+	leaderboardManager.tops = nil
+}
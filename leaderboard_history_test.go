@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLeaderboardWindowStart tests the calendar boundaries of each window
+func TestLeaderboardWindowStart(t *testing.T) {
+	// Thursday, March 14 2024
+	now := time.Date(2024, 3, 14, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		window string
+		now    time.Time
+		want   time.Time
+	}{
+		{windowAllTime, now, time.Time{}},
+		{windowMonth, now, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{windowWeek, now, time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{windowDay, now, time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC)},
+		// Sunday belongs to the week that started the previous Monday, across a month boundary
+		{windowWeek, time.Date(2024, 3, 3, 9, 0, 0, 0, time.UTC), time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC)},
+		{windowWeek, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.window+" "+tt.now.Format(time.DateOnly), func(t *testing.T) {
+			if got := leaderboardWindowStart(tt.window, tt.now); !got.Equal(tt.want) {
+				t.Errorf("leaderboardWindowStart(%q) = %v, want %v", tt.window, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseLeaderboardWindow tests that unknown windows fall back to all-time
+func TestParseLeaderboardWindow(t *testing.T) {
+	tests := map[string]string{
+		"":      windowAllTime,
+		"all":   windowAllTime,
+		"month": windowMonth,
+		"week":  windowWeek,
+		"day":   windowDay,
+		"year":  windowAllTime,
+	}
+	for name, want := range tests {
+		if got := parseLeaderboardWindow(name); got != want {
+			t.Errorf("parseLeaderboardWindow(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// TestTopLeaderboard_Windows tests that each window ranks its own entries with its own top N
+func TestTopLeaderboard_Windows(t *testing.T) {
+	now := time.Date(2024, 3, 14, 15, 30, 0, 0, time.UTC)
+	leaderboardManager = LeaderboardManager{}
+
+	// Old perfect scores fill the all-time board; lower recent scores rank in the shorter windows
+	leaderboardManager.mu.Lock()
+	for i := 0; i < MaxLeaderboardSize; i++ {
+		insertLeaderboardEntryLocked(LeaderboardEntry{Name: fmt.Sprintf("Old%d", i), Score: 10, Total: 10, QuizType: "astrology", When: now.AddDate(-1, 0, i)})
+	}
+	insertLeaderboardEntryLocked(LeaderboardEntry{Name: "Month", Score: 8, Total: 10, QuizType: "astrology", When: time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC)})
+	insertLeaderboardEntryLocked(LeaderboardEntry{Name: "Week", Score: 6, Total: 10, QuizType: "tarot", When: time.Date(2024, 3, 12, 12, 0, 0, 0, time.UTC)})
+	insertLeaderboardEntryLocked(LeaderboardEntry{Name: "Today", Score: 4, Total: 10, QuizType: "astrology", When: now.Add(-time.Hour)})
+	leaderboardManager.mu.Unlock()
+
+	names := func(entries []LeaderboardEntry) string {
+		var out []string
+		for _, e := range entries {
+			out = append(out, e.Name)
+		}
+		return strings.Join(out, ",")
+	}
+
+	tests := []struct {
+		window   string
+		quizType string
+		n        int
+		want     string
+	}{
+		{windowMonth, "", MaxLeaderboardSize, "Month,Week,Today"},
+		{windowWeek, "", MaxLeaderboardSize, "Week,Today"},
+		{windowDay, "", MaxLeaderboardSize, "Today"},
+		{windowMonth, "astrology", MaxLeaderboardSize, "Month,Today"},
+		{windowMonth, "", 2, "Month,Week"},
+		{windowAllTime, "tarot", 1, "Week"},
+	}
+	for _, tt := range tests {
+		if got := names(topLeaderboard(tt.window, tt.quizType, tt.n, now)); got != tt.want {
+			t.Errorf("topLeaderboard(%q, %q, %d) = %s, want %s", tt.window, tt.quizType, tt.n, got, tt.want)
+		}
+	}
+
+	allTime := topLeaderboard(windowAllTime, "", MaxLeaderboardSize, now)
+	if len(allTime) != MaxLeaderboardSize || allTime[0].Name != "Old0" || allTime[MaxLeaderboardSize-1].Name != fmt.Sprintf("Old%d", MaxLeaderboardSize-1) {
+		t.Errorf("expected the all-time board to hold the old perfect scores, got %s", names(allTime))
+	}
+}
+
+// TestLeaderboardWindowTop tests that each window's kept board matches a scan of the history as
+// entries arrive, and is rebuilt when the window moves on
+func TestLeaderboardWindowTop(t *testing.T) {
+	now := time.Date(2024, 3, 14, 15, 30, 0, 0, time.UTC)
+	leaderboardManager = LeaderboardManager{}
+
+	matchesScan := func(when time.Time) {
+		t.Helper()
+		for _, window := range leaderboardWindows {
+			kept := topEntriesLocked(window, "", MaxLeaderboardSize, when)
+			scanned := scanTopEntriesLocked(leaderboardWindowStart(window, when), "", MaxLeaderboardSize)
+			if !slices.Equal(kept, scanned) {
+				t.Fatalf("%s board = %+v, want %+v", window, kept, scanned)
+			}
+		}
+	}
+
+	leaderboardManager.mu.Lock()
+	defer leaderboardManager.mu.Unlock()
+	for i := 0; i < 5*MaxLeaderboardSize; i++ {
+		for _, window := range leaderboardWindows {
+			leaderboardWindowTopLocked(window, now)
+		}
+		entry := LeaderboardEntry{
+			ID:       strconv.Itoa(i),
+			Score:    (i * 7) % 11,
+			Total:    10,
+			QuizType: "astrology",
+			When:     now.Add(-time.Duration(i*37%200) * time.Hour),
+		}
+		top := topEntriesLocked(windowDay, "", MaxLeaderboardSize, now)
+		cutoff := len(top) == MaxLeaderboardSize && !leaderboardRankLess(entry, top[len(top)-1])
+		madeDay := !entry.When.Before(leaderboardWindowStart(windowDay, now)) && !cutoff
+
+		if onBoard := insertLeaderboardEntryLocked(entry); madeDay && !onBoard {
+			t.Fatalf("entry %d made the day's board but was not reported", i)
+		}
+		matchesScan(now)
+	}
+
+	// A new day starts an empty daily board
+	tomorrow := now.Add(24 * time.Hour)
+	matchesScan(tomorrow)
+	if top := topEntriesLocked(windowDay, "", MaxLeaderboardSize, tomorrow); len(top) != 0 {
+		t.Errorf("expected an empty board on a new day, got %d entries", len(top))
+	}
+}
+
+// TestPruneLeaderboard tests the storage policy keeps recent scores and all-time bests
+func TestPruneLeaderboard(t *testing.T) {
+	now := time.Date(2024, 3, 14, 15, 30, 0, 0, time.UTC)
+	originalHistory := leaderboardHistory
+	defer func() { leaderboardHistory = originalHistory }()
+	leaderboardHistory = 60 * 24 * time.Hour
+
+	leaderboardManager = LeaderboardManager{}
+	leaderboardManager.mu.Lock()
+	defer leaderboardManager.mu.Unlock()
+
+	// The all-time top scores are old and must survive
+	for i := 0; i < MaxLeaderboardSize; i++ {
+		insertLeaderboardEntryLocked(LeaderboardEntry{Name: fmt.Sprintf("Top%d", i), Score: 10, Total: 10, QuizType: "astrology", When: now.AddDate(-2, 0, 0)})
+	}
+	insertLeaderboardEntryLocked(LeaderboardEntry{Name: "Expired", Score: 5, Total: 10, QuizType: "astrology", When: now.AddDate(0, 0, -61)})
+	insertLeaderboardEntryLocked(LeaderboardEntry{Name: "TarotBest", Score: 1, Total: 10, QuizType: "tarot", When: now.AddDate(-1, 0, 0)})
+	insertLeaderboardEntryLocked(LeaderboardEntry{Name: "Recent", Score: 2, Total: 10, QuizType: "astrology", When: now.AddDate(0, 0, -59)})
+
+	pruneLeaderboardLocked(now)
+
+	kept := make(map[string]bool)
+	for _, e := range leaderboardManager.entries {
+		kept[e.Name] = true
+	}
+	if len(leaderboardManager.entries) != MaxLeaderboardSize+2 {
+		t.Errorf("expected %d entries after pruning, got %d", MaxLeaderboardSize+2, len(leaderboardManager.entries))
+	}
+	for _, name := range []string{"Top0", fmt.Sprintf("Top%d", MaxLeaderboardSize-1), "TarotBest", "Recent"} {
+		if !kept[name] {
+			t.Errorf("expected %s to be kept", name)
+		}
+	}
+	if kept["Expired"] {
+		t.Error("expected the expired entry to be pruned")
+	}
+
+	// History is never shorter than the monthly board needs
+	leaderboardHistory = time.Hour
+	insertLeaderboardEntryLocked(LeaderboardEntry{Name: "ThisMonth", Score: 2, Total: 10, QuizType: "astrology", When: now.AddDate(0, 0, -20)})
+	pruneLeaderboardLocked(now)
+	kept = make(map[string]bool)
+	for _, e := range leaderboardManager.entries {
+		kept[e.Name] = true
+	}
+	if !kept["ThisMonth"] || kept["Recent"] {
+		t.Errorf("expected only the last %v of history to be kept, got %v", minLeaderboardHistory, kept)
+	}
+}
+
+// TestLeaderboardGetHandler_Window tests that ?window= selects the board shown
+func TestLeaderboardGetHandler_Window(t *testing.T) {
+	now := time.Now()
+	leaderboardManager = LeaderboardManager{entries: []LeaderboardEntry{
+		{Name: "Legend", Score: 10, Total: 10, QuizType: "astrology", When: now.AddDate(-2, 0, 0)},
+		{Name: "Newcomer", Score: 3, Total: 10, QuizType: "astrology", When: now},
+	}}
+
+	tests := []struct {
+		name       string
+		target     string
+		wantBody   []string
+		unwantBody []string
+	}{
+		{"all time by default", "/leaderboard", []string{"Legend", "Newcomer", `class="active">All Time`}, nil},
+		{"today", "/leaderboard?window=day", []string{"Newcomer", `class="active">Today`, `const windowName = "day"`}, []string{"Legend"}},
+		{"unknown window", "/leaderboard?window=decade", []string{"Legend", `class="active">All Time`}, nil},
+		{"tabs keep the quiz type", "/leaderboard?type=tarot", []string{`href="/leaderboard?type=tarot&window=week"`}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			rr := httptest.NewRecorder()
+			leaderboardGetHandler(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", rr.Code)
+			}
+			body := rr.Body.String()
+			for _, want := range tt.wantBody {
+				if !strings.Contains(body, want) {
+					t.Errorf("expected body to contain %q", want)
+				}
+			}
+			for _, unwant := range tt.unwantBody {
+				if strings.Contains(body, unwant) {
+					t.Errorf("expected body not to contain %q", unwant)
+				}
+			}
+		})
+	}
+}
+
+// TestQuizRun_RecordsDuration tests that a finished run's signed timestamps reach the leaderboard
+func TestQuizRun_RecordsDuration(t *testing.T) {
+	setupAccountsTest(t)
+	practiceQuestions()
+	send := newBrowser()
+
+	send(http.MethodGet, "/quiz?new=1", nil)
+	var w *httptest.ResponseRecorder
+	for step := 0; step < NumQuestions; step++ {
+		w = send(http.MethodPost, "/quiz", url.Values{"step": {strconv.Itoa(step)}, "answer": {"0"}})
+	}
+	results, _ := url.Parse(w.Header().Get("Location"))
+	state, ok := decodeStateToken(results.Query().Get("state"))
+	if !ok {
+		t.Fatalf("expected a results token, got %s", results)
+	}
+	if state.StartedAt == 0 || state.FinishedAt < state.StartedAt {
+		t.Fatalf("expected start and finish timestamps, got %d and %d", state.StartedAt, state.FinishedAt)
+	}
+
+	// Pretend the run took 42.3 seconds
+	state.StartedAt = state.FinishedAt - 42300
+	token, err := encodeStateToken(*state)
+	if err != nil {
+		t.Fatalf("encodeStateToken() failed: %v", err)
+	}
+	w = send(http.MethodPost, "/quiz/leaderboard", url.Values{"state": {token}, "name": {"Speedy"}})
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected a redirect to the leaderboard, got %d", w.Code)
+	}
+
+	entries := getLeaderboard()
+	if len(entries) != 1 || entries[0].DurationMS != 42300 {
+		t.Fatalf("expected one entry lasting 42300ms, got %+v", entries)
+	}
+	if body := send(http.MethodGet, "/leaderboard", nil).Body.String(); !strings.Contains(body, `<td class="time">42.3s</td>`) {
+		t.Error("expected the leaderboard to show the run's time")
+	}
+}
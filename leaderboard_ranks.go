@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Tie-break orders for equal scores, selected by the -leaderboard-tiebreak flag
+const (
+	tieBreakDuration  = "duration"  // fastest run first, then earliest submission
+	tieBreakSubmitted = "submitted" // earliest submission first
+)
+
+// Rank numbering styles for tied entries, selected by the -leaderboard-ranks flag
+const (
+	rankCompetition = "competition" // ties share a rank and the next rank is skipped: 1, 2, 2, 4
+	rankDense       = "dense"       // ties share a rank and no rank is skipped: 1, 2, 2, 3
+)
+
+var (
+	// leaderboardTieBreak orders entries with equal scores
+	leaderboardTieBreak = tieBreakDuration
+
+	// leaderboardRankStyle numbers tied entries on the leaderboard
+	leaderboardRankStyle = rankCompetition
+)
+
+// setLeaderboardTieBreak selects how equal scores are ordered
+func setLeaderboardTieBreak(name string) error {
+	switch name {
+	case tieBreakDuration, tieBreakSubmitted:
+		leaderboardTieBreak = name
+		return nil
+	default:
+		return fmt.Errorf("unknown tie-break %q (want %s or %s)", name, tieBreakDuration, tieBreakSubmitted)
+	}
+}
+
+// setLeaderboardRankStyle selects how tied entries are numbered
+func setLeaderboardRankStyle(name string) error {
+	switch name {
+	case rankCompetition, rankDense:
+		leaderboardRankStyle = name
+		return nil
+	default:
+		return fmt.Errorf("unknown rank style %q (want %s or %s)", name, rankCompetition, rankDense)
+	}
+}
+
+// compareLeaderboardEntries orders two entries by every ranking key: percentage of the quiz
+// answered correctly (higher first), then absolute score (higher first), then the tie-break's
+// run duration (shorter first, unknown durations last). It returns 0 for entries that tie and so
+// share a rank; submission time only orders tied entries for display.
+func compareLeaderboardEntries(a, b LeaderboardEntry) int {
+	// Compare Score/Total without rounding; an entry without a total counts as 0%
+	aScore, aTotal := int64(a.Score), int64(a.Total)
+	bScore, bTotal := int64(b.Score), int64(b.Total)
+	if aTotal <= 0 {
+		aScore, aTotal = 0, 1
+	}
+	if bTotal <= 0 {
+		bScore, bTotal = 0, 1
+	}
+	if left, right := aScore*bTotal, bScore*aTotal; left != right {
+		if left > right {
+			return -1
+		}
+		return 1
+	}
+
+	if a.Score != b.Score {
+		if a.Score > b.Score {
+			return -1
+		}
+		return 1
+	}
+
+	if leaderboardTieBreak == tieBreakDuration && a.DurationMS != b.DurationMS {
+		if a.DurationMS == 0 || b.DurationMS == 0 {
+			if b.DurationMS == 0 {
+				return -1
+			}
+			return 1
+		}
+		if a.DurationMS < b.DurationMS {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// leaderboardRankLess orders entries by compareLeaderboardEntries, then by time (earlier first)
+func leaderboardRankLess(a, b LeaderboardEntry) bool {
+	if c := compareLeaderboardEntries(a, b); c != 0 {
+		return c < 0
+	}
+	return a.When.Before(b.When)
+}
+
+// RankedEntry is a leaderboard entry with its displayed rank number
+type RankedEntry struct {
+	Rank      int
+	Highlight bool // the entry the viewer just submitted
+	LeaderboardEntry
+}
+
+// nextLeaderboardRank numbers the entry at 1-based position in a ranked list, given the entry
+// before it and that entry's rank, in the configured style
+func nextLeaderboardRank(previous, e LeaderboardEntry, previousRank, position int) int {
+	switch {
+	case position == 1:
+		return 1
+	case compareLeaderboardEntries(previous, e) == 0:
+		return previousRank
+	case leaderboardRankStyle == rankDense:
+		return previousRank + 1
+	default:
+		return position
+	}
+}
+
+// rankLeaderboardEntries numbers entries that are already in rank order, giving tied entries
+// the same rank in the configured style
+func rankLeaderboardEntries(entries []LeaderboardEntry) []RankedEntry {
+	ranked := make([]RankedEntry, len(entries))
+	for i, e := range entries {
+		rank := 1
+		if i > 0 {
+			rank = nextLeaderboardRank(entries[i-1], e, ranked[i-1].Rank, i+1)
+		}
+		ranked[i] = RankedEntry{Rank: rank, LeaderboardEntry: e}
+	}
+	return ranked
+}
+
+// LeaderboardPosition is where one entry stands on a window's board
+type LeaderboardPosition struct {
+	Entry     RankedEntry
+	Of        int           // how many entries the window ranks
+	Neighbors []RankedEntry // the entries around it, itself included, in rank order
+}
+
+// leaderboardPosition finds an entry by ID on a window's board (across every quiz type) with up
+// to radius neighbours on each side. It reports false when the entry is not in the window or
+// is no longer stored.
+func leaderboardPosition(id, window string, now time.Time, radius int) (LeaderboardPosition, bool) {
+	leaderboardManager.mu.Lock()
+	defer leaderboardManager.mu.Unlock()
+
+	if id == "" {
+		return LeaderboardPosition{}, false
+	}
+
+	// One pass in rank order: keep the last radius entries until the target turns up, then
+	// take radius more and keep counting
+	start := leaderboardWindowStart(window, now)
+	var pos LeaderboardPosition
+	var before []RankedEntry
+	var previous LeaderboardEntry
+	found, after, rank := false, 0, 0
+	for _, e := range leaderboardManager.entries {
+		if e.When.Before(start) {
+			continue
+		}
+		pos.Of++
+		rank = nextLeaderboardRank(previous, e, rank, pos.Of)
+		previous = e
+		ranked := RankedEntry{Rank: rank, LeaderboardEntry: e}
+
+		switch {
+		case !found && e.ID == id:
+			found = true
+			ranked.Highlight = true
+			pos.Entry = ranked
+			pos.Neighbors = append(slices.Clone(before), ranked)
+		case !found:
+			before = append(before, ranked)
+			if len(before) > radius {
+				before = before[1:]
+			}
+		case after < radius:
+			pos.Neighbors = append(pos.Neighbors, ranked)
+			after++
+		}
+	}
+	return pos, found
+}
+
+// migrateLeaderboard re-ranks entries loaded from a leaderboard file written under an older
+// ranking (raw score, or a different tie-break) and reports whether their order changed
+func migrateLeaderboard(entries []LeaderboardEntry) ([]LeaderboardEntry, bool) {
+	ranked := slices.Clone(entries)
+	sort.SliceStable(ranked, func(i, j int) bool { return leaderboardRankLess(ranked[i], ranked[j]) })
+	return ranked, !slices.Equal(ranked, entries)
+}
+
+// rewriteMigratedLeaderboardLocked persists re-ranked entries, keeping the original file as a
+// backup. The caller must hold leaderboardManager.mu.
+func rewriteMigratedLeaderboardLocked(original []byte) error {
+	if err := os.WriteFile(leaderboardFilename+".bak", original, 0644); err != nil {
+		return fmt.Errorf("failed to back up leaderboard file: %w", err)
+	}
+	if err := saveLeaderboard(); err != nil {
+		return err
+	}
+	log.Printf("Re-ranked %d leaderboard entries by percentage; the previous file is in %s.bak", len(leaderboardManager.entries), leaderboardFilename)
+	return nil
+}
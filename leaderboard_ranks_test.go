@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLeaderboardRankLess_TieBreak tests both orders for equal scores
+func TestLeaderboardRankLess_TieBreak(t *testing.T) {
+	original := leaderboardTieBreak
+	defer func() { leaderboardTieBreak = original }()
+
+	early := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	late := early.Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		tieBreak string
+		a, b     LeaderboardEntry
+		want     bool
+	}{
+		{"higher score wins regardless", tieBreakDuration, LeaderboardEntry{Score: 3, DurationMS: 90000, When: late}, LeaderboardEntry{Score: 2, DurationMS: 1000, When: early}, true},
+		{"faster run wins a tie", tieBreakDuration, LeaderboardEntry{Score: 3, DurationMS: 20000, When: late}, LeaderboardEntry{Score: 3, DurationMS: 30000, When: early}, true},
+		{"slower run loses a tie", tieBreakDuration, LeaderboardEntry{Score: 3, DurationMS: 30000, When: early}, LeaderboardEntry{Score: 3, DurationMS: 20000, When: late}, false},
+		{"unknown duration ranks after timed runs", tieBreakDuration, LeaderboardEntry{Score: 3, When: early}, LeaderboardEntry{Score: 3, DurationMS: 60000, When: late}, false},
+		{"timed run ranks before unknown duration", tieBreakDuration, LeaderboardEntry{Score: 3, DurationMS: 60000, When: late}, LeaderboardEntry{Score: 3, When: early}, true},
+		{"equal durations fall back to submission time", tieBreakDuration, LeaderboardEntry{Score: 3, DurationMS: 5000, When: early}, LeaderboardEntry{Score: 3, DurationMS: 5000, When: late}, true},
+		{"submitted order ignores duration", tieBreakSubmitted, LeaderboardEntry{Score: 3, DurationMS: 30000, When: early}, LeaderboardEntry{Score: 3, DurationMS: 20000, When: late}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leaderboardTieBreak = tt.tieBreak
+			if got := leaderboardRankLess(tt.a, tt.b); got != tt.want {
+				t.Errorf("leaderboardRankLess() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSetLeaderboardTieBreak tests the -leaderboard-tiebreak values
+func TestSetLeaderboardTieBreak(t *testing.T) {
+	original := leaderboardTieBreak
+	defer func() { leaderboardTieBreak = original }()
+
+	if err := setLeaderboardTieBreak(tieBreakSubmitted); err != nil || leaderboardTieBreak != tieBreakSubmitted {
+		t.Errorf("setLeaderboardTieBreak(%q) = %v, tie-break %q", tieBreakSubmitted, err, leaderboardTieBreak)
+	}
+	if err := setLeaderboardTieBreak("alphabetical"); err == nil {
+		t.Error("expected an error for an unknown tie-break")
+	}
+	if leaderboardTieBreak != tieBreakSubmitted {
+		t.Errorf("an invalid value must not change the tie-break, got %q", leaderboardTieBreak)
+	}
+}
+
+// TestCompareLeaderboardEntries_Percentage tests that quizzes of different lengths rank by percentage
+func TestCompareLeaderboardEntries_Percentage(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b LeaderboardEntry
+		want int
+	}{
+		{"2/2 beats 3/5", LeaderboardEntry{Score: 2, Total: 2}, LeaderboardEntry{Score: 3, Total: 5}, -1},
+		{"3/5 loses to 2/2", LeaderboardEntry{Score: 3, Total: 5}, LeaderboardEntry{Score: 2, Total: 2}, 1},
+		{"equal percentage ranks the higher score first", LeaderboardEntry{Score: 3, Total: 3}, LeaderboardEntry{Score: 2, Total: 2}, -1},
+		{"1/3 beats 3/10", LeaderboardEntry{Score: 1, Total: 3}, LeaderboardEntry{Score: 3, Total: 10}, -1},
+		{"no total counts as zero percent", LeaderboardEntry{Score: 5, Total: 0}, LeaderboardEntry{Score: 1, Total: 10}, 1},
+		{"same score and total tie", LeaderboardEntry{Score: 2, Total: 3, When: time.Now()}, LeaderboardEntry{Score: 2, Total: 3}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareLeaderboardEntries(tt.a, tt.b); got != tt.want {
+				t.Errorf("compareLeaderboardEntries() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRankLeaderboardEntries tests competition and dense numbering of ties
+func TestRankLeaderboardEntries(t *testing.T) {
+	original := leaderboardRankStyle
+	defer func() { leaderboardRankStyle = original }()
+
+	// 100%, 100% (fewer questions), 67%, 67%, 67%, 33%
+	entries := []LeaderboardEntry{
+		{Name: "A", Score: 3, Total: 3},
+		{Name: "B", Score: 2, Total: 2},
+		{Name: "C", Score: 2, Total: 3},
+		{Name: "D", Score: 2, Total: 3},
+		{Name: "E", Score: 2, Total: 3},
+		{Name: "F", Score: 1, Total: 3},
+	}
+
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{rankCompetition, "1,2,3,3,3,6"},
+		{rankDense, "1,2,3,3,3,4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			leaderboardRankStyle = tt.style
+			var ranks []string
+			for _, e := range rankLeaderboardEntries(entries) {
+				ranks = append(ranks, fmt.Sprint(e.Rank))
+			}
+			if got := strings.Join(ranks, ","); got != tt.want {
+				t.Errorf("ranks = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if err := setLeaderboardRankStyle("olympic"); err == nil {
+		t.Error("expected an error for an unknown rank style")
+	}
+}
+
+// TestLoadLeaderboard_MigratesRawScoreOrder tests that a file ranked by raw score is re-ranked
+// by percentage once, keeping a backup of the original
+func TestLoadLeaderboard_MigratesRawScoreOrder(t *testing.T) {
+	tempDir := t.TempDir()
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd)
+	os.Chdir(tempDir)
+	leaderboardManager = LeaderboardManager{}
+
+	when := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	legacy := []LeaderboardEntry{
+		{Name: "Long", Score: 3, Total: 5, When: when, QuizType: "astrology"},
+		{Name: "Short", Score: 2, Total: 2, When: when, QuizType: "tarot"},
+	}
+	original, _ := json.MarshalIndent(legacy, "", "  ")
+	if err := os.WriteFile(leaderboardFilename, original, 0644); err != nil {
+		t.Fatalf("failed to write leaderboard: %v", err)
+	}
+
+	if err := loadLeaderboard(); err != nil {
+		t.Fatalf("loadLeaderboard() failed: %v", err)
+	}
+	if entries := getLeaderboard(); len(entries) != 2 || entries[0].Name != "Short" {
+		t.Fatalf("expected 2/2 to rank first, got %+v", entries)
+	}
+
+	backup, err := os.ReadFile(leaderboardFilename + ".bak")
+	if err != nil || string(backup) != string(original) {
+		t.Errorf("expected the original file to be backed up, got %q (%v)", backup, err)
+	}
+	var saved []LeaderboardEntry
+	data, _ := os.ReadFile(leaderboardFilename)
+	if err := json.Unmarshal(data, &saved); err != nil || len(saved) != 2 || saved[0].Name != "Short" {
+		t.Errorf("expected the re-ranked file to be saved, got %s", data)
+	}
+
+	// A migrated file is left alone
+	os.Remove(leaderboardFilename + ".bak")
+	if err := loadLeaderboard(); err != nil {
+		t.Fatalf("second loadLeaderboard() failed: %v", err)
+	}
+	if _, err := os.Stat(leaderboardFilename + ".bak"); !os.IsNotExist(err) {
+		t.Error("expected no second migration")
+	}
+}
+
+// TestLeaderboardGetHandler_SharedRanks tests that tied entries show the same rank
+func TestLeaderboardGetHandler_SharedRanks(t *testing.T) {
+	now := time.Now()
+	leaderboardManager = LeaderboardManager{entries: []LeaderboardEntry{
+		{Name: "Ana", Score: 3, Total: 3, QuizType: "astrology", When: now.Add(-time.Hour)},
+		{Name: "Ben", Score: 3, Total: 3, QuizType: "tarot", When: now},
+		{Name: "Cy", Score: 1, Total: 3, QuizType: "astrology", When: now},
+	}}
+
+	req := httptest.NewRequest(http.MethodGet, "/leaderboard", nil)
+	rr := httptest.NewRecorder()
+	leaderboardGetHandler(rr, req)
+
+	body := rr.Body.String()
+	if got := strings.Count(body, `<td class="rank">1</td>`); got != 2 {
+		t.Errorf("expected two entries sharing rank 1, got %d", got)
+	}
+	if !strings.Contains(body, `<td class="rank">3</td>`) {
+		t.Error("expected competition ranking to skip to rank 3")
+	}
+}
+
+// TestLeaderboardPosition tests the rank query for entries outside the top
+func TestLeaderboardPosition(t *testing.T) {
+	now := time.Date(2024, 3, 14, 15, 30, 0, 0, time.UTC)
+	leaderboardManager = LeaderboardManager{}
+
+	// P0..P9 score 10..1 out of 10, except P5 ties P4 and, being older, lists first; P9 is from last year
+	leaderboardManager.mu.Lock()
+	for i := 0; i < 10; i++ {
+		score := 10 - i
+		if i == 5 {
+			score = 6
+		}
+		when := now.Add(-time.Duration(i) * time.Minute)
+		if i == 9 {
+			when = now.AddDate(-1, 0, 0)
+		}
+		insertLeaderboardEntryLocked(LeaderboardEntry{ID: fmt.Sprintf("id%d", i), Name: fmt.Sprintf("P%d", i), Score: score, Total: 10, When: when})
+	}
+	leaderboardManager.mu.Unlock()
+
+	neighbors := func(pos LeaderboardPosition) string {
+		var out []string
+		for _, e := range pos.Neighbors {
+			s := fmt.Sprintf("%s#%d", e.Name, e.Rank)
+			if e.Highlight {
+				s += "*"
+			}
+			out = append(out, s)
+		}
+		return strings.Join(out, ",")
+	}
+
+	tests := []struct {
+		name      string
+		id        string
+		window    string
+		wantOK    bool
+		wantRank  int
+		wantOf    int
+		neighbors string
+	}{
+		{"middle of the board", "id6", windowAllTime, true, 7, 10, "P5#5,P4#5,P6#7*,P7#8,P8#9"},
+		{"tied entry shares the rank", "id5", windowAllTime, true, 5, 10, "P2#3,P3#4,P5#5*,P4#5,P6#7"},
+		{"first place", "id0", windowAllTime, true, 1, 10, "P0#1*,P1#2,P2#3"},
+		{"last place", "id9", windowAllTime, true, 10, 10, "P7#8,P8#9,P9#10*"},
+		{"other windows count only their entries", "id8", windowDay, true, 9, 9, "P6#7,P7#8,P8#9*"},
+		{"entry outside the window", "id9", windowMonth, false, 0, 0, ""},
+		{"unknown entry", "nope", windowAllTime, false, 0, 0, ""},
+		{"empty ID", "", windowAllTime, false, 0, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, ok := leaderboardPosition(tt.id, tt.window, now, 2)
+			if ok != tt.wantOK {
+				t.Fatalf("leaderboardPosition() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if pos.Entry.Rank != tt.wantRank || pos.Of != tt.wantOf {
+				t.Errorf("position = #%d of %d, want #%d of %d", pos.Entry.Rank, pos.Of, tt.wantRank, tt.wantOf)
+			}
+			if got := neighbors(pos); got != tt.neighbors {
+				t.Errorf("neighbors = %s, want %s", got, tt.neighbors)
+			}
+		})
+	}
+}
+
+// TestLeaderboardSubmission_ShowsOwnRank tests that a submitted entry is highlighted on the
+// board, or located below it when it misses the top
+func TestLeaderboardSubmission_ShowsOwnRank(t *testing.T) {
+	setupAccountsTest(t)
+	practiceQuestions()
+
+	// Fill all but one place in the top with untimed perfect scores. A new perfect run either
+	// beats them on time or, if too quick to measure, ties with them; both leave it first.
+	for i := 0; i < MaxLeaderboardSize-1; i++ {
+		if err := saveScore(fmt.Sprintf("Ace%d", i), NumQuestions, NumQuestions, "astrology"); err != nil {
+			t.Fatalf("saveScore() failed: %v", err)
+		}
+	}
+
+	submit := func(name, answer string) string {
+		t.Helper()
+		send := newBrowser()
+		send(http.MethodGet, "/quiz?new=1", nil)
+		var w *httptest.ResponseRecorder
+		for step := 0; step < NumQuestions; step++ {
+			w = send(http.MethodPost, "/quiz", url.Values{"step": {strconv.Itoa(step)}, "answer": {answer}})
+		}
+		results, _ := url.Parse(w.Header().Get("Location"))
+		w = send(http.MethodPost, "/quiz/leaderboard", url.Values{"state": {results.Query().Get("state")}, "name": {name}})
+		location, _ := url.Parse(w.Header().Get("Location"))
+		if location.Path != "/leaderboard" || location.Query().Get("entry") == "" {
+			t.Fatalf("expected a redirect to the leaderboard with the entry ID, got %s", location)
+		}
+		return send(http.MethodGet, location.String(), nil).Body.String()
+	}
+
+	// A perfect score ties for first and is highlighted on the board
+	body := submit("Winner", "0")
+	if !strings.Contains(body, `<tr class="you">
+                    <td class="rank">1</td>
+                    <td class="name">Winner</td>`) {
+		t.Error("expected the new entry to be highlighted on the board")
+	}
+	if strings.Contains(body, `class="your-rank"`) {
+		t.Error("expected no separate rank section for an entry on the board")
+	}
+
+	// A zero misses the top and is located below it
+	body = submit("Rookie", "1")
+	if !strings.Contains(body, "You are #21 of 21") {
+		t.Error("expected the player's rank outside the top")
+	}
+	if !strings.Contains(body, `<tr class="you">
+                    <td class="rank">21</td>
+                    <td class="name">Rookie</td>`) {
+		t.Error("expected the player's entry highlighted among its neighbours")
+	}
+}
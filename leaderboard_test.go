@@ -1,9 +1,13 @@
 package main
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -199,19 +203,9 @@ func TestSaveScore_SingleEntry(t *testing.T) {
 		t.Errorf("timestamp %v is not between %v and %v", entry.When, before, after)
 	}
 
-	// Verify file was created
-	data, err := os.ReadFile(leaderboardFilename)
-	if err != nil {
-		t.Fatalf("failed to read file: %v", err)
-	}
-
-	var fileEntries []LeaderboardEntry
-	if err := json.Unmarshal(data, &fileEntries); err != nil {
-		t.Fatalf("failed to parse file: %v", err)
-	}
-
-	if len(fileEntries) != 1 {
-		t.Errorf("expected 1 entry in file, got %d", len(fileEntries))
+	// Verify the entry was appended to the log
+	if logged := readLeaderboardLog(t); len(logged) != 1 || logged[0].ID != entry.ID {
+		t.Errorf("expected the entry in the log, got %+v", logged)
 	}
 }
 
@@ -271,7 +265,7 @@ func TestSaveScore_Sorting(t *testing.T) {
 	}
 }
 
-// TestSaveScore_Truncation tests that only top 20 entries are kept
+// TestSaveScore_Truncation tests that full history is kept while the board shows the top 20
 func TestSaveScore_Truncation(t *testing.T) {
 	// Create temporary directory
 	tempDir := t.TempDir()
@@ -283,47 +277,128 @@ func TestSaveScore_Truncation(t *testing.T) {
 	leaderboardManager = LeaderboardManager{entries: []LeaderboardEntry{}}
 
 	// Add 25 entries (exceeding MaxLeaderboardSize of 20)
-	for i := 0; i < 25; i++ {
-		// Use descending scores so we know which should be kept
+	numEntries := 25
+	for i := 0; i < numEntries; i++ {
+		// Use descending scores so we know which should be shown
 		err := saveScore(fmt.Sprintf("Player%d", i), 100-i, 100, "astrology")
 		if err != nil {
 			t.Fatalf("saveScore() failed for player %d: %v", i, err)
 		}
 	}
 
-	// Verify only top 20 remain
-	if len(leaderboardManager.entries) != MaxLeaderboardSize {
-		t.Errorf("expected %d entries, got %d", MaxLeaderboardSize, len(leaderboardManager.entries))
+	// Recent scores are all retained, in rank order
+	if len(leaderboardManager.entries) != numEntries {
+		t.Errorf("expected %d entries, got %d", numEntries, len(leaderboardManager.entries))
 	}
-
-	// Verify highest scores are kept (Player0 through Player19)
-	for i := 0; i < MaxLeaderboardSize; i++ {
-		expectedName := fmt.Sprintf("Player%d", i)
-		if leaderboardManager.entries[i].Name != expectedName {
-			t.Errorf("expected entry %d to be %s, got %s", i, expectedName, leaderboardManager.entries[i].Name)
+	for i, entry := range leaderboardManager.entries {
+		if expectedName := fmt.Sprintf("Player%d", i); entry.Name != expectedName {
+			t.Errorf("expected entry %d to be %s, got %s", i, expectedName, entry.Name)
 		}
 	}
 
-	// Verify lowest scores are removed (Player20-24 should not be present)
-	for _, entry := range leaderboardManager.entries {
+	// The all-time board shows only the highest scores (Player0 through Player19)
+	top := topLeaderboard(windowAllTime, "", MaxLeaderboardSize, time.Now())
+	if len(top) != MaxLeaderboardSize {
+		t.Fatalf("expected %d entries on the board, got %d", MaxLeaderboardSize, len(top))
+	}
+	for _, entry := range top {
 		if entry.Name == "Player20" || entry.Name == "Player24" {
-			t.Errorf("entry %s should have been truncated", entry.Name)
+			t.Errorf("entry %s should not be on the board", entry.Name)
 		}
 	}
 
-	// Verify file only contains 20 entries
-	data, err := os.ReadFile(leaderboardFilename)
+	// Verify the log holds the full history
+	if logged := readLeaderboardLog(t); len(logged) != numEntries {
+		t.Errorf("expected %d entries in the log, got %d", numEntries, len(logged))
+	}
+}
+
+// readLeaderboardLog returns the entries appended to the leaderboard log
+func readLeaderboardLog(t *testing.T) []LeaderboardEntry {
+	t.Helper()
+	file, err := os.Open(leaderboardLogFilename)
 	if err != nil {
-		t.Fatalf("failed to read file: %v", err)
+		t.Fatalf("failed to open log: %v", err)
 	}
+	defer file.Close()
 
-	var fileEntries []LeaderboardEntry
-	if err := json.Unmarshal(data, &fileEntries); err != nil {
-		t.Fatalf("failed to parse file: %v", err)
+	var entries []LeaderboardEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry LeaderboardEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("failed to parse log line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
 	}
+	return entries
+}
+
+// TestLeaderboardLog_ReplayAndCompact tests that logged entries survive a restart, are folded
+// into the file once, and that the history is rewritten only every leaderboardCompactEvery entries
+func TestLeaderboardLog_ReplayAndCompact(t *testing.T) {
+	tempDir := t.TempDir()
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd)
+	os.Chdir(tempDir)
 
-	if len(fileEntries) != MaxLeaderboardSize {
-		t.Errorf("expected %d entries in file, got %d", MaxLeaderboardSize, len(fileEntries))
+	leaderboardManager = LeaderboardManager{}
+	if err := loadLeaderboard(); err != nil {
+		t.Fatalf("loadLeaderboard() failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := saveScore(fmt.Sprintf("Player%d", i), i, 3, "astrology"); err != nil {
+			t.Fatalf("saveScore() failed: %v", err)
+		}
+	}
+	if data, _ := os.ReadFile(leaderboardFilename); string(data) != "[]" {
+		t.Errorf("file should not be rewritten for each entry, got %s", data)
+	}
+
+	// A crash can leave a partial last line; it is skipped
+	file, _ := os.OpenFile(leaderboardLogFilename, os.O_WRONLY|os.O_APPEND, 0644)
+	file.WriteString(`{"name":"Torn","sc`)
+	file.Close()
+
+	// A restart replays the log into the file and empties the log
+	leaderboardManager = LeaderboardManager{}
+	if err := loadLeaderboard(); err != nil {
+		t.Fatalf("loadLeaderboard() failed: %v", err)
+	}
+	if len(leaderboardManager.entries) != 3 || leaderboardManager.entries[0].Name != "Player2" {
+		t.Fatalf("expected the 3 logged entries in rank order, got %+v", leaderboardManager.entries)
+	}
+	if _, err := os.Stat(leaderboardLogFilename); !os.IsNotExist(err) {
+		t.Errorf("expected the log to be emptied, stat error = %v", err)
+	}
+	var stored []LeaderboardEntry
+	data, _ := os.ReadFile(leaderboardFilename)
+	if err := json.Unmarshal(data, &stored); err != nil || len(stored) != 3 {
+		t.Fatalf("expected 3 entries in the file, got %s (%v)", data, err)
+	}
+
+	// Entries already in the file are not added twice if the log outlives a compaction
+	logged, _ := json.Marshal(stored[0])
+	os.WriteFile(leaderboardLogFilename, append(logged, '\n'), 0644)
+	leaderboardManager = LeaderboardManager{}
+	if err := loadLeaderboard(); err != nil {
+		t.Fatalf("loadLeaderboard() failed: %v", err)
+	}
+	if len(leaderboardManager.entries) != 3 {
+		t.Errorf("expected 3 entries after replaying a duplicate, got %d", len(leaderboardManager.entries))
+	}
+
+	// Reaching leaderboardCompactEvery logged entries rewrites the file
+	leaderboardManager.logged = leaderboardCompactEvery - 1
+	if err := saveScore("Compacted", 3, 3, "astrology"); err != nil {
+		t.Fatalf("saveScore() failed: %v", err)
+	}
+	data, _ = os.ReadFile(leaderboardFilename)
+	if err := json.Unmarshal(data, &stored); err != nil || len(stored) != 4 {
+		t.Errorf("expected 4 entries in the file after compaction, got %s (%v)", data, err)
+	}
+	if leaderboardManager.logged != 0 {
+		t.Errorf("logged = %d after compaction, want 0", leaderboardManager.logged)
 	}
 }
 
@@ -418,15 +493,9 @@ func TestSaveScore_Concurrent(t *testing.T) {
 		t.Errorf("concurrent saveScore() failed: %v", err)
 	}
 
-	// Verify all writes succeeded
-	// Should have min(50, MaxLeaderboardSize) entries
-	expectedCount := numGoroutines
-	if expectedCount > MaxLeaderboardSize {
-		expectedCount = MaxLeaderboardSize
-	}
-
-	if len(leaderboardManager.entries) != expectedCount {
-		t.Errorf("expected %d entries, got %d", expectedCount, len(leaderboardManager.entries))
+	// Verify all writes succeeded and were kept in history
+	if len(leaderboardManager.entries) != numGoroutines {
+		t.Errorf("expected %d entries, got %d", numGoroutines, len(leaderboardManager.entries))
 	}
 
 	// Verify no data corruption by checking entries are valid
@@ -508,3 +577,142 @@ func TestGetLeaderboard_MaxSize(t *testing.T) {
 	// Note: The truncation happens in saveScore, not getLeaderboard
 	// getLeaderboard just returns a copy of whatever is in the manager
 }
+
+// TestSaveScore_PublishesRankingChanges tests that live viewers are only notified when the board changes
+func TestSaveScore_PublishesRankingChanges(t *testing.T) {
+	tempDir := t.TempDir()
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd)
+	os.Chdir(tempDir)
+
+	// Fill the board with perfect scores
+	entries := make([]LeaderboardEntry, MaxLeaderboardSize)
+	for i := range entries {
+		entries[i] = LeaderboardEntry{Name: fmt.Sprintf("Player%d", i), Score: 3, Total: 3, When: time.Now()}
+	}
+	leaderboardManager = LeaderboardManager{entries: entries}
+
+	events, unsubscribe, snapshot, err := subscribeLeaderboard()
+	if err != nil {
+		t.Fatalf("subscribeLeaderboard() failed: %v", err)
+	}
+	defer unsubscribe()
+
+	var initial leaderboardUpdate
+	if err := json.Unmarshal(snapshot.Data, &initial); err != nil {
+		t.Fatalf("invalid snapshot: %v", err)
+	}
+	if len(initial.Entries) != MaxLeaderboardSize || initial.Entries[0].Percentage != "100.0" {
+		t.Errorf("unexpected initial snapshot: %+v", initial)
+	}
+
+	// A score that doesn't make the board must not publish
+	if err := saveScore("Loser", 0, 3, "astrology"); err != nil {
+		t.Fatalf("saveScore() failed: %v", err)
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected update for unranked score: %s", ev.Data)
+	default:
+	}
+
+	// Clear the board so the next score ranks
+	leaderboardManager.mu.Lock()
+	leaderboardManager.entries, leaderboardManager.tops = nil, nil
+	leaderboardManager.mu.Unlock()
+
+	if err := saveScore("Winner", 2, 3, "tarot"); err != nil {
+		t.Fatalf("saveScore() failed: %v", err)
+	}
+	select {
+	case ev := <-events:
+		var update leaderboardUpdate
+		if err := json.Unmarshal(ev.Data, &update); err != nil {
+			t.Fatalf("invalid update: %v", err)
+		}
+		if update.Version <= initial.Version {
+			t.Errorf("expected version to increase past %d, got %d", initial.Version, update.Version)
+		}
+		if len(update.Entries) != 1 || update.Entries[0].Name != "Winner" || update.Entries[0].Percentage != "66.7" {
+			t.Errorf("unexpected update: %+v", update)
+		}
+	default:
+		t.Fatal("expected an update after a ranking change")
+	}
+}
+
+// TestLeaderboardEventsHandler tests the SSE endpoint streams the current board and live changes
+func TestLeaderboardEventsHandler(t *testing.T) {
+	tempDir := t.TempDir()
+	originalWd, _ := os.Getwd()
+	defer os.Chdir(originalWd)
+	os.Chdir(tempDir)
+
+	leaderboardManager = LeaderboardManager{entries: []LeaderboardEntry{
+		{Name: "Alice", Score: 3, Total: 3, When: time.Now(), QuizType: "astrology"},
+	}}
+
+	server := httptest.NewServer(setupRoutes())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/leaderboard/events")
+	if err != nil {
+		t.Fatalf("GET /leaderboard/events failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %s", resp.Header.Get("Content-Type"))
+	}
+
+	lines := make(chan string, 16)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if strings.HasPrefix(scanner.Text(), "data: ") {
+				lines <- strings.TrimPrefix(scanner.Text(), "data: ")
+			}
+		}
+		close(lines)
+	}()
+
+	readUpdate := func() leaderboardUpdate {
+		t.Helper()
+		select {
+		case line := <-lines:
+			var update leaderboardUpdate
+			if err := json.Unmarshal([]byte(line), &update); err != nil {
+				t.Fatalf("invalid event data %q: %v", line, err)
+			}
+			return update
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for leaderboard event")
+		}
+		return leaderboardUpdate{}
+	}
+
+	if first := readUpdate(); len(first.Entries) != 1 || first.Entries[0].Name != "Alice" {
+		t.Errorf("unexpected initial event: %+v", first)
+	}
+
+	if err := saveScore("Bob", 3, 3, "tarot"); err != nil {
+		t.Fatalf("saveScore() failed: %v", err)
+	}
+	// Bob ties Alice's perfect score, so they share first place
+	second := readUpdate()
+	if len(second.Entries) != 2 || second.Entries[1].Name != "Bob" || second.Entries[1].Rank != 1 {
+		t.Errorf("unexpected live update: %+v", second)
+	}
+}
+
+// TestLeaderboardEventsHandler_MethodNotAllowed tests non-GET requests to the stream
+func TestLeaderboardEventsHandler_MethodNotAllowed(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/leaderboard/events", nil)
+	rec := httptest.NewRecorder()
+
+	leaderboardEventsHandler(rec, req)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", rec.Code)
+	}
+}
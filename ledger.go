@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	quizRunIDBytes         = 16           // size of the random ID that binds every state of one run to the ledger
+	stepLedgerFilename     = "ledger.log" // claimed steps and submissions, one run position per line
+	stepLedgerCompactEvery = 1000         // logged claims after which the log is rewritten with live runs only
+)
+
+// stepLedger records, per run, the next question step that may be graded and whether the run's
+// score was submitted. Each step can be consumed exactly once, so replaying an earlier signed
+// state (or racing two submissions of the current one) cannot grade a question twice, and a
+// finished run posts to the leaderboard at most once.
+//
+// Entries outlive the state tokens they guard (both expire after quizSessionLifetime of
+// inactivity). Once loaded from a file, every claim is logged there before it takes effect, so a
+// restart forgets no graded step or submitted score. A run the ledger does not know has claimed
+// nothing yet and is adopted at whatever step it presents.
+type stepLedger struct {
+	mu   sync.Mutex
+	runs map[string]*ledgerRun
+
+	path   string // where claims are logged; empty keeps the ledger in memory only
+	logged int    // claims appended since the log was last rewritten
+}
+
+// ledgerRun is one run's position in the ledger
+type ledgerRun struct {
+	next    int       // the only step that may be graded next
+	expires time.Time // forgotten after this much inactivity
+	latest  string    // embedded runs: the token of the newest state, for clients that resubmit an old one
+
+	submitted bool // the run's score is on the leaderboard
+}
+
+// ledgerRecord is one run's position as written to the ledger log; the last record of a run wins
+type ledgerRecord struct {
+	RunID     string `json:"r"`
+	Next      int    `json:"n"`
+	Submitted bool   `json:"s,omitempty"`
+	Expires   int64  `json:"x"` // Unix seconds
+}
+
+// answeredSteps is the global step ledger
+var answeredSteps stepLedger
+
+// newQuizRunID returns a random, unguessable run ID
+func newQuizRunID() string {
+	return randomToken(quizRunIDBytes)
+}
+
+// begin registers a new run at step 0 and drops runs that have gone idle
+func (l *stepLedger) begin(runID string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.runs == nil {
+		l.runs = make(map[string]*ledgerRun)
+	}
+	for id, run := range l.runs {
+		if now.After(run.expires) {
+			delete(l.runs, id)
+		}
+	}
+	l.runs[runID] = &ledgerRun{next: 0, expires: now.Add(quizSessionLifetime)}
+}
+
+// consume claims a step for grading. It reports false when the step was already graded or
+// is ahead of the run, in which case the submission must be ignored.
+func (l *stepLedger) consume(runID string, step int, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.runs == nil {
+		l.runs = make(map[string]*ledgerRun)
+	}
+	run, ok := l.runs[runID]
+	if !ok || now.After(run.expires) {
+		run = &ledgerRun{next: step, expires: now.Add(quizSessionLifetime)}
+		l.runs[runID] = run
+	}
+	if step != run.next {
+		return false
+	}
+	claimed := *run
+	claimed.next++
+	claimed.expires = now.Add(quizSessionLifetime)
+	if err := l.recordLocked(runID, claimed, now); err != nil {
+		log.Printf("Error recording quiz step: %v", err)
+		return false
+	}
+	*run = claimed
+	return true
+}
+
+// submit claims a finished run's one leaderboard submission. It reports false when the run was
+// already submitted.
+func (l *stepLedger) submit(runID string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.runs == nil {
+		l.runs = make(map[string]*ledgerRun)
+	}
+	run, ok := l.runs[runID]
+	if !ok || now.After(run.expires) {
+		run = &ledgerRun{expires: now.Add(quizSessionLifetime)}
+		l.runs[runID] = run
+	}
+	if run.submitted {
+		return false
+	}
+	claimed := *run
+	claimed.submitted = true
+	claimed.expires = now.Add(quizSessionLifetime)
+	if err := l.recordLocked(runID, claimed, now); err != nil {
+		log.Printf("Error recording quiz submission: %v", err)
+		return false
+	}
+	*run = claimed
+	return true
+}
+
+// load replays the claims logged at path, drops runs that have gone idle and rewrites the log
+// with the rest. Claims made afterwards are appended to it. A line a crash cut short is skipped.
+func (l *stepLedger) load(path string, now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.path = path
+	l.runs = make(map[string]*ledgerRun)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read step ledger: %w", err)
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var record ledgerRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			log.Printf("Skipping unreadable step ledger record: %v", err)
+			continue
+		}
+		l.runs[record.RunID] = &ledgerRun{
+			next:      record.Next,
+			submitted: record.Submitted,
+			expires:   time.Unix(record.Expires, 0),
+		}
+	}
+	return l.compactLocked(now)
+}
+
+// recordLocked appends a run's new position to the log, rewriting the log first once enough
+// claims have piled up. The caller must hold l.mu.
+func (l *stepLedger) recordLocked(runID string, run ledgerRun, now time.Time) error {
+	if l.path == "" {
+		return nil
+	}
+	if l.logged >= stepLedgerCompactEvery {
+		if err := l.compactLocked(now); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(run.record(runID))
+	if err != nil {
+		return fmt.Errorf("failed to marshal step ledger record: %w", err)
+	}
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open step ledger: %w", err)
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write step ledger: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write step ledger: %w", err)
+	}
+	l.logged++
+	return nil
+}
+
+// compactLocked drops idle runs and rewrites the log with one record per remaining run,
+// replacing it in one step so a crash never leaves it half written. The caller must hold l.mu.
+func (l *stepLedger) compactLocked(now time.Time) error {
+	var buf bytes.Buffer
+	for id, run := range l.runs {
+		if now.After(run.expires) {
+			delete(l.runs, id)
+			continue
+		}
+		data, err := json.Marshal(run.record(id))
+		if err != nil {
+			return fmt.Errorf("failed to marshal step ledger record: %w", err)
+		}
+		buf.Write(append(data, '\n'))
+	}
+
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write step ledger: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return fmt.Errorf("failed to write step ledger: %w", err)
+	}
+	l.logged = 0
+	return nil
+}
+
+// record returns the run's position as written to the ledger log
+func (run ledgerRun) record(runID string) ledgerRecord {
+	return ledgerRecord{RunID: runID, Next: run.next, Submitted: run.submitted, Expires: run.expires.Unix()}
+}
+
+// remember keeps the token of a run's newest state. Embedded quizzes have no cookie to hold it,
+// so a resubmitted old state is sent on to this one instead of being stuck at a graded step.
+func (l *stepLedger) remember(runID, token string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if run, ok := l.runs[runID]; ok {
+		run.latest = token
+	}
+}
+
+// latestToken returns the token remembered for a run, if any
+func (l *stepLedger) latestToken(runID string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	run, ok := l.runs[runID]
+	if !ok || run.latest == "" {
+		return "", false
+	}
+	return run.latest, true
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStepLedger_Consume(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	var ledger stepLedger
+	ledger.begin("run", now)
+
+	steps := []struct {
+		step int
+		want bool
+	}{
+		{1, false}, // ahead of the run
+		{0, true},
+		{0, false}, // replay of the first question
+		{1, true},
+		{0, false},
+		{1, false},
+		{2, true},
+	}
+	for i, s := range steps {
+		if got := ledger.consume("run", s.step, now); got != s.want {
+			t.Errorf("call %d: consume(step %d) = %v, want %v", i, s.step, got, s.want)
+		}
+	}
+
+	// A run the ledger does not know has claimed nothing and is adopted at the step it presents
+	if !ledger.consume("unknown", 3, now) || ledger.consume("unknown", 3, now) {
+		t.Error("an unknown run should be adopted once at its current step")
+	}
+
+	// Idle runs are dropped when new runs begin
+	ledger.begin("later", now.Add(quizSessionLifetime+time.Minute))
+	if _, ok := ledger.runs["run"]; ok {
+		t.Error("idle run should be pruned")
+	}
+}
+
+func TestStepLedger_ConcurrentSubmissions(t *testing.T) {
+	var ledger stepLedger
+	ledger.begin("run", time.Now())
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	granted := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ledger.consume("run", 0, time.Now()) {
+				mu.Lock()
+				granted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if granted != 1 {
+		t.Errorf("step 0 was graded %d times, want exactly once", granted)
+	}
+}
+
+func TestStepLedger_Persisted(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "ledger.log")
+
+	var before stepLedger
+	if err := before.load(path, now); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	before.begin("run", now)
+	before.consume("run", 0, now)
+	before.consume("run", 1, now)
+	before.submit("done", now)
+
+	// A crash may cut the last line short
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	file.WriteString(`{"r":"cut`)
+	file.Close()
+
+	// After a restart, graded steps and submitted scores stay claimed
+	var after stepLedger
+	if err := after.load(path, now.Add(time.Minute)); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if after.consume("run", 1, now.Add(time.Minute)) {
+		t.Error("a step graded before the restart should not be graded again")
+	}
+	if !after.consume("run", 2, now.Add(time.Minute)) {
+		t.Error("the run should continue at its next step")
+	}
+	if after.submit("done", now.Add(time.Minute)) {
+		t.Error("a run submitted before the restart should not be submitted again")
+	}
+
+	// Runs that went idle are dropped when the log is rewritten
+	var later stepLedger
+	if err := later.load(path, now.Add(2*quizSessionLifetime)); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if len(later.runs) != 0 {
+		t.Errorf("idle runs after reload = %d, want 0", len(later.runs))
+	}
+	if data, _ := os.ReadFile(path); len(data) != 0 {
+		t.Errorf("rewritten log = %q, want empty", data)
+	}
+}
+
+// TestQuizPostHandler_ReplayedState tests that resubmitting an earlier signed state cannot regrade its question
+func TestQuizPostHandler_ReplayedState(t *testing.T) {
+	questionSets = map[string][]Question{
+		"astrology": {
+			{ID: "q1", Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 1},
+			{ID: "q2", Question: "Q2?", Choices: []string{"A", "B"}, AnswerIndex: 1},
+		},
+	}
+
+	w := httptest.NewRecorder()
+	quizGetHandler(w, httptest.NewRequest(http.MethodGet, "/quiz?new=1", nil))
+	_, rest, _ := strings.Cut(w.Body.String(), `name="state" value="`)
+	firstToken, _, _ := strings.Cut(rest, `"`)
+
+	// The attacker drops the cookie and posts the first question's token with each answer in turn
+	post := func(answer string) *httptest.ResponseRecorder {
+		form := url.Values{"state": {firstToken}, "answer": {answer}}
+		req := httptest.NewRequest(http.MethodPost, "/quiz", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		quizPostHandler(w, req)
+		return w
+	}
+
+	wrong := post("0")
+	var cookie *http.Cookie
+	for _, c := range wrong.Result().Cookies() {
+		if c.Name == quizRunCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("first submission should be graded and store the next state")
+	}
+
+	replay := post("1")
+	for _, c := range replay.Result().Cookies() {
+		if c.Name == quizRunCookieName {
+			t.Fatal("replayed state must not produce a new state")
+		}
+	}
+	if replay.Code != http.StatusSeeOther || replay.Header().Get("Location") != "/quiz/play" {
+		t.Errorf("replay = %d %q, want redirect to /quiz/play", replay.Code, replay.Header().Get("Location"))
+	}
+
+	state, ok := decodeStateToken(cookie.Value)
+	if !ok || state.CurrentIndex != 1 || state.Score != 0 {
+		t.Errorf("run after replay = %+v, want question 2 with score 0", state)
+	}
+}
+
+func TestStepLedger_Submit(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	var ledger stepLedger
+	ledger.begin("run", now)
+
+	if !ledger.submit("run", now) {
+		t.Fatal("first submission should be accepted")
+	}
+	if ledger.submit("run", now.Add(time.Minute)) {
+		t.Error("second submission of the same run should be rejected")
+	}
+	if !ledger.submit("other", now) {
+		t.Error("another run should be submittable")
+	}
+
+	// The claim outlives the results token, which was issued before it
+	if ledger.submit("run", now.Add(quizSessionLifetime)) {
+		t.Error("submission should stay claimed for the token's lifetime")
+	}
+}
+
+// TestQuizLeaderboardPost_ReplayedResults tests that a results token posts one score, and only once the run is finished
+func TestQuizLeaderboardPost_ReplayedResults(t *testing.T) {
+	setupAccountsTest(t)
+
+	post := func(state QuizState) *httptest.ResponseRecorder {
+		token, _ := encodeStateToken(state)
+		form := url.Values{"state": {token}, "name": {"Replayer"}}
+		req := httptest.NewRequest(http.MethodPost, "/quiz/leaderboard", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		quizLeaderboardPostHandler(w, req)
+		return w
+	}
+
+	unfinished := QuizState{QuestionIDs: []string{"a", "b"}, CurrentIndex: 1, Score: 1, QuizType: "astrology", RunID: newQuizRunID()}
+	if w := post(unfinished); w.Code != http.StatusBadRequest {
+		t.Errorf("unfinished run status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	finished := QuizState{QuestionIDs: []string{"a", "b"}, CurrentIndex: 2, Score: 2, QuizType: "astrology", RunID: newQuizRunID()}
+	if w := post(finished); w.Code != http.StatusSeeOther {
+		t.Fatalf("first submit status = %d, want %d", w.Code, http.StatusSeeOther)
+	}
+	for i := 0; i < 3; i++ {
+		if w := post(finished); w.Code != http.StatusConflict {
+			t.Errorf("repeat submit status = %d, want %d", w.Code, http.StatusConflict)
+		}
+	}
+
+	if entries := getLeaderboard(); len(entries) != 1 {
+		t.Errorf("expected 1 entry, got %d", len(entries))
+	}
+}
+
+// TestQuizPostHandler_StateWithoutRunID tests that a state the step ledger cannot track is never graded
+func TestQuizPostHandler_StateWithoutRunID(t *testing.T) {
+	questionSets = map[string][]Question{
+		"astrology": {
+			{ID: "q1", Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 1},
+			{ID: "q2", Question: "Q2?", Choices: []string{"A", "B"}, AnswerIndex: 1},
+		},
+	}
+
+	token, _ := encodeStateToken(QuizState{QuestionIDs: []string{"q1", "q2"}, QuizType: "astrology"})
+	for i := 0; i < 2; i++ {
+		form := url.Values{"state": {token}, "answer": {"1"}}
+		req := httptest.NewRequest(http.MethodPost, "/quiz", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		quizPostHandler(w, req)
+
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/quiz" {
+			t.Errorf("submission %d = %d %q, want redirect to /quiz", i, w.Code, w.Header().Get("Location"))
+		}
+		if len(w.Result().Cookies()) != 0 {
+			t.Errorf("submission %d should not store a graded state", i)
+		}
+	}
+}
+
+// TestQuizPostHandler_OtherTabRun tests that a form is graded against its own run when another tab's run holds the cookie
+func TestQuizPostHandler_OtherTabRun(t *testing.T) {
+	questionSets = map[string][]Question{
+		"astrology": {
+			{ID: "q1", Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 1},
+			{ID: "q2", Question: "Q2?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+		},
+	}
+
+	tabA := QuizState{QuestionIDs: []string{"q1", "q2"}, QuizType: "astrology", RunID: newQuizRunID()}
+	tabB := QuizState{QuestionIDs: []string{"q2", "q1"}, QuizType: "astrology", RunID: newQuizRunID()}
+	tokenA, _ := encodeStateToken(tabA)
+	tokenB, _ := encodeStateToken(tabB)
+
+	// Tab B was opened last, so the cookie holds its run; tab A answers its own first question
+	form := url.Values{"state": {tokenA}, "run": {tabA.RunID}, "step": {"0"}, "answer": {"1"}}
+	req := httptest.NewRequest(http.MethodPost, "/quiz", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: quizRunCookieName, Value: tokenB})
+	w := httptest.NewRecorder()
+	quizPostHandler(w, req)
+
+	var next *QuizState
+	for _, c := range w.Result().Cookies() {
+		if c.Name == quizRunCookieName {
+			next, _ = decodeStateToken(c.Value)
+		}
+	}
+	if next == nil || next.RunID != tabA.RunID || next.CurrentIndex != 1 || next.Score != 1 {
+		t.Errorf("state after tab A's answer = %+v, want run A at question 2 with score 1", next)
+	}
+}
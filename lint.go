@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Lint severities
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// lintDiagnostic describes a single problem found in a question file
+type lintDiagnostic struct {
+	File     string `json:"file"`
+	Index    int    `json:"index"` // -1 for problems that apply to the whole file
+	ID       string `json:"id,omitempty"`
+	Severity string `json:"severity"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+// lintReport is the machine-readable output of the lint-questions subcommand
+type lintReport struct {
+	Files       int              `json:"files"`
+	Errors      int              `json:"errors"`
+	Warnings    int              `json:"warnings"`
+	Formatted   []string         `json:"formatted,omitempty"`
+	Diagnostics []lintDiagnostic `json:"diagnostics"`
+}
+
+// runLintQuestions implements `helloworld lint-questions [flags] path...`
+// Paths may be JSON, CSV or YAML banks, or directories containing them.
+// It returns the process exit code: 0 when clean, 1 when errors were found and 2 on usage errors.
+func runLintQuestions(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("lint-questions", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "text", "Output format: text or json")
+	fix := fs.Bool("fix", false, "Rewrite files in canonical format when they parse cleanly")
+	strict := fs.Bool("strict", false, "Treat warnings as errors")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: helloworld lint-questions [flags] path...")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "unknown format %q (must be text or json)\n", *format)
+		return 2
+	}
+
+	files, err := expandQuestionPaths(fs.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if len(files) == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	report := lintReport{Files: len(files), Diagnostics: []lintDiagnostic{}}
+	for _, file := range files {
+		diags, formatted := lintQuestionFile(file, *fix)
+		if formatted {
+			report.Formatted = append(report.Formatted, file)
+		}
+		report.Diagnostics = append(report.Diagnostics, diags...)
+	}
+
+	for i, d := range report.Diagnostics {
+		if *strict && d.Severity == severityWarning {
+			report.Diagnostics[i].Severity = severityError
+			d.Severity = severityError
+		}
+		if d.Severity == severityError {
+			report.Errors++
+		} else {
+			report.Warnings++
+		}
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(stderr, "failed to write report: %v\n", err)
+			return 2
+		}
+	} else {
+		writeLintText(stdout, report)
+	}
+
+	if report.Errors > 0 {
+		return 1
+	}
+	return 0
+}
+
+// expandQuestionPaths turns the command-line arguments into a sorted list of files,
+// descending one level into directories to pick up question banks
+func expandQuestionPaths(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("cannot access %s: %w", p, err)
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		matches, err := globQuestionBanks(p)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list %s: %w", p, err)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// writeLintText prints diagnostics in a compiler-like "file:index: severity: message" layout
+func writeLintText(w io.Writer, report lintReport) {
+	for _, d := range report.Diagnostics {
+		location := d.File
+		if d.Index >= 0 {
+			location = fmt.Sprintf("%s:#%d", d.File, d.Index)
+			if d.ID != "" {
+				location += fmt.Sprintf(" (id: %s)", d.ID)
+			}
+		}
+		fmt.Fprintf(w, "%s: %s: %s [%s]\n", location, d.Severity, d.Message, d.Rule)
+	}
+	for _, f := range report.Formatted {
+		fmt.Fprintf(w, "%s: formatted\n", f)
+	}
+	fmt.Fprintf(w, "%d file(s) checked, %d error(s), %d warning(s)\n", report.Files, report.Errors, report.Warnings)
+}
+
+// lintQuestionFile checks a single question file and optionally rewrites it in canonical form.
+// The second return value reports whether the file was rewritten.
+func lintQuestionFile(filename string, fix bool) ([]lintDiagnostic, bool) {
+	fileDiag := func(rule, msg string) lintDiagnostic {
+		return lintDiagnostic{File: filename, Index: -1, Severity: severityError, Rule: rule, Message: msg}
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return []lintDiagnostic{fileDiag("read", fmt.Sprintf("failed to read questions file: %v", err))}, false
+	}
+
+	format, err := questionFormatFromPath(filename)
+	if err != nil {
+		return []lintDiagnostic{fileDiag("extension", err.Error())}, false
+	}
+
+	// Decode strictly so typos in field or column names are reported instead of silently ignored
+	questions, err := decodeQuestions(data, format, true)
+	if err != nil {
+		rule := "parse"
+		msg := err.Error()
+		if strings.Contains(msg, "unknown field") || strings.Contains(msg, "not found in type") || strings.Contains(msg, "unknown column") {
+			rule = "unknown-field"
+		}
+		return []lintDiagnostic{fileDiag(rule, msg)}, false
+	}
+
+	diags := lintQuestions(filename, questions)
+
+	canonical, err := encodeQuestions(questions, format)
+	if err != nil {
+		return append(diags, fileDiag("format", fmt.Sprintf("failed to format questions: %v", err))), false
+	}
+	if bytes.Equal(data, canonical) {
+		return diags, false
+	}
+	if !fix {
+		d := fileDiag("format", "file is not canonically formatted (run with -fix)")
+		d.Severity = severityWarning
+		return append(diags, d), false
+	}
+	if err := os.WriteFile(filename, canonical, 0644); err != nil {
+		return append(diags, fileDiag("write", fmt.Sprintf("failed to write formatted file: %v", err))), false
+	}
+	return diags, true
+}
+
+// lintQuestions applies loadQuestions' validation plus stricter content checks to a parsed bank
+func lintQuestions(filename string, questions []Question) []lintDiagnostic {
+	var diags []lintDiagnostic
+	report := func(i int, q Question, severity, rule, format string, args ...any) {
+		diags = append(diags, lintDiagnostic{
+			File:     filename,
+			Index:    i,
+			ID:       q.ID,
+			Severity: severity,
+			Rule:     rule,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	if len(questions) == 0 {
+		diags = append(diags, lintDiagnostic{File: filename, Index: -1, Severity: severityWarning, Rule: "empty-bank", Message: "file contains no questions"})
+	}
+
+	seenIDs := make(map[string]int)
+	for i, q := range questions {
+		// Same checks the server applies at startup
+		if err := validateQuestion(i, q); err != nil {
+			report(i, q, severityError, "answer-index", "%v", err)
+		}
+
+		if strings.TrimSpace(q.ID) == "" {
+			report(i, q, severityError, "missing-id", "question has no id")
+		} else if first, dup := seenIDs[q.ID]; dup {
+			report(i, q, severityError, "duplicate-id", "id %q is already used by question #%d", q.ID, first)
+		} else {
+			seenIDs[q.ID] = i
+		}
+		if strings.TrimSpace(q.Question) == "" {
+			report(i, q, severityError, "missing-question", "question text is empty")
+		}
+		if len(q.Choices) < 2 {
+			report(i, q, severityError, "too-few-choices", "question has %d choice(s), need at least 2", len(q.Choices))
+		}
+
+		seenChoices := make(map[string]int)
+		for j, c := range q.Choices {
+			key := strings.ToLower(strings.TrimSpace(c))
+			if key == "" {
+				report(i, q, severityError, "empty-choice", "choice %d is empty", j)
+				continue
+			}
+			if first, dup := seenChoices[key]; dup {
+				report(i, q, severityError, "duplicate-choice", "choice %d duplicates choice %d (%q)", j, first, c)
+			} else {
+				seenChoices[key] = j
+			}
+			if c != strings.TrimSpace(c) {
+				report(i, q, severityWarning, "whitespace", "choice %d has leading or trailing whitespace", j)
+			}
+		}
+
+		if strings.TrimSpace(q.Explanation) == "" {
+			report(i, q, severityWarning, "missing-explanation", "question has no explanation")
+		}
+		if q.ID != strings.TrimSpace(q.ID) || q.Question != strings.TrimSpace(q.Question) || q.Explanation != strings.TrimSpace(q.Explanation) {
+			report(i, q, severityWarning, "whitespace", "id, question or explanation has leading or trailing whitespace")
+		}
+
+		langs := make([]string, 0, len(q.Translations))
+		for lang := range q.Translations {
+			langs = append(langs, lang)
+		}
+		sort.Strings(langs)
+		for _, lang := range langs {
+			t := q.Translations[lang]
+			if lang == defaultLanguage {
+				report(i, q, severityWarning, "translation-language", "%q translation duplicates the default language", lang)
+			} else if _, ok := messageCatalogs[lang]; !ok {
+				report(i, q, severityWarning, "translation-language", "no UI catalog for language %q, so this translation is never shown", lang)
+			}
+			if len(t.Choices) > 0 && len(t.Choices) != len(q.Choices) {
+				report(i, q, severityError, "translation-choices", "%s translation has %d choices, want %d in the original order", lang, len(t.Choices), len(q.Choices))
+			}
+			for j, c := range t.Choices {
+				if strings.TrimSpace(c) == "" {
+					report(i, q, severityError, "empty-choice", "%s choice %d is empty", lang, j)
+				}
+			}
+			if t.Question == "" && len(t.Choices) == 0 && t.Explanation == "" {
+				report(i, q, severityWarning, "translation-empty", "%s translation has no fields", lang)
+			}
+		}
+	}
+
+	return diags
+}
+
+// formatQuestions renders a question bank in the canonical on-disk layout used by
+// questions.json: two-space indentation, one field per line and choices on a single line
+func formatQuestions(questions []Question) ([]byte, error) {
+	var buf bytes.Buffer
+	if len(questions) == 0 {
+		buf.WriteString("[]\n")
+		return buf.Bytes(), nil
+	}
+
+	buf.WriteString("[\n")
+	for i, q := range questions {
+		fields := []struct {
+			key   string
+			value any
+		}{
+			{"id", q.ID},
+			{"question", q.Question},
+			{"choices", q.Choices},
+			{"answer_index", q.AnswerIndex},
+			{"explanation", q.Explanation},
+		}
+
+		buf.WriteString("  {\n")
+		for j, f := range fields {
+			value, err := marshalCompact(f.value)
+			if err != nil {
+				return nil, fmt.Errorf("question %d (id: %s): %w", i, q.ID, err)
+			}
+			fmt.Fprintf(&buf, "    %q: %s", f.key, value)
+			if j < len(fields)-1 || len(q.Translations) > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		if len(q.Translations) > 0 {
+			if err := formatTranslations(&buf, q.Translations); err != nil {
+				return nil, fmt.Errorf("question %d (id: %s): %w", i, q.ID, err)
+			}
+		}
+		buf.WriteString("  }")
+		if i < len(questions)-1 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("]\n")
+	return buf.Bytes(), nil
+}
+
+// formatTranslations writes a question's translations block, languages sorted by code
+// and only the fields each translation sets
+func formatTranslations(buf *bytes.Buffer, translations map[string]QuestionTranslation) error {
+	langs := make([]string, 0, len(translations))
+	for lang := range translations {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	buf.WriteString("    \"translations\": {\n")
+	for i, lang := range langs {
+		t := translations[lang]
+		var fields []string
+		for _, f := range []struct {
+			key   string
+			value any
+			set   bool
+		}{
+			{"question", t.Question, t.Question != ""},
+			{"choices", t.Choices, len(t.Choices) > 0},
+			{"explanation", t.Explanation, t.Explanation != ""},
+		} {
+			if !f.set {
+				continue
+			}
+			value, err := marshalCompact(f.value)
+			if err != nil {
+				return err
+			}
+			fields = append(fields, fmt.Sprintf("        %q: %s", f.key, value))
+		}
+
+		langKey, err := marshalCompact(lang)
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			fmt.Fprintf(buf, "      %s: {}", langKey)
+		} else {
+			fmt.Fprintf(buf, "      %s: {\n%s\n      }", langKey, strings.Join(fields, ",\n"))
+		}
+		if i < len(langs)-1 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("    }\n")
+	return nil
+}
+
+// marshalCompact encodes a value as single-line JSON without HTML escaping,
+// with a space after each comma in arrays to match the hand-written files
+func marshalCompact(v any) (string, error) {
+	if v == nil {
+		return "null", nil
+	}
+	if items, ok := v.([]string); ok {
+		if items == nil {
+			return "[]", nil
+		}
+		parts := make([]string, len(items))
+		for i, item := range items {
+			s, err := marshalCompact(item)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return "[" + strings.Join(parts, ", ") + "]", nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeLintFixture writes content to a temporary question file and returns its path
+func writeLintFixture(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+	return path
+}
+
+// TestLintQuestions_BundledBanksAreClean tests that the shipped question files pass the linter
+func TestLintQuestions_BundledBanksAreClean(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runLintQuestions([]string{"questions.json", "tarot_questions.json"}, &stdout, &stderr)
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d\nstdout: %s\nstderr: %s", code, stdout.String(), stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "2 file(s) checked, 0 error(s), 0 warning(s)") {
+		t.Errorf("unexpected summary: %s", stdout.String())
+	}
+}
+
+// TestLintQuestions_Rules tests that each stricter check produces the expected rule
+func TestLintQuestions_Rules(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		rule     string
+		severity string
+	}{
+		{
+			name:     "answer index out of range",
+			content:  `[{"id": "q1", "question": "Q?", "choices": ["A", "B"], "answer_index": 2, "explanation": "E"}]`,
+			rule:     "answer-index",
+			severity: severityError,
+		},
+		{
+			name: "duplicate id",
+			content: `[{"id": "q1", "question": "Q?", "choices": ["A", "B"], "answer_index": 0, "explanation": "E"},
+				{"id": "q1", "question": "Q2?", "choices": ["A", "B"], "answer_index": 0, "explanation": "E"}]`,
+			rule:     "duplicate-id",
+			severity: severityError,
+		},
+		{
+			name:     "missing id",
+			content:  `[{"question": "Q?", "choices": ["A", "B"], "answer_index": 0, "explanation": "E"}]`,
+			rule:     "missing-id",
+			severity: severityError,
+		},
+		{
+			name:     "too few choices",
+			content:  `[{"id": "q1", "question": "Q?", "choices": ["A"], "answer_index": 0, "explanation": "E"}]`,
+			rule:     "too-few-choices",
+			severity: severityError,
+		},
+		{
+			name:     "duplicate choice",
+			content:  `[{"id": "q1", "question": "Q?", "choices": ["Fire", " fire"], "answer_index": 0, "explanation": "E"}]`,
+			rule:     "duplicate-choice",
+			severity: severityError,
+		},
+		{
+			name:     "unknown field",
+			content:  `[{"id": "q1", "question": "Q?", "choices": ["A", "B"], "answer_idx": 0, "explanation": "E"}]`,
+			rule:     "unknown-field",
+			severity: severityError,
+		},
+		{
+			name:     "missing explanation",
+			content:  `[{"id": "q1", "question": "Q?", "choices": ["A", "B"], "answer_index": 0}]`,
+			rule:     "missing-explanation",
+			severity: severityWarning,
+		},
+		{
+			name:     "translated choices out of step",
+			content:  `[{"id": "q1", "question": "Q?", "choices": ["A", "B"], "answer_index": 0, "explanation": "E", "translations": {"es": {"choices": ["A"]}}}]`,
+			rule:     "translation-choices",
+			severity: severityError,
+		},
+		{
+			name:     "translation without a UI catalog",
+			content:  `[{"id": "q1", "question": "Q?", "choices": ["A", "B"], "answer_index": 0, "explanation": "E", "translations": {"xx": {"question": "Q-xx?"}}}]`,
+			rule:     "translation-language",
+			severity: severityWarning,
+		},
+		{
+			name:     "empty translation",
+			content:  `[{"id": "q1", "question": "Q?", "choices": ["A", "B"], "answer_index": 0, "explanation": "E", "translations": {"es": {}}}]`,
+			rule:     "translation-empty",
+			severity: severityWarning,
+		},
+		{
+			name:     "non-canonical formatting",
+			content:  `[{"id": "q1", "question": "Q?", "choices": ["A", "B"], "answer_index": 0, "explanation": "E"}]`,
+			rule:     "format",
+			severity: severityWarning,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeLintFixture(t, "bank.json", tt.content)
+
+			diags, formatted := lintQuestionFile(path, false)
+			if formatted {
+				t.Error("file should not be rewritten without -fix")
+			}
+
+			found := false
+			for _, d := range diags {
+				if d.Rule == tt.rule {
+					found = true
+					if d.Severity != tt.severity {
+						t.Errorf("expected severity %s for %s, got %s", tt.severity, tt.rule, d.Severity)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("expected a %s diagnostic, got %+v", tt.rule, diags)
+			}
+		})
+	}
+}
+
+// TestLintQuestions_ExitCodeAndJSON tests the machine-readable report and non-zero exit on errors
+func TestLintQuestions_ExitCodeAndJSON(t *testing.T) {
+	path := writeLintFixture(t, "bad.json", `[{"id": "q1", "question": "", "choices": ["A", "B"], "answer_index": 0, "explanation": "E"}]`)
+
+	var stdout, stderr bytes.Buffer
+	code := runLintQuestions([]string{"-format", "json", path}, &stdout, &stderr)
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+
+	var report lintReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, stdout.String())
+	}
+	if report.Errors != 1 {
+		t.Errorf("expected 1 error, got %d: %+v", report.Errors, report.Diagnostics)
+	}
+	if report.Diagnostics[0].Rule != "missing-question" {
+		t.Errorf("expected missing-question rule, got %s", report.Diagnostics[0].Rule)
+	}
+}
+
+// TestLintQuestions_Strict tests that -strict promotes warnings to errors
+func TestLintQuestions_Strict(t *testing.T) {
+	path := writeLintFixture(t, "bank.json", `[{"id": "q1", "question": "Q?", "choices": ["A", "B"], "answer_index": 0}]`)
+
+	var stdout, stderr bytes.Buffer
+	if code := runLintQuestions([]string{path}, &stdout, &stderr); code != 0 {
+		t.Errorf("expected warnings alone to exit 0, got %d", code)
+	}
+	if code := runLintQuestions([]string{"-strict", path}, &stdout, &stderr); code != 1 {
+		t.Errorf("expected -strict to exit 1, got %d", code)
+	}
+}
+
+// TestLintQuestions_Fix tests that -fix rewrites files canonically and is idempotent
+func TestLintQuestions_Fix(t *testing.T) {
+	path := writeLintFixture(t, "bank.json", `[{"explanation": "Fire & ice", "id": "q1", "question": "Which <element>?", "choices": ["Fire","Ice"], "answer_index": 1}]`)
+
+	var stdout, stderr bytes.Buffer
+	if code := runLintQuestions([]string{"-fix", path}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "formatted") {
+		t.Errorf("expected file to be reported as formatted, got %s", stdout.String())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read formatted file: %v", err)
+	}
+	expected := `[
+  {
+    "id": "q1",
+    "question": "Which <element>?",
+    "choices": ["Fire", "Ice"],
+    "answer_index": 1,
+    "explanation": "Fire & ice"
+  }
+]
+`
+	if string(data) != expected {
+		t.Errorf("unexpected canonical output:\n%s", data)
+	}
+
+	// A second run must leave the file untouched
+	diags, formatted := lintQuestionFile(path, true)
+	if formatted || len(diags) != 0 {
+		t.Errorf("expected formatted file to be stable, got formatted=%v diags=%+v", formatted, diags)
+	}
+}
+
+// TestLintQuestions_Usage tests argument errors
+func TestLintQuestions_Usage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "no paths", args: []string{}},
+		{name: "unknown format", args: []string{"-format", "xml", "questions.json"}},
+		{name: "missing file", args: []string{"/nonexistent/questions.json"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := runLintQuestions(tt.args, &stdout, &stderr); code != 2 {
+				t.Errorf("expected exit code 2, got %d", code)
+			}
+		})
+	}
+}
+
+// TestLintQuestions_OtherFormats tests that CSV and YAML banks are linted with strict decoding
+func TestLintQuestions_OtherFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		rule    string
+	}{
+		{
+			name:    "clean csv",
+			file:    "bank.csv",
+			content: "id,question,answer_index,explanation,choice_1,choice_2\nq1,Q?,0,E,A,B\n",
+		},
+		{
+			name:    "csv unknown column",
+			file:    "bank.csv",
+			content: "id,question,answer_index,explanation,choice_1,hint\nq1,Q?,0,E,A,B\n",
+			rule:    "unknown-field",
+		},
+		{
+			name:    "yaml unknown key",
+			file:    "bank.yaml",
+			content: "- id: q1\n  question: Q?\n  choices: [A, B]\n  answer_index: 0\n  hint: E\n",
+			rule:    "unknown-field",
+		},
+		{
+			name:    "unsupported extension",
+			file:    "bank.txt",
+			content: "[]",
+			rule:    "extension",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeLintFixture(t, tt.file, tt.content)
+			diags, _ := lintQuestionFile(path, false)
+
+			if tt.rule == "" {
+				if len(diags) != 0 {
+					t.Errorf("expected no diagnostics, got %+v", diags)
+				}
+				return
+			}
+			if len(diags) != 1 || diags[0].Rule != tt.rule {
+				t.Errorf("expected a single %s diagnostic, got %+v", tt.rule, diags)
+			}
+		})
+	}
+}
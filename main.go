@@ -1,18 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
-	_ "embed"
+	"errors"
 	"flag"
 	"fmt"
-	"html/template"
 	"log"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"sort"
@@ -23,25 +21,23 @@ import (
 	"time"
 )
 
-//go:embed home.html
-var homeHTML string
-
-//go:embed quiz.html
-var quizHTML string
-
-//go:embed results.html
-var resultsHTML string
-
-//go:embed leaderboard.html
-var leaderboardHTML string
-
 // Question represents an astrology trivia question
 type Question struct {
-	ID          string   `json:"id"`
-	Question    string   `json:"question"`
-	Choices     []string `json:"choices"`
-	AnswerIndex int      `json:"answer_index"`
-	Explanation string   `json:"explanation"`
+	ID          string   `json:"id" yaml:"id"`
+	Question    string   `json:"question" yaml:"question"`
+	Choices     []string `json:"choices" yaml:"choices"`
+	AnswerIndex int      `json:"answer_index" yaml:"answer_index"`
+	Explanation string   `json:"explanation" yaml:"explanation"`
+	// Translations holds localized variants keyed by language code; missing fields fall back to the fields above
+	Translations map[string]QuestionTranslation `json:"translations,omitempty" yaml:"translations,omitempty"`
+}
+
+// QuestionTranslation is the localized text of a question in one language
+// Choices must be in the same order as the original, since answers are checked by index.
+type QuestionTranslation struct {
+	Question    string   `json:"question,omitempty" yaml:"question,omitempty"`
+	Choices     []string `json:"choices,omitempty" yaml:"choices,omitempty"`
+	Explanation string   `json:"explanation,omitempty" yaml:"explanation,omitempty"`
 }
 
 // QuizState represents the client-side quiz state
@@ -49,7 +45,27 @@ type QuizState struct {
 	QuestionIDs  []string `json:"question_ids"`
 	CurrentIndex int      `json:"current_index"`
 	Score        int      `json:"score"`
-	QuizType     string   `json:"quiz_type"` // "astrology" or "tarot"
+	QuizType     string   `json:"quiz_type"`        // "astrology" or "tarot"
+	RunID        string   `json:"run_id,omitempty"` // binds the run's states to the step ledger
+	// Practice runs are untimed drills over the whole bank and are never accepted by the leaderboard
+	Practice       bool     `json:"practice,omitempty"`
+	Missed         []string `json:"missed,omitempty"`           // practice: IDs answered incorrectly, for re-review
+	LastQuestionID string   `json:"last_question_id,omitempty"` // practice: the question just answered, for feedback
+	LastAnswer     string   `json:"last_answer,omitempty"`      // practice: the answer submitted for LastQuestionID
+	Study          bool     `json:"study,omitempty"`            // practice run scheduled from, and recorded to, the player's study cards
+	AskedAt        int64    `json:"asked_at,omitempty"`         // Unix milliseconds when the current question was first shown
+	StartedAt      int64    `json:"started_at,omitempty"`       // Unix milliseconds when the first question was shown
+	FinishedAt     int64    `json:"finished_at,omitempty"`      // Unix milliseconds when the last answer was graded
+	EmbedOrigin    string   `json:"embed_origin,omitempty"`     // embedded runs: the verified origin of the page told the result
+}
+
+// Duration returns how long the run took from its first question to its last answer, or 0
+// when either end was not recorded (a state from before runs were timed, or a run still in progress)
+func (s *QuizState) Duration() time.Duration {
+	if s.StartedAt == 0 || s.FinishedAt < s.StartedAt {
+		return 0
+	}
+	return time.Duration(s.FinishedAt-s.StartedAt) * time.Millisecond
 }
 
 // LeaderboardEntry represents a single leaderboard entry
@@ -58,20 +74,43 @@ type LeaderboardEntry struct {
 	Score    int       `json:"score"`
 	Total    int       `json:"total"`
 	When     time.Time `json:"when"`
-	QuizType string    `json:"quiz_type"` // "astrology" or "tarot"
+	QuizType string    `json:"quiz_type"`          // "astrology" or "tarot"
+	Verified bool      `json:"verified,omitempty"` // submitted by a logged-in account named Name
+	// DurationMS is the run's total time, measured server-side from its signed timestamps; 0 if unknown
+	DurationMS int64 `json:"duration_ms,omitempty"`
+	// ID identifies the entry so its submitter can find it on the board; empty for old entries
+	ID string `json:"id,omitempty"`
+}
+
+// DurationText formats the run's total time for display, or "" when it is unknown
+func (e LeaderboardEntry) DurationText() string {
+	if e.DurationMS <= 0 {
+		return ""
+	}
+	return (time.Duration(e.DurationMS) * time.Millisecond).Round(100 * time.Millisecond).String()
 }
 
 // LeaderboardManager manages the leaderboard with thread-safe access
 type LeaderboardManager struct {
-	mu      sync.Mutex
-	entries []LeaderboardEntry
+	mu       sync.Mutex
+	entries  []LeaderboardEntry // score history in rank order, bounded by the storage policy
+	version  uint64             // incremented whenever the ranking changes
+	modified time.Time          // when the entries last changed, for feed caching
+	events   *broadcaster       // live update subscribers, created on first use
+	logged   int                // entries appended to the log since the file was last written
+
+	tops map[string]*leaderboardWindowTop // each window's top entries, kept current as entries are added
 }
 
 // Constants for leaderboard configuration
 const (
-	MaxLeaderboardSize   = 20
-	leaderboardFilename  = "leaderboard.json"
-	NumQuestions         = 3  // Number of questions per quiz
+	MaxLeaderboardSize        = 20 // entries shown on each leaderboard window
+	leaderboardNeighborRadius = 2  // entries shown above and below a player's own rank outside the top
+	leaderboardEntryIDBytes   = 8
+	leaderboardFilename       = "leaderboard.json"
+	leaderboardLogFilename    = "leaderboard.log" // entries added since leaderboardFilename was last written
+	leaderboardCompactEvery   = 500               // logged entries after which the history is pruned and rewritten
+	NumQuestions              = 3                 // Number of questions per quiz
 )
 
 // Global instances
@@ -80,83 +119,74 @@ var (
 	questionSets       map[string][]Question // map[quizType][]Question
 )
 
-// hmacSecret is used to sign and verify quiz state
-// In production, this should be loaded from environment variables
-const hmacSecret = "astrology-quiz-secret-key-change-in-production"
-
-// signQuizState generates an HMAC-SHA256 signature for a quiz state
-func signQuizState(state QuizState) (string, error) {
-	// Serialize state to JSON
-	stateJSON, err := json.Marshal(state)
+// loadQuestions loads questions from a JSON, CSV or YAML file and validates them
+// The format is chosen by file extension; unknown extensions are read as JSON.
+func loadQuestions(filename string) ([]Question, error) {
+	// Read the file
+	data, err := os.ReadFile(filename)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal state: %w", err)
+		return nil, fmt.Errorf("failed to read questions file: %w", err)
 	}
 
-	// Create HMAC-SHA256 hash
-	mac := hmac.New(sha256.New, []byte(hmacSecret))
-	mac.Write(stateJSON)
-
-	// Compute signature and encode to hex
-	signature := mac.Sum(nil)
-	return hex.EncodeToString(signature), nil
-}
-
-// verifyQuizState verifies the HMAC signature and returns the deserialized state
-func verifyQuizState(stateJSON string, signature string) (*QuizState, bool) {
-	// Decode the provided signature from hex
-	providedSig, err := hex.DecodeString(signature)
+	// Parse according to the file format
+	format, err := questionFormatFromPath(filename)
 	if err != nil {
-		return nil, false
+		format = formatJSON
 	}
-
-	// Re-compute HMAC for the received JSON
-	mac := hmac.New(sha256.New, []byte(hmacSecret))
-	mac.Write([]byte(stateJSON))
-	expectedSig := mac.Sum(nil)
-
-	// Compare signatures using constant-time comparison
-	if !hmac.Equal(providedSig, expectedSig) {
-		return nil, false
+	questions, err := decodeQuestions(data, format, false)
+	if err != nil {
+		return nil, err
 	}
 
-	// Deserialize JSON to QuizState
-	var state QuizState
-	if err := json.Unmarshal([]byte(stateJSON), &state); err != nil {
-		return nil, false
+	// Validate each question
+	for i, q := range questions {
+		if err := validateQuestion(i, q); err != nil {
+			return nil, err
+		}
+		if err := validateTranslations(i, q); err != nil {
+			return nil, err
+		}
 	}
 
-	return &state, true
+	return questions, nil
 }
 
-// loadQuestions loads questions from a JSON file and validates them
-func loadQuestions(filename string) ([]Question, error) {
-	// Read the file
-	data, err := os.ReadFile(filename)
+// loadQuestionBank loads the bank stored as base.json, base.yaml, base.yml or base.csv
+func loadQuestionBank(base string) ([]Question, error) {
+	filename, err := findQuestionBank(base)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read questions file: %w", err)
+		return nil, err
 	}
+	return loadQuestions(filename)
+}
 
-	// Parse JSON
-	var questions []Question
-	if err := json.Unmarshal(data, &questions); err != nil {
-		return nil, fmt.Errorf("failed to parse questions JSON: %w", err)
+// validateQuestion checks the invariants every loaded question must satisfy
+func validateQuestion(i int, q Question) error {
+	if q.AnswerIndex < 0 {
+		return fmt.Errorf("question %d (id: %s) has invalid answer_index: %d (must be >= 0)", i, q.ID, q.AnswerIndex)
+	}
+	if q.AnswerIndex >= len(q.Choices) {
+		return fmt.Errorf("question %d (id: %s) has invalid answer_index: %d (must be < %d choices)", i, q.ID, q.AnswerIndex, len(q.Choices))
 	}
+	return nil
+}
 
-	// Validate each question
-	for i, q := range questions {
-		if q.AnswerIndex < 0 {
-			return nil, fmt.Errorf("question %d (id: %s) has invalid answer_index: %d (must be >= 0)", i, q.ID, q.AnswerIndex)
-		}
-		if q.AnswerIndex >= len(q.Choices) {
-			return nil, fmt.Errorf("question %d (id: %s) has invalid answer_index: %d (must be < %d choices)", i, q.ID, q.AnswerIndex, len(q.Choices))
+// validateTranslations checks that translated choices line up with the original choices
+func validateTranslations(i int, q Question) error {
+	for lang, t := range q.Translations {
+		if len(t.Choices) > 0 && len(t.Choices) != len(q.Choices) {
+			return fmt.Errorf("question %d (id: %s) has %d %s choices (must match the %d original choices)", i, q.ID, len(t.Choices), lang, len(q.Choices))
 		}
 	}
-
-	return questions, nil
+	return nil
 }
 
-// loadLeaderboard loads leaderboard entries from the JSON file
+// loadLeaderboard loads leaderboard entries from the JSON file, then adds those logged since it
+// was last written
 func loadLeaderboard() error {
+	// Versions restart at zero, so cached feeds must revalidate against the load time
+	leaderboardManager.modified = time.Now()
+
 	// Read the file
 	data, err := os.ReadFile(leaderboardFilename)
 	if err != nil {
@@ -167,7 +197,7 @@ func loadLeaderboard() error {
 				return fmt.Errorf("failed to create leaderboard file: %w", err)
 			}
 			leaderboardManager.entries = []LeaderboardEntry{}
-			return nil
+			return replayLeaderboardLogLocked(time.Now())
 		}
 		return fmt.Errorf("failed to read leaderboard file: %w", err)
 	}
@@ -178,11 +208,20 @@ func loadLeaderboard() error {
 		return fmt.Errorf("failed to parse leaderboard JSON: %w", err)
 	}
 
+	// Entries are kept in rank order so each window's top scores are found by a single scan.
+	// Files ranked the old way (by raw score) are re-ranked and rewritten once.
+	entries, changed := migrateLeaderboard(entries)
 	leaderboardManager.entries = entries
-	return nil
+	if changed {
+		if err := rewriteMigratedLeaderboardLocked(data); err != nil {
+			return err
+		}
+	}
+	return replayLeaderboardLogLocked(time.Now())
 }
 
-// saveLeaderboard persists the current leaderboard entries to the JSON file
+// saveLeaderboard persists the current leaderboard entries to the JSON file, replacing it in
+// one step so a crash never leaves it half written
 func saveLeaderboard() error {
 	// Marshal entries to JSON with indentation
 	data, err := json.MarshalIndent(leaderboardManager.entries, "", "  ")
@@ -190,49 +229,157 @@ func saveLeaderboard() error {
 		return fmt.Errorf("failed to marshal leaderboard: %w", err)
 	}
 
-	// Write to file
-	if err := os.WriteFile(leaderboardFilename, data, 0644); err != nil {
+	// Write to a temporary file, then move it into place
+	tmp := leaderboardFilename + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write leaderboard file: %w", err)
+	}
+	if err := os.Rename(tmp, leaderboardFilename); err != nil {
 		return fmt.Errorf("failed to write leaderboard file: %w", err)
 	}
 
 	return nil
 }
 
-// saveScore adds a new score to the leaderboard in a thread-safe manner
-func saveScore(name string, score int, total int, quizType string) error {
+// appendLeaderboardLogLocked persists one new entry by appending it to the log as a line of
+// JSON. The caller must hold leaderboardManager.mu.
+func appendLeaderboardLogLocked(entry LeaderboardEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal leaderboard entry: %w", err)
+	}
+
+	file, err := os.OpenFile(leaderboardLogFilename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open leaderboard log: %w", err)
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write leaderboard log: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write leaderboard log: %w", err)
+	}
+	leaderboardManager.logged++
+	return nil
+}
+
+// replayLeaderboardLogLocked adds the entries logged since the file was last written, skipping
+// any the file already holds, and folds them into the file. A line a crash cut short is
+// skipped. The caller must hold leaderboardManager.mu.
+func replayLeaderboardLogLocked(now time.Time) error {
+	data, err := os.ReadFile(leaderboardLogFilename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read leaderboard log: %w", err)
+	}
+
+	stored := make(map[string]bool, len(leaderboardManager.entries))
+	for _, e := range leaderboardManager.entries {
+		stored[e.ID] = true
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var entry LeaderboardEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			log.Printf("Skipping unreadable leaderboard log entry: %v", err)
+			continue
+		}
+		if stored[entry.ID] {
+			continue
+		}
+		stored[entry.ID] = true
+		insertLeaderboardEntryLocked(entry)
+	}
+	return compactLeaderboardLocked(now)
+}
+
+// compactLeaderboardLocked applies the storage policy, rewrites the file with the whole history
+// and empties the log. The caller must hold leaderboardManager.mu.
+func compactLeaderboardLocked(now time.Time) error {
+	stored := len(leaderboardManager.entries)
+	pruneLeaderboardLocked(now)
+	if len(leaderboardManager.entries) != stored {
+		leaderboardManager.version++
+		leaderboardManager.modified = now
+	}
+
+	if err := saveLeaderboard(); err != nil {
+		return err
+	}
+	if err := os.Remove(leaderboardLogFilename); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to empty leaderboard log: %w", err)
+	}
+	leaderboardManager.logged = 0
+	return nil
+}
+
+// compactLeaderboard folds the log into the file, so the next start reads a single file
+func compactLeaderboard() error {
 	leaderboardManager.mu.Lock()
 	defer leaderboardManager.mu.Unlock()
 
+	return compactLeaderboardLocked(time.Now())
+}
+
+// saveScore adds a new score to the leaderboard in a thread-safe manner
+func saveScore(name string, score int, total int, quizType string) error {
 	// Create new entry with current timestamp
-	entry := LeaderboardEntry{
+	_, err := addLeaderboardEntry(LeaderboardEntry{
 		Name:     name,
 		Score:    score,
 		Total:    total,
 		When:     time.Now(),
 		QuizType: quizType,
+	})
+	return err
+}
+
+// addLeaderboardEntry ranks a fully populated entry into the score history, persists it and
+// returns the entry's ID
+func addLeaderboardEntry(entry LeaderboardEntry) (string, error) {
+	leaderboardManager.mu.Lock()
+	defer leaderboardManager.mu.Unlock()
+
+	if entry.ID == "" {
+		entry.ID = randomToken(leaderboardEntryIDBytes)
 	}
 
-	// Append to entries
-	leaderboardManager.entries = append(leaderboardManager.entries, entry)
+	// Persist by appending to the log; the whole history is rewritten only when it is compacted
+	if err := appendLeaderboardLogLocked(entry); err != nil {
+		return "", err
+	}
 
-	// Sort entries: Score DESC (higher first), When ASC (earlier first for same score)
-	sort.Slice(leaderboardManager.entries, func(i, j int) bool {
-		if leaderboardManager.entries[i].Score != leaderboardManager.entries[j].Score {
-			return leaderboardManager.entries[i].Score > leaderboardManager.entries[j].Score
-		}
-		return leaderboardManager.entries[i].When.Before(leaderboardManager.entries[j].When)
-	})
+	// Bring each window's board up to date, so the entry is placed against its current cutoff
+	now := time.Now()
+	for _, window := range leaderboardWindows {
+		leaderboardWindowTopLocked(window, now)
+	}
 
-	// Truncate to MaxLeaderboardSize if necessary
-	if len(leaderboardManager.entries) > MaxLeaderboardSize {
-		leaderboardManager.entries = leaderboardManager.entries[:MaxLeaderboardSize]
+	// Insert in rank order: percentage DESC, Score DESC, then the configured tie-break
+	onBoard := insertLeaderboardEntryLocked(entry)
+	leaderboardManager.version++
+	leaderboardManager.modified = now
+
+	if leaderboardManager.logged >= leaderboardCompactEvery {
+		if err := compactLeaderboardLocked(now); err != nil {
+			// The entry is safe in the log; compaction is retried with the next one
+			log.Printf("Error compacting leaderboard: %v", err)
+		}
 	}
 
-	// Persist to file
-	return saveLeaderboard()
+	// Only notify live viewers when the new entry actually made one of the boards
+	if onBoard {
+		publishLeaderboardLocked()
+	}
+	return entry.ID, nil
 }
 
-// getLeaderboard returns a copy of the current leaderboard entries
+// getLeaderboard returns a copy of the whole score history in rank order
 func getLeaderboard() []LeaderboardEntry {
 	leaderboardManager.mu.Lock()
 	defer leaderboardManager.mu.Unlock()
@@ -253,6 +400,11 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap exposes the underlying writer so http.ResponseController can flush streaming responses
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // validatePort ensures port is in valid range (1-65535)
 func validatePort(port int) int {
 	if port < 1 || port > 65535 {
@@ -279,15 +431,58 @@ func loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// homeHandler serves the embedded HTML home page
+// homeHandler serves the embedded HTML home page in the visitor's language
 func homeHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(homeHTML))
+
+	lang := requestLanguage(w, r)
+	renderPage(w, "home", lang, HomePageData{Quizzes: buildQuizSummaries(lang)})
+}
+
+// HomePageData represents the data passed to the home.html template
+type HomePageData struct {
+	Quizzes []QuizSummary
+}
+
+// QuizSummary describes one playable quiz type on the home page
+type QuizSummary struct {
+	Type          string
+	Theme         Theme
+	QuestionCount int
+	Best          *LeaderboardEntry // top leaderboard entry for this type, nil when nobody has played
+}
+
+// buildQuizSummaries lists every loaded quiz type with questions, sorted by type name
+func buildQuizSummaries(lang string) []QuizSummary {
+	now := time.Now()
+
+	summaries := make([]QuizSummary, 0, len(questionSets))
+	for quizType, questions := range questionSets {
+		if len(questions) == 0 {
+			continue
+		}
+		summary := QuizSummary{
+			Type:          quizType,
+			Theme:         themeFor(quizType, lang),
+			QuestionCount: len(questions),
+		}
+		// Types without a theme borrow the default colors but not its copy
+		if _, ok := themes[quizType]; !ok {
+			summary.Theme.Title = quizType
+			summary.Theme.Intro = ""
+		}
+		if best := topLeaderboard(windowAllTime, quizType, 1, now); len(best) > 0 {
+			summary.Best = &best[0]
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Type < summaries[j].Type
+	})
+	return summaries
 }
 
 // healthHandler serves the health check endpoint
@@ -303,23 +498,47 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 
 // QuizPageData represents the data passed to the quiz.html template
 type QuizPageData struct {
+	Theme          Theme
+	QuizType       string
 	Question       Question
 	CurrentIndex   int
 	TotalQuestions int
 	Score          int
-	QuizState      string
-	Signature      string
+	StateToken     string
+	RunID          string // the run the form answers, so another tab's run is never graded instead
+	Step           int    // 0-indexed question the form answers; stale submissions are ignored
+	Resumed        bool   // the player came back to an unfinished run
+	Practice       bool
+	Feedback       *PracticeFeedback // practice: how the previous answer went
 }
 
-// quizGetHandler handles GET requests to /quiz and starts a new quiz
+// quizGetHandler handles GET requests to /quiz
+// An unfinished run is resumed at its current question unless ?new=1 asks to start over or
+// ?type= and ?practice= name a different quiz or mode; otherwise a new quiz starts.
+// ?practice=1 starts a practice run, and adding ?review=1 drills only the questions missed
+// in the last finished practice run. ?study=1 starts a practice run of the logged-in player's
+// due study cards.
 func quizGetHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	query := r.URL.Query()
+	requestedType := query.Get("type")
+	study := query.Get("study") != ""
+	practice := study || query.Get("practice") != ""
+	if query.Get("new") == "" && query.Get("review") == "" {
+		if run, ok := loadQuizRun(r); ok && run.CurrentIndex < len(run.QuestionIDs) &&
+			(requestedType == "" && !query.Has("practice") && !study ||
+				(requestedType == "" || requestedType == run.QuizType) && run.Practice == practice && run.Study == study) {
+			renderQuizQuestion(w, r, run, false, true)
+			return
+		}
+	}
+
 	// Determine quiz type (default to "astrology")
-	quizType := r.URL.Query().Get("type")
+	quizType := requestedType
 	if quizType == "" {
 		quizType = "astrology"
 	}
@@ -331,85 +550,138 @@ func quizGetHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Select random questions
-	numToSelect := NumQuestions
-	if len(questions) < NumQuestions {
-		numToSelect = len(questions)
+	// Initialize quiz state with random questions
+	now := time.Now()
+	state := &QuizState{
+		CurrentIndex: 0,
+		Score:        0,
+		QuizType:     quizType,
+		RunID:        newQuizRunID(),
+		Practice:     practice,
+		Study:        study,
+		AskedAt:      now.UnixMilli(),
+		StartedAt:    now.UnixMilli(),
 	}
-
-	// Create a copy of question indices and shuffle them
-	indices := make([]int, len(questions))
-	for i := range indices {
-		indices[i] = i
+	if study {
+		// Study sessions need a player to schedule for, and end early when nothing is due
+		account, ok := currentAccount(r)
+		if !ok {
+			http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+		state.QuestionIDs = selectStudyQuestionIDs(account.Username, quizType, questions, studySessionSize, time.Now())
+		if len(state.QuestionIDs) == 0 {
+			http.Redirect(w, r, "/study", http.StatusSeeOther)
+			return
+		}
+	} else if practice {
+		// Practice drills the whole bank, or just the questions missed last time
+		if query.Get("review") != "" {
+			state.QuestionIDs = practiceReviewIDs(r, quizType)
+		}
+		if len(state.QuestionIDs) == 0 {
+			state.QuestionIDs = selectQuestionIDs(questions, len(questions))
+		}
+	} else {
+		state.QuestionIDs = selectQuestionIDs(questions, NumQuestions)
 	}
-	rand.Shuffle(len(indices), func(i, j int) {
-		indices[i], indices[j] = indices[j], indices[i]
-	})
+	answeredSteps.begin(state.RunID, time.Now())
+	recordRunEvent(state, runStarted, time.Now())
 
-	// Select the first numToSelect questions
-	selectedQuestionIDs := make([]string, numToSelect)
-	for i := 0; i < numToSelect; i++ {
-		selectedQuestionIDs[i] = questions[indices[i]].ID
+	renderQuizQuestion(w, r, state, true, false)
+}
+
+// quizPlayHandler handles GET /quiz/play, the page each answer redirects to (Post/Redirect/Get),
+// so refreshing it never resubmits an answer
+func quizPlayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	// Initialize quiz state
-	state := QuizState{
-		QuestionIDs:  selectedQuestionIDs,
-		CurrentIndex: 0,
-		Score:        0,
-		QuizType:     quizType,
+	state, ok := loadQuizRun(r)
+	if !ok {
+		http.Redirect(w, r, "/quiz", http.StatusSeeOther)
+		return
+	}
+	if state.CurrentIndex >= len(state.QuestionIDs) {
+		redirectToResults(w, r, state)
+		return
 	}
+	renderQuizQuestion(w, r, state, false, false)
+}
 
-	// Generate signature
-	signature, err := signQuizState(state)
-	if err != nil {
+// renderQuizQuestion stores the run and renders its current question. Storing on every view
+// gives the page a current token and keeps the run's cookie alive; newRun starts a fresh session.
+func renderQuizQuestion(w http.ResponseWriter, r *http.Request, state *QuizState, newRun, resumed bool) {
+	questions := questionSets[state.QuizType]
+	question, found := findQuestion(questions, state.QuestionIDs[state.CurrentIndex])
+	if !found {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		log.Printf("Error signing quiz state: %v", err)
+		log.Printf("Question ID %s not found", state.QuestionIDs[state.CurrentIndex])
 		return
 	}
 
-	// Serialize state to JSON
-	stateJSON, err := json.Marshal(state)
+	token, err := storeQuizState(w, r, *state, newRun)
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		log.Printf("Error marshaling quiz state: %v", err)
+		log.Printf("Error storing quiz state: %v", err)
 		return
 	}
 
-	// Find the first question
-	var currentQuestion Question
-	for _, q := range questions {
-		if q.ID == selectedQuestionIDs[0] {
-			currentQuestion = q
-			break
-		}
+	lang := requestLanguage(w, r)
+	renderPage(w, "quiz", lang, QuizPageData{
+		Theme:          themeFor(state.QuizType, lang),
+		QuizType:       state.QuizType,
+		Question:       localizeQuestion(question, lang),
+		CurrentIndex:   state.CurrentIndex + 1, // Display as 1-indexed
+		TotalQuestions: len(state.QuestionIDs),
+		Score:          state.Score,
+		StateToken:     token,
+		RunID:          state.RunID,
+		Step:           state.CurrentIndex,
+		Resumed:        resumed,
+		Practice:       state.Practice,
+		Feedback:       practiceFeedback(state, lang),
+	})
+}
+
+// selectQuestionIDs picks up to n distinct questions at random and returns their IDs
+func selectQuestionIDs(questions []Question, n int) []string {
+	numToSelect := n
+	if len(questions) < n {
+		numToSelect = len(questions)
 	}
 
-	// Prepare template data
-	data := QuizPageData{
-		Question:       currentQuestion,
-		CurrentIndex:   1, // Display as 1-indexed
-		TotalQuestions: numToSelect,
-		Score:          0,
-		QuizState:      string(stateJSON),
-		Signature:      signature,
+	// Create a copy of question indices and shuffle them
+	indices := make([]int, len(questions))
+	for i := range indices {
+		indices[i] = i
 	}
+	rand.Shuffle(len(indices), func(i, j int) {
+		indices[i], indices[j] = indices[j], indices[i]
+	})
 
-	// Parse and execute template
-	tmpl, err := template.New("quiz").Parse(quizHTML)
-	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		log.Printf("Error parsing quiz template: %v", err)
-		return
+	// Select the first numToSelect questions
+	selectedQuestionIDs := make([]string, numToSelect)
+	for i := 0; i < numToSelect; i++ {
+		selectedQuestionIDs[i] = questions[indices[i]].ID
 	}
+	return selectedQuestionIDs
+}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := tmpl.Execute(w, data); err != nil {
-		log.Printf("Error executing quiz template: %v", err)
+// findQuestion looks up a question by ID within a question set
+func findQuestion(questions []Question, id string) (Question, bool) {
+	for _, q := range questions {
+		if q.ID == id {
+			return q, true
+		}
 	}
+	return Question{}, false
 }
 
 // quizPostHandler handles POST requests to /quiz and processes answer submissions
+// It always redirects: to /quiz/play for the next question or to the results page.
 func quizPostHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
@@ -422,13 +694,12 @@ func quizPostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	stateJSON := r.FormValue("quizState")
-	signature := r.FormValue("signature")
 	answerStr := r.FormValue("answer")
 
-	// Verify HMAC signature
-	state, valid := verifyQuizState(stateJSON, signature)
-	if !valid {
+	// Load the run this browser is playing. A state without a run ID predates the step ledger,
+	// which could not stop it being replayed, so it is never graded.
+	state, valid := loadQuizRun(r)
+	if !valid || state.RunID == "" {
 		// Redirect to start over
 		http.Redirect(w, r, "/quiz", http.StatusSeeOther)
 		return
@@ -436,7 +707,20 @@ func quizPostHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Find current question
 	if state.CurrentIndex >= len(state.QuestionIDs) {
-		http.Error(w, "Invalid quiz state", http.StatusBadRequest)
+		redirectToResults(w, r, state)
+		return
+	}
+
+	// A form for an earlier question (back button, double click) must not answer the current one
+	if step := r.FormValue("step"); step != "" && step != strconv.Itoa(state.CurrentIndex) {
+		http.Redirect(w, r, "/quiz/play", http.StatusSeeOther)
+		return
+	}
+
+	// Practice can end at any question; the results cover only the questions answered
+	if state.Practice && r.FormValue("finish") != "" {
+		state.QuestionIDs = state.QuestionIDs[:state.CurrentIndex]
+		redirectToResults(w, r, state)
 		return
 	}
 
@@ -448,124 +732,132 @@ func quizPostHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	currentQuestionID := state.QuestionIDs[state.CurrentIndex]
-	var currentQuestion Question
-	found := false
-	for _, q := range questions {
-		if q.ID == currentQuestionID {
-			currentQuestion = q
-			found = true
-			break
-		}
-	}
-
+	currentQuestion, found := findQuestion(questions, currentQuestionID)
 	if !found {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		log.Printf("Question ID %s not found", currentQuestionID)
 		return
 	}
 
-	// Check answer if provided
-	if answerStr != "" {
-		answerIndex, err := strconv.Atoi(answerStr)
-		if err == nil && answerIndex == currentQuestion.AnswerIndex {
-			state.Score++
-		}
+	// Grade each step at most once, however many copies of this state are submitted
+	now := time.Now()
+	if !gradeStep(r, state, currentQuestion, answerStr, now) {
+		http.Redirect(w, r, "/quiz/play", http.StatusSeeOther)
+		return
 	}
 
-	// Update state
-	state.CurrentIndex++
-
 	// Check if more questions remain
 	if state.CurrentIndex < len(state.QuestionIDs) {
-		// Generate new signature for updated state
-		newSignature, err := signQuizState(*state)
-		if err != nil {
+		// Store the updated state and show the next question with a GET
+		if _, err := storeQuizState(w, r, *state, false); err != nil {
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			log.Printf("Error signing quiz state: %v", err)
+			log.Printf("Error storing quiz state: %v", err)
 			return
 		}
+		http.Redirect(w, r, "/quiz/play", http.StatusSeeOther)
+		return
+	}
 
-		// Serialize updated state
-		newStateJSON, err := json.Marshal(state)
-		if err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			log.Printf("Error marshaling quiz state: %v", err)
-			return
+	// Quiz complete - record the run in the player's history when logged in (practice runs are not recorded)
+	if account, ok := currentAccount(r); ok && !state.Practice {
+		run := RunRecord{QuizType: state.QuizType, Score: state.Score, Total: len(state.QuestionIDs), When: time.Now()}
+		if err := recordRun(account.Username, run); err != nil {
+			log.Printf("Error recording run for %s: %v", account.Username, err)
 		}
+	}
+	redirectToResults(w, r, state)
+}
 
-		// Find next question (questions variable already retrieved earlier)
-		nextQuestionID := state.QuestionIDs[state.CurrentIndex]
-		var nextQuestion Question
-		found = false
-		for _, q := range questions {
-			if q.ID == nextQuestionID {
-				nextQuestion = q
-				found = true
-				break
-			}
-		}
+// gradeStep grades the answer to the run's current question and advances the run, recording
+// the answer for analytics, practice review and study scheduling. It reports false, leaving the
+// state alone, when this step of the run was already graded or the state has no run ID to
+// check.
+func gradeStep(r *http.Request, state *QuizState, q Question, answerStr string, now time.Time) bool {
+	if state.RunID == "" || !answeredSteps.consume(state.RunID, state.CurrentIndex, now) {
+		return false
+	}
 
-		if !found {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			log.Printf("Question ID %s not found", nextQuestionID)
-			return
+	// Check answer if provided
+	correct := isCorrectAnswer(q, answerStr)
+	if correct {
+		state.Score++
+	}
+	recordAnswer(state, q, answerStr, correct, now)
+	if state.Practice {
+		recordPracticeAnswer(state, q, answerStr, correct)
+	}
+	if state.Study {
+		if account, ok := currentAccount(r); ok {
+			if err := recordStudyAnswer(account.Username, state.QuizType, q.ID, correct, now); err != nil {
+				log.Printf("Error recording study progress for %s: %v", account.Username, err)
+			}
 		}
+	}
 
-		// Prepare template data for next question
-		data := QuizPageData{
-			Question:       nextQuestion,
-			CurrentIndex:   state.CurrentIndex + 1, // Display as 1-indexed
-			TotalQuestions: len(state.QuestionIDs),
-			Score:          state.Score,
-			QuizState:      string(newStateJSON),
-			Signature:      newSignature,
-		}
+	// Update state, stamping the finish for the leaderboard tie-break
+	state.CurrentIndex++
+	state.AskedAt = now.UnixMilli()
+	if state.CurrentIndex == len(state.QuestionIDs) {
+		state.FinishedAt = now.UnixMilli()
+	}
+	recordRunEvent(state, runAnswered, now)
+	return true
+}
 
-		// Parse and execute template
-		tmpl, err := template.New("quiz").Parse(quizHTML)
-		if err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			log.Printf("Error parsing quiz template: %v", err)
-			return
-		}
+// redirectToResults stores a finished run and redirects to its results, carrying the token
+// unless the state lives in the session
+func redirectToResults(w http.ResponseWriter, r *http.Request, state *QuizState) {
+	token, err := storeQuizState(w, r, *state, false)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Error storing final state: %v", err)
+		return
+	}
 
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := tmpl.Execute(w, data); err != nil {
-			log.Printf("Error executing quiz template: %v", err)
-		}
-	} else {
-		// Quiz complete - redirect to results
-		// Serialize final state
-		finalStateJSON, err := json.Marshal(state)
-		if err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			log.Printf("Error marshaling final state: %v", err)
-			return
-		}
+	redirectURL := "/quiz/results"
+	if token != "" {
+		redirectURL += "?state=" + url.QueryEscape(token)
+	}
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
 
-		// Generate signature for final state
-		finalSignature, err := signQuizState(*state)
-		if err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			log.Printf("Error signing final state: %v", err)
-			return
-		}
+// isCorrectAnswer reports whether the submitted choice index answers the question
+// An empty or malformed answer (e.g. when the timer expires) is treated as incorrect.
+func isCorrectAnswer(q Question, answerStr string) bool {
+	if answerStr == "" {
+		return false
+	}
+	answerIndex, err := strconv.Atoi(answerStr)
+	return err == nil && answerIndex == q.AnswerIndex
+}
 
-		// Redirect to results with state
-		redirectURL := fmt.Sprintf("/quiz/results?state=%s&signature=%s",
-			template.URLQueryEscaper(string(finalStateJSON)),
-			template.URLQueryEscaper(finalSignature))
-		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+// validatePlayerName trims a display name and enforces the 1-20 character limit
+func validatePlayerName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		return "", errors.New("Name cannot be empty")
+	}
+	if len(name) > 20 {
+		return "", errors.New("Name must be 20 characters or less")
 	}
+	return name, nil
 }
 
 // ResultsPageData represents the data passed to the results.html template
 type ResultsPageData struct {
+	Theme      Theme
+	QuizType   string
 	Score      int
 	Total      int
 	Percentage float64
-	QuizState  string
-	Signature  string
+	StateToken string
+	Username   string // set when a logged-in player will submit under their account
+	LoginNext  string // brings a guest back to these results after logging in
+	ShareURL   string // public, signed link to this result
+	Practice   bool
+	Study      bool
+	Feedback   *PracticeFeedback // practice: how the last answer went
+	Missed     []MissedQuestion  // practice: questions to review
 }
 
 // quizResultsGetHandler handles GET requests to /quiz/results
@@ -575,12 +867,8 @@ func quizResultsGetHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract state and signature from query parameters
-	stateJSON := r.URL.Query().Get("state")
-	signature := r.URL.Query().Get("signature")
-
-	// Verify HMAC signature
-	state, valid := verifyQuizState(stateJSON, signature)
+	// Verify the state token from the query string or load the session
+	state, valid := loadQuizState(r)
 	if !valid {
 		// Redirect to start over if tampered
 		http.Redirect(w, r, "/quiz", http.StatusSeeOther)
@@ -595,26 +883,38 @@ func quizResultsGetHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Prepare template data
+	lang := requestLanguage(w, r)
 	data := ResultsPageData{
+		Theme:      themeFor(state.QuizType, lang),
+		QuizType:   state.QuizType,
 		Score:      state.Score,
 		Total:      total,
 		Percentage: percentage,
-		QuizState:  stateJSON,
-		Signature:  signature,
 	}
-
-	// Parse and execute template
-	tmpl, err := template.New("results").Parse(resultsHTML)
+	// Re-store so the results form submits a fresh token
+	token, err := storeQuizState(w, r, *state, false)
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		log.Printf("Error parsing results template: %v", err)
+		log.Printf("Error storing quiz state: %v", err)
 		return
 	}
-
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := tmpl.Execute(w, data); err != nil {
-		log.Printf("Error executing results template: %v", err)
+	data.StateToken = token
+	recordRunEvent(state, runResultsViewed, time.Now())
+	if state.Practice {
+		data.Practice = true
+		data.Study = state.Study
+		data.Feedback = practiceFeedback(state, lang)
+		data.Missed = missedQuestions(state, lang)
+	} else if total > 0 {
+		data.ShareURL = "/share/" + newShareToken(ShareCard{QuizType: state.QuizType, Score: state.Score, Total: total, When: time.Now()})
+	}
+	if account, ok := currentAccount(r); ok {
+		data.Username = account.Username
+	} else {
+		data.LoginNext = r.URL.RequestURI()
 	}
+
+	renderPage(w, "results", lang, data)
 }
 
 // quizLeaderboardPostHandler handles POST requests to /quiz/leaderboard
@@ -632,92 +932,129 @@ func quizLeaderboardPostHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Extract form values
 	name := r.FormValue("name")
-	stateJSON := r.FormValue("quizState")
-	signature := r.FormValue("signature")
 
-	// Verify HMAC signature
-	state, valid := verifyQuizState(stateJSON, signature)
+	// Verify the state token or load the session
+	state, valid := loadQuizState(r)
 	if !valid {
 		// Redirect to start over if tampered
 		http.Redirect(w, r, "/quiz", http.StatusSeeOther)
 		return
 	}
-
-	// Validate name
-	name = strings.TrimSpace(name)
-	if len(name) == 0 {
-		http.Error(w, "Name cannot be empty", http.StatusBadRequest)
+	if state.Practice {
+		http.Error(w, "Practice runs cannot be submitted to the leaderboard", http.StatusBadRequest)
 		return
 	}
-	if len(name) > 20 {
-		http.Error(w, "Name must be 20 characters or less", http.StatusBadRequest)
+	if state.CurrentIndex != len(state.QuestionIDs) {
+		http.Error(w, "Finish the quiz before submitting your score", http.StatusBadRequest)
+		return
+	}
+	if state.RunID == "" {
+		http.Error(w, "This run cannot be submitted to the leaderboard", http.StatusBadRequest)
+		return
+	}
+
+	// Logged-in players always submit under their verified username
+	entry := LeaderboardEntry{
+		Score:    state.Score,
+		Total:    len(state.QuestionIDs),
+		When:     time.Now(),
+		QuizType: state.QuizType,
+
+		DurationMS: state.Duration().Milliseconds(),
+	}
+	if account, ok := currentAccount(r); ok {
+		entry.Name = account.Username
+		entry.Verified = true
+	} else {
+		// Validate name
+		name, err := validatePlayerName(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Guests may not impersonate registered players
+		if accountExists(name) {
+			http.Error(w, "That name belongs to a registered player; log in to use it", http.StatusBadRequest)
+			return
+		}
+		entry.Name = name
+	}
+
+	// Each run posts one score, however often its results token is sent
+	if !answeredSteps.submit(state.RunID, time.Now()) {
+		http.Error(w, "This score has already been submitted", http.StatusConflict)
 		return
 	}
 
 	// Save score to leaderboard
-	total := len(state.QuestionIDs)
-	if err := saveScore(name, state.Score, total, state.QuizType); err != nil {
+	entryID, err := addLeaderboardEntry(entry)
+	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		log.Printf("Error saving score: %v", err)
 		return
 	}
+	recordRunEvent(state, runSubmitted, time.Now())
+	endQuizState(w, r)
 
-	// Redirect to leaderboard
-	http.Redirect(w, r, "/leaderboard", http.StatusSeeOther)
+	// Redirect to leaderboard, keeping the quiz type's branding and pointing out the new entry
+	http.Redirect(w, r, "/leaderboard?type="+url.QueryEscape(state.QuizType)+"&entry="+url.QueryEscape(entryID), http.StatusSeeOther)
 }
 
 
 // LeaderboardPageData represents the data passed to the leaderboard.html template
 type LeaderboardPageData struct {
-	Entries []LeaderboardEntry
+	Theme    Theme
+	QuizType string // branding only; the board still lists every quiz type
+	Window   string // "all", "month", "week" or "day"
+	Windows  []string
+	Entries  []RankedEntry
+	EntryID  string               // the viewer's just-submitted entry, highlighted on the board
+	Position *LeaderboardPosition // where EntryID stands when it is not in Entries
 }
 
 // leaderboardGetHandler handles GET requests to /leaderboard
+// ?window= selects the all-time (default), month, week or day board.
 func leaderboardGetHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Get leaderboard entries
-	entries := getLeaderboard()
+	// Get the window's top entries, highlighting the viewer's entry
+	now := time.Now()
+	window := parseLeaderboardWindow(r.URL.Query().Get("window"))
+	entryID := r.URL.Query().Get("entry")
+	entries := rankLeaderboardEntries(topLeaderboard(window, "", MaxLeaderboardSize, now))
+	onBoard := false
+	for i := range entries {
+		if entryID != "" && entries[i].ID == entryID {
+			entries[i].Highlight = true
+			onBoard = true
+		}
+	}
 
 	// Prepare template data
+	lang := requestLanguage(w, r)
+	quizType := r.URL.Query().Get("type")
+	if _, ok := themes[quizType]; !ok {
+		quizType = defaultThemeName
+	}
 	data := LeaderboardPageData{
-		Entries: entries,
-	}
-
-	// Create template with custom functions
-	tmpl := template.New("leaderboard").Funcs(template.FuncMap{
-		"add": func(a, b int) int {
-			return a + b
-		},
-		"mul": func(a, b float64) float64 {
-			return a * b
-		},
-		"div": func(a, b float64) float64 {
-			if b == 0 {
-				return 0
-			}
-			return a / b
-		},
-		"toFloat": func(i int) float64 {
-			return float64(i)
-		},
-	})
-
-	// Parse template
-	tmpl, err := tmpl.Parse(leaderboardHTML)
-	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		log.Printf("Error parsing leaderboard template: %v", err)
-		return
+		Theme:    themeFor(quizType, lang),
+		QuizType: quizType,
+		Window:   window,
+		Windows:  leaderboardWindows,
+		Entries:  entries,
+		EntryID:  entryID,
 	}
-
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := tmpl.Execute(w, data); err != nil {
-		log.Printf("Error executing leaderboard template: %v", err)
+	// Entries outside the top N show where they landed instead
+	if !onBoard {
+		if pos, ok := leaderboardPosition(entryID, window, now, leaderboardNeighborRadius); ok {
+			data.Position = &pos
+		}
 	}
+
+	renderPage(w, "leaderboard", lang, data)
 }
 
 // setupRoutes configures the HTTP routes
@@ -726,9 +1063,34 @@ func setupRoutes() *http.ServeMux {
 
 	// Register specific routes first
 	mux.HandleFunc("/health", healthHandler)
+	mux.HandleFunc("/quiz/play", quizPlayHandler)
 	mux.HandleFunc("/quiz/results", quizResultsGetHandler)
 	mux.HandleFunc("/quiz/leaderboard", quizLeaderboardPostHandler)
 	mux.HandleFunc("/leaderboard", leaderboardGetHandler)
+	mux.HandleFunc("/leaderboard/events", leaderboardEventsHandler)
+	mux.HandleFunc("/leaderboard.json", leaderboardJSONHandler)
+	mux.HandleFunc("/leaderboard.csv", leaderboardCSVHandler)
+	mux.HandleFunc("/leaderboard.atom", leaderboardAtomHandler)
+	mux.HandleFunc("/share/{token}", sharePageHandler)
+	mux.HandleFunc("/share/{token}/card.svg", shareCardSVGHandler)
+	mux.HandleFunc("/share/{token}/card.png", shareCardPNGHandler)
+	mux.HandleFunc("/signup", signupHandler)
+	mux.HandleFunc("/login", loginHandler)
+	mux.HandleFunc("/logout", logoutHandler)
+	mux.HandleFunc("/profile", profileHandler)
+	mux.HandleFunc("/study", studyHandler)
+	mux.HandleFunc("/admin/questions", adminQuestionsHandler)
+	mux.HandleFunc("/admin/questions.csv", adminQuestionsCSVHandler)
+	mux.HandleFunc("/admin/funnel", adminFunnelHandler)
+	mux.HandleFunc("/embed/{type}", embedQuizHandler)
+	mux.HandleFunc("/embed/{type}/play", embedPlayHandler)
+	mux.HandleFunc("/embed/{type}/results", embedResultsHandler)
+	mux.HandleFunc("/rooms", roomsHandler)
+	mux.HandleFunc("/rooms/join", roomJoinHandler)
+	mux.HandleFunc("/rooms/{code}", roomPageHandler)
+	mux.HandleFunc("/rooms/{code}/events", roomEventsHandler)
+	mux.HandleFunc("/rooms/{code}/answer", roomAnswerHandler)
+	mux.HandleFunc("/rooms/{code}/advance", roomAdvanceHandler)
 	mux.HandleFunc("/quiz", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodGet {
 			quizGetHandler(w, r)
@@ -752,15 +1114,118 @@ func setupRoutes() *http.ServeMux {
 }
 
 func main() {
+	// Dispatch offline subcommands before starting the server
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "lint-questions":
+			os.Exit(runLintQuestions(os.Args[2:], os.Stdout, os.Stderr))
+		case "convert-questions":
+			os.Exit(runConvertQuestions(os.Args[2:], os.Stdout, os.Stderr))
+		case "embed-snippet":
+			os.Exit(runEmbedSnippet(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
 	// Parse CLI flags
 	port := flag.Int("port", 8080, "Port to listen on")
+	devTemplates := flag.Bool("dev-templates", false, "Reload templates from ./templates on every request")
+	themeDir := flag.String("theme-dir", "", "Directory with themes.json and theme CSS/assets to serve under /themes/")
+	flag.BoolVar(&encryptStateTokens, "encrypt-state", true, "Encrypt quiz state tokens so players cannot read question IDs or scores")
+	sessionStore := flag.String("quiz-sessions", "off", "Where quiz state lives: off (signed client tokens), memory or file")
+	sessionDir := flag.String("quiz-session-dir", "quiz_sessions", "Directory for -quiz-sessions=file")
+	admins := flag.String("admins", "", "Comma-separated usernames allowed to view the /admin reports")
+	analyticsLog := flag.String("analytics-log", defaultAnalyticsFilename, "JSON Lines file recording every graded answer for question analytics (empty disables it)")
+	funnelLog := flag.String("funnel-log", defaultFunnelFilename, "JSON Lines file recording anonymous run lifecycle events for the funnel report (empty disables it)")
+	flag.DurationVar(&funnelRetention, "funnel-retention", defaultFunnelRetention, "How long run lifecycle events are kept before being pruned")
+	flag.DurationVar(&leaderboardHistory, "leaderboard-history", defaultLeaderboardHistory, "How long to keep scores that are not all-time top scores (at least 31 days)")
+	tieBreak := flag.String("leaderboard-tiebreak", tieBreakDuration, "How equal scores are ordered: duration (fastest run first) or submitted (earliest submission first)")
+	rankStyle := flag.String("leaderboard-ranks", rankCompetition, "How tied entries are numbered: competition (1, 2, 2, 4) or dense (1, 2, 2, 3)")
+	embedOriginList := flag.String("embed-origins", "", "Comma-separated origins allowed to frame /embed/ quizzes, e.g. https://blog.example (* allows any site)")
+	security := defaultSecurityConfig()
+	flag.StringVar(&security.CSP, "csp", security.CSP, "Content Security Policy mode: enforce, report-only or off")
+	flag.StringVar(&security.CSPReportURI, "csp-report-uri", "", "URL browsers report Content Security Policy violations to (empty for none)")
+	flag.StringVar(&security.FrameOptions, "frame-options", security.FrameOptions, "Who may frame the site's pages: deny, sameorigin or off (/embed/ pages follow -embed-origins)")
+	flag.StringVar(&security.ReferrerPolicy, "referrer-policy", security.ReferrerPolicy, "Referrer-Policy header value (empty omits it)")
+	flag.DurationVar(&security.HSTSMaxAge, "hsts-max-age", 0, "Strict-Transport-Security max-age; only enable when the site is served over HTTPS (0 omits it)")
+	flag.BoolVar(&security.HSTSSubdomains, "hsts-include-subdomains", false, "Extend Strict-Transport-Security to subdomains")
+	secretFile := flag.String("secret-file", defaultServerSecretFile, "File holding the secret that signs sessions, quiz state and share links, created with a random secret if missing ("+serverSecretEnv+" overrides it)")
 	flag.Parse()
 
+	secret, err := loadServerSecret(*secretFile)
+	if err != nil {
+		log.Fatalf("Failed to load the server secret: %v", err)
+	}
+	keys = deriveServerKeys(secret)
+	setAdmins(*admins)
+	if err := setLeaderboardTieBreak(*tieBreak); err != nil {
+		log.Fatalf("Invalid -leaderboard-tiebreak: %v", err)
+	}
+	if err := setLeaderboardRankStyle(*rankStyle); err != nil {
+		log.Fatalf("Invalid -leaderboard-ranks: %v", err)
+	}
+	if err := setEmbedOrigins(*embedOriginList); err != nil {
+		log.Fatalf("Invalid -embed-origins: %v", err)
+	}
+	if err := security.validate(); err != nil {
+		log.Fatalf("Invalid security headers: %v", err)
+	}
+
+	// Record graded answers for the question analytics report
+	if *analyticsLog != "" {
+		eventLog, err := openEventLog[AnswerEvent](*analyticsLog)
+		if err != nil {
+			log.Fatalf("Failed to open analytics log: %v", err)
+		}
+		defer eventLog.Close()
+		answerEvents = eventLog
+	}
+
+	// Record run lifecycle events for the funnel report, pruning them by the retention policy
+	if *funnelLog != "" {
+		eventLog, err := openEventLog[RunEvent](*funnelLog)
+		if err != nil {
+			log.Fatalf("Failed to open funnel log: %v", err)
+		}
+		defer eventLog.Close()
+		runEvents = eventLog
+		stopPruner := make(chan struct{})
+		defer close(stopPruner)
+		go pruneEventLog(eventLog, funnelRetention, funnelPruneEvery, stopPruner)
+	}
+
+	// Optionally keep quiz state server-side, sweeping abandoned runs in the background
+	store, err := newQuizSessionStore(*sessionStore, *sessionDir)
+	if err != nil {
+		log.Fatalf("Failed to set up quiz sessions: %v", err)
+	}
+	if store != nil {
+		quizSessions = store
+		stopSweeper := make(chan struct{})
+		defer close(stopSweeper)
+		go sweepQuizSessions(store, quizSessionSweepEvery, stopSweeper)
+		log.Printf("Keeping quiz state in %s sessions", *sessionStore)
+	}
+
+	// Free abandoned multiplayer rooms in the background
+	stopRoomSweeper := make(chan struct{})
+	defer close(stopRoomSweeper)
+	go sweepRooms(rooms, roomSweepEvery, stopRoomSweeper)
+
+	// In dev mode, serve templates from disk so UI edits show up on refresh
+	if *devTemplates {
+		registry, err := newTemplateRegistry(os.DirFS(templateDir), true)
+		if err != nil {
+			log.Fatalf("Failed to parse templates from %s: %v", templateDir, err)
+		}
+		pageTemplates = registry
+		log.Printf("Reloading templates from %s on every request", templateDir)
+	}
+
 	// Initialize question sets map
 	questionSets = make(map[string][]Question)
 
 	// Load astrology questions
-	astrologyQuestions, err := loadQuestions("questions.json")
+	astrologyQuestions, err := loadQuestionBank("questions")
 	if err != nil {
 		log.Printf("Warning: Failed to load astrology questions: %v", err)
 	} else {
@@ -769,7 +1234,7 @@ func main() {
 	}
 
 	// Load tarot questions
-	tarotQuestions, err := loadQuestions("tarot_questions.json")
+	tarotQuestions, err := loadQuestionBank("tarot_questions")
 	if err != nil {
 		log.Printf("Warning: Failed to load tarot questions: %v", err)
 	} else {
@@ -784,14 +1249,48 @@ func main() {
 		log.Printf("Successfully loaded leaderboard with %d entries", len(leaderboardManager.entries))
 	}
 
+	// Reload the step ledger so a restart cannot regrade a question or post a score twice
+	if err := answeredSteps.load(stepLedgerFilename, time.Now()); err != nil {
+		log.Printf("Warning: Failed to load step ledger: %v", err)
+	}
+
+	// Load player accounts from JSON file
+	if err := loadAccounts(); err != nil {
+		log.Printf("Warning: Failed to load accounts: %v", err)
+	} else {
+		log.Printf("Successfully loaded %d accounts", len(accountManager.accounts))
+	}
+
+	// Load study progress from JSON file
+	if err := loadStudyProgress(); err != nil {
+		log.Printf("Warning: Failed to load study progress: %v", err)
+	}
+
+	// Load custom themes and serve their assets when a theme directory is configured
+	if *themeDir != "" {
+		if err := loadThemes(*themeDir); err != nil {
+			log.Fatalf("Failed to load themes from %s: %v", *themeDir, err)
+		}
+		log.Printf("Serving theme assets from %s at %s", *themeDir, themeAssetsPrefix)
+
+		// Let pages load the themes' assets from other hosts
+		security.AssetOrigins = themeAssetOrigins()
+		if err := security.validate(); err != nil {
+			log.Fatalf("Invalid security headers: %v", err)
+		}
+	}
+
 	// Validate port
 	validPort := validatePort(*port)
 
 	// Setup routes
 	mux := setupRoutes()
+	if *themeDir != "" {
+		mux.Handle(themeAssetsPrefix, themeAssetsHandler(*themeDir))
+	}
 
-	// Wrap with logging middleware
-	handler := loggingMiddleware(mux)
+	// Wrap with security headers and logging middleware
+	handler := loggingMiddleware(securityHeaders(security, mux))
 
 	// Configure HTTP server
 	server := &http.Server{
@@ -825,4 +1324,7 @@ func main() {
 	} else {
 		log.Println("Server shutdown complete")
 	}
+	if err := compactLeaderboard(); err != nil {
+		log.Printf("Error compacting leaderboard: %v", err)
+	}
 }
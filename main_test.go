@@ -76,6 +76,73 @@ func TestHomeHandler(t *testing.T) {
 	}
 }
 
+// TestHomeHandler_QuizTypes tests that the home page lists every loaded quiz type with its stats
+func TestHomeHandler_QuizTypes(t *testing.T) {
+	resetThemes(t)
+	questionSets = map[string][]Question{
+		"tarot":      {{ID: "t1"}, {ID: "t2"}, {ID: "t3"}},
+		"astrology":  {{ID: "a1"}, {ID: "a2"}},
+		"numerology": {{ID: "n1"}},
+		"empty":      {},
+	}
+	leaderboardManager = LeaderboardManager{entries: []LeaderboardEntry{
+		{Name: "Zed", Score: 5, Total: 5, QuizType: "astrology"},
+		{Name: "Ann", Score: 3, Total: 3, QuizType: "tarot"},
+		{Name: "Bob", Score: 2, Total: 3, QuizType: "tarot"},
+	}}
+
+	summaries := buildQuizSummaries("en")
+	var types []string
+	for _, s := range summaries {
+		types = append(types, s.Type)
+	}
+	if got := strings.Join(types, ","); got != "astrology,numerology,tarot" {
+		t.Fatalf("quiz types = %s, want astrology,numerology,tarot", got)
+	}
+	if tarot := summaries[2]; tarot.QuestionCount != 3 || tarot.Best == nil || tarot.Best.Name != "Ann" {
+		t.Errorf("tarot summary = %+v, want 3 questions and Ann's best score", tarot)
+	}
+	if numerology := summaries[1]; numerology.Best != nil || numerology.Theme.Title != "numerology" || numerology.Theme.Intro != "" {
+		t.Errorf("unthemed type should use its name and no intro: %+v", numerology)
+	}
+
+	tests := []struct {
+		name     string
+		url      string
+		contains []string
+	}{
+		{"english", "/", []string{
+			"Tarot Quiz", "Major and Minor Arcana", "3 questions", "Best score: 3/3 by Ann",
+			`href="/quiz?type=numerology"`, `href="/leaderboard?type=tarot"`, "No scores yet",
+			"--card-color: #6a1b9a",
+		}},
+		{"spanish", "/?lang=es", []string{"Quiz de Tarot", "3 preguntas", "Mejor puntuación: 5/5 de Zed", "Aún no hay puntuaciones"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			homeHandler(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("body missing %q", want)
+				}
+			}
+		})
+	}
+
+	// With no questions loaded the page explains there is nothing to play
+	questionSets = map[string][]Question{}
+	rec := httptest.NewRecorder()
+	homeHandler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(rec.Body.String(), "No quizzes are available") {
+		t.Error("empty home page should say no quizzes are available")
+	}
+}
+
 func TestHealthHandler(t *testing.T) {
 	tests := []struct {
 		name           string
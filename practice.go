@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math/rand"
+	"net/http"
+	"slices"
+)
+
+// PracticeFeedback tells a practice player how they did on the question they just answered
+type PracticeFeedback struct {
+	Correct     bool
+	Question    string
+	Answer      string // the correct choice
+	Explanation string
+}
+
+// MissedQuestion is a question answered incorrectly in practice, listed on the results page for review
+type MissedQuestion struct {
+	Question    string
+	Answer      string
+	Explanation string
+}
+
+// recordPracticeAnswer remembers an answer for the next page's feedback and adds a missed
+// question to the run's review list
+func recordPracticeAnswer(state *QuizState, q Question, answerStr string, correct bool) {
+	state.LastQuestionID = q.ID
+	state.LastAnswer = answerStr
+	if !correct && !slices.Contains(state.Missed, q.ID) {
+		state.Missed = append(state.Missed, q.ID)
+	}
+}
+
+// practiceFeedback describes the last answered question of a practice run in the player's
+// language, or returns nil when there is nothing to report
+func practiceFeedback(state *QuizState, lang string) *PracticeFeedback {
+	if !state.Practice || state.LastQuestionID == "" {
+		return nil
+	}
+	q, found := findQuestion(questionSets[state.QuizType], state.LastQuestionID)
+	if !found {
+		return nil
+	}
+	localized := localizeQuestion(q, lang)
+	return &PracticeFeedback{
+		Correct:     isCorrectAnswer(q, state.LastAnswer),
+		Question:    localized.Question,
+		Answer:      localized.Choices[q.AnswerIndex],
+		Explanation: localized.Explanation,
+	}
+}
+
+// missedQuestions lists a practice run's missed questions in the player's language
+func missedQuestions(state *QuizState, lang string) []MissedQuestion {
+	var missed []MissedQuestion
+	for _, id := range state.Missed {
+		q, found := findQuestion(questionSets[state.QuizType], id)
+		if !found {
+			continue
+		}
+		localized := localizeQuestion(q, lang)
+		missed = append(missed, MissedQuestion{
+			Question:    localized.Question,
+			Answer:      localized.Choices[q.AnswerIndex],
+			Explanation: localized.Explanation,
+		})
+	}
+	return missed
+}
+
+// practiceReviewIDs returns, shuffled, the questions missed in this browser's finished practice
+// run of quizType, or nil if there is no such run
+func practiceReviewIDs(r *http.Request, quizType string) []string {
+	run, ok := loadQuizRun(r)
+	if !ok || !run.Practice || run.QuizType != quizType || run.CurrentIndex < len(run.QuestionIDs) {
+		return nil
+	}
+	ids := slices.Clone(run.Missed)
+	rand.Shuffle(len(ids), func(i, j int) {
+		ids[i], ids[j] = ids[j], ids[i]
+	})
+	return ids
+}
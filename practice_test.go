@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// practiceQuestions installs a small bank whose answers are all the first choice
+func practiceQuestions() {
+	questionSets = map[string][]Question{
+		"astrology": {
+			{ID: "q1", Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 0, Explanation: "Why Q1"},
+			{ID: "q2", Question: "Q2?", Choices: []string{"A", "B"}, AnswerIndex: 0, Explanation: "Why Q2"},
+			{ID: "q3", Question: "Q3?", Choices: []string{"A", "B"}, AnswerIndex: 0, Explanation: "Why Q3"},
+		},
+	}
+}
+
+// newBrowser returns a function that sends requests through the routes like a browser, keeping
+// cookies, starting with the given ones
+func newBrowser(initial ...*http.Cookie) func(method, target string, form url.Values) *httptest.ResponseRecorder {
+	mux := setupRoutes()
+	cookies := map[string]*http.Cookie{}
+	for _, c := range initial {
+		cookies[c.Name] = c
+	}
+	return func(method, target string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		for _, c := range w.Result().Cookies() {
+			cookies[c.Name] = c
+		}
+		return w
+	}
+}
+
+// shownQuestion returns the text of the question a quiz page asks
+func shownQuestion(body string) string {
+	_, rest, _ := strings.Cut(body, `<div class="question">`)
+	text, _, _ := strings.Cut(rest, "</div>")
+	return text
+}
+
+func TestPracticeRun(t *testing.T) {
+	for _, mode := range []string{"tokens", "sessions"} {
+		t.Run(mode, func(t *testing.T) {
+			setupAccountsTest(t)
+			practiceQuestions()
+			if mode == "sessions" {
+				useQuizSessions(t, newMemorySessionStore())
+			}
+			send := newBrowser()
+
+			body := send(http.MethodGet, "/quiz?type=astrology&practice=1", nil).Body.String()
+			if !strings.Contains(body, "Question 1 of 3") || !strings.Contains(body, "Practice mode:") {
+				t.Fatal("practice should drill the whole bank and say so")
+			}
+			if strings.Contains(body, `id="timer"`) {
+				t.Error("practice page should not have a timer")
+			}
+			missed := shownQuestion(body)
+
+			// A wrong answer is explained on the next page and kept for review
+			send(http.MethodPost, "/quiz", url.Values{"step": {"0"}, "answer": {"1"}})
+			body = send(http.MethodGet, "/quiz/play", nil).Body.String()
+			if !strings.Contains(body, "Not quite. The answer was: A") || !strings.Contains(body, "Why "+strings.TrimSuffix(missed, "?")) {
+				t.Error("wrong answer should show the correct answer and explanation")
+			}
+
+			send(http.MethodPost, "/quiz", url.Values{"step": {"1"}, "answer": {"0"}})
+			body = send(http.MethodGet, "/quiz/play", nil).Body.String()
+			if !strings.Contains(body, "Correct!") || !strings.Contains(body, "Question 3 of 3") {
+				t.Error("right answer should be confirmed")
+			}
+
+			// Ending early scores only the questions answered
+			w := send(http.MethodPost, "/quiz", url.Values{"step": {"2"}, "finish": {"1"}})
+			if w.Code != http.StatusSeeOther || !strings.HasPrefix(w.Header().Get("Location"), "/quiz/results") {
+				t.Fatalf("finish should redirect to results, got %d %q", w.Code, w.Header().Get("Location"))
+			}
+			body = send(http.MethodGet, w.Header().Get("Location"), nil).Body.String()
+			for _, want := range []string{"Practice Complete!", "1 / 2", missed, "Answer: A", "Review Missed Questions (1)", `href="/quiz?type=astrology&practice=1&review=1"`} {
+				if !strings.Contains(body, want) {
+					t.Errorf("practice results should contain %q", want)
+				}
+			}
+			if strings.Contains(body, `action="/quiz/leaderboard"`) || strings.Contains(body, "/share/") {
+				t.Error("practice results should offer neither the leaderboard nor a share link")
+			}
+
+			// Even a hand-crafted submission is refused
+			// (in session mode the token is ignored and the finished practice run is loaded from the session)
+			token, _ := encodeStateToken(QuizState{QuestionIDs: []string{"q1"}, CurrentIndex: 1, Score: 1, QuizType: "astrology", Practice: true})
+			w = send(http.MethodPost, "/quiz/leaderboard", url.Values{"state": {token}, "name": {"Drill"}})
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("practice submission should be rejected with 400, got %d", w.Code)
+			}
+			if entries := getLeaderboard(); len(entries) != 0 {
+				t.Errorf("practice must not reach the leaderboard, got %+v", entries)
+			}
+
+			// Review drills only the missed question
+			body = send(http.MethodGet, "/quiz?type=astrology&practice=1&review=1", nil).Body.String()
+			if !strings.Contains(body, "Question 1 of 1") || shownQuestion(body) != missed {
+				t.Errorf("review should ask only %q", missed)
+			}
+		})
+	}
+}
+
+func TestPracticeResume(t *testing.T) {
+	practiceQuestions()
+	send := newBrowser()
+
+	send(http.MethodGet, "/quiz?type=astrology&practice=1", nil)
+	send(http.MethodPost, "/quiz", url.Values{"step": {"0"}, "answer": {"0"}})
+
+	tests := []struct {
+		target       string
+		wantResumed  bool
+		wantPractice bool
+	}{
+		{"/quiz", true, true},
+		{"/quiz?type=astrology&practice=1", true, true},
+		{"/quiz?type=astrology", false, false}, // an explicit timed link starts a timed run
+	}
+	for _, tt := range tests {
+		body := send(http.MethodGet, tt.target, nil).Body.String()
+		if resumed := strings.Contains(body, "continuing your quiz"); resumed != tt.wantResumed {
+			t.Errorf("GET %s: resumed = %v, want %v", tt.target, resumed, tt.wantResumed)
+		}
+		if practice := strings.Contains(body, "Practice mode:"); practice != tt.wantPractice {
+			t.Errorf("GET %s: practice = %v, want %v", tt.target, practice, tt.wantPractice)
+		}
+	}
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Supported question bank formats
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+	formatYAML = "yaml"
+)
+
+// questionBankExtensions lists the file extensions tried, in order, when locating a bank
+var questionBankExtensions = []string{".json", ".yaml", ".yml", ".csv"}
+
+// CSV column layout for question banks.
+//
+// The first row is a header; columns are matched by name so their order does not matter:
+//
+//	id, question, answer_index, explanation, choice_1, choice_2, ... choice_N
+//
+// Choices are read from choice_1 upwards. Rows may leave trailing choice cells empty when a
+// question has fewer choices than the widest question in the file; those cells are dropped.
+//
+// Translations use the same column names suffixed with @ and a language code, e.g.
+// question@es, explanation@es, choice_1@es. A row whose cells for a language are all empty
+// has no translation in that language.
+const (
+	csvColumnID          = "id"
+	csvColumnQuestion    = "question"
+	csvColumnAnswerIndex = "answer_index"
+	csvColumnExplanation = "explanation"
+	csvChoicePrefix      = "choice_"
+	csvLanguageSeparator = "@"
+)
+
+// csvTextColumns locates the question, explanation and choice columns for one language
+type csvTextColumns struct {
+	question    int // -1 when absent
+	explanation int // -1 when absent
+	choices     map[int]int
+	maxChoice   int
+}
+
+// newCSVTextColumns returns an empty column set
+func newCSVTextColumns() *csvTextColumns {
+	return &csvTextColumns{question: -1, explanation: -1, choices: make(map[int]int)}
+}
+
+// read extracts the text cells of one record; trailing empty choices are dropped
+func (c *csvTextColumns) read(record []string) (question string, choices []string, explanation string) {
+	cell := func(i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+	choices = make([]string, 0, c.maxChoice)
+	for n := 1; n <= c.maxChoice; n++ {
+		choices = append(choices, cell(c.choices[n]))
+	}
+	// Drop the padding cells of questions narrower than the widest one
+	for len(choices) > 0 && choices[len(choices)-1] == "" {
+		choices = choices[:len(choices)-1]
+	}
+	return cell(c.question), choices, cell(c.explanation)
+}
+
+// questionFormatFromPath infers the bank format from a file extension
+func questionFormatFromPath(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return formatJSON, nil
+	case ".csv":
+		return formatCSV, nil
+	case ".yaml", ".yml":
+		return formatYAML, nil
+	}
+	return "", fmt.Errorf("unsupported question file extension %q (want .json, .csv, .yaml or .yml)", filepath.Ext(path))
+}
+
+// findQuestionBank returns the first existing file named base plus a supported extension
+func findQuestionBank(base string) (string, error) {
+	for _, ext := range questionBankExtensions {
+		path := base + ext
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no question bank found for %s (tried %s)", base, strings.Join(questionBankExtensions, ", "))
+}
+
+// decodeQuestions parses a question bank in the given format.
+// In strict mode unknown JSON/YAML fields are rejected; CSV headers are always checked.
+func decodeQuestions(data []byte, format string, strict bool) ([]Question, error) {
+	switch format {
+	case formatJSON:
+		return decodeQuestionsJSON(data, strict)
+	case formatCSV:
+		return decodeQuestionsCSV(data)
+	case formatYAML:
+		return decodeQuestionsYAML(data, strict)
+	}
+	return nil, fmt.Errorf("unsupported question format %q", format)
+}
+
+// encodeQuestions renders a question bank in the given format
+func encodeQuestions(questions []Question, format string) ([]byte, error) {
+	switch format {
+	case formatJSON:
+		return formatQuestions(questions)
+	case formatCSV:
+		return encodeQuestionsCSV(questions)
+	case formatYAML:
+		return encodeQuestionsYAML(questions)
+	}
+	return nil, fmt.Errorf("unsupported question format %q", format)
+}
+
+// decodeQuestionsJSON parses the JSON array format
+func decodeQuestionsJSON(data []byte, strict bool) ([]Question, error) {
+	var questions []Question
+	if !strict {
+		if err := json.Unmarshal(data, &questions); err != nil {
+			return nil, fmt.Errorf("failed to parse questions JSON: %w", err)
+		}
+		return questions, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&questions); err != nil {
+		return nil, fmt.Errorf("failed to parse questions JSON: %w", err)
+	}
+	if dec.More() {
+		return nil, errors.New("failed to parse questions JSON: unexpected data after the question array")
+	}
+	return questions, nil
+}
+
+// decodeQuestionsYAML parses a YAML sequence of questions using the same field names as JSON
+func decodeQuestionsYAML(data []byte, strict bool) ([]Question, error) {
+	var questions []Question
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(strict)
+	if err := dec.Decode(&questions); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse questions YAML: %w", err)
+	}
+	if questions == nil {
+		questions = []Question{}
+	}
+	return questions, nil
+}
+
+// encodeQuestionsYAML renders a question bank as a YAML sequence
+func encodeQuestionsYAML(questions []Question) ([]byte, error) {
+	if len(questions) == 0 {
+		return []byte("[]\n"), nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(questions); err != nil {
+		return nil, fmt.Errorf("failed to encode questions YAML: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode questions YAML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeQuestionsCSV parses the documented CSV column layout. A leading UTF-8 byte order mark,
+// as spreadsheet exports often write, is ignored.
+func decodeQuestionsCSV(data []byte) ([]Question, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse questions CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return []Question{}, nil
+	}
+
+	// Map header names to column positions; text columns are grouped by language ("" is the original)
+	header := records[0]
+	seen := make(map[string]bool, len(header))
+	idColumn, answerColumn := -1, -1
+	texts := map[string]*csvTextColumns{"": newCSVTextColumns()}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if seen[name] {
+			return nil, fmt.Errorf("failed to parse questions CSV: duplicate column %q", name)
+		}
+		seen[name] = true
+
+		switch name {
+		case csvColumnID:
+			idColumn = i
+			continue
+		case csvColumnAnswerIndex:
+			answerColumn = i
+			continue
+		}
+
+		base, lang, translated := strings.Cut(name, csvLanguageSeparator)
+		if translated && lang == "" {
+			return nil, fmt.Errorf("failed to parse questions CSV: column %q has an empty language code", name)
+		}
+		cols, ok := texts[lang]
+		if !ok {
+			cols = newCSVTextColumns()
+			texts[lang] = cols
+		}
+
+		switch base {
+		case csvColumnQuestion:
+			cols.question = i
+			continue
+		case csvColumnExplanation:
+			cols.explanation = i
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimPrefix(base, csvChoicePrefix))
+		if !strings.HasPrefix(base, csvChoicePrefix) || err != nil || n < 1 {
+			return nil, fmt.Errorf("failed to parse questions CSV: unknown column %q", name)
+		}
+		cols.choices[n] = i
+		if n > cols.maxChoice {
+			cols.maxChoice = n
+		}
+	}
+	if idColumn < 0 {
+		return nil, fmt.Errorf("failed to parse questions CSV: missing required column %q", csvColumnID)
+	}
+	if texts[""].question < 0 {
+		return nil, fmt.Errorf("failed to parse questions CSV: missing required column %q", csvColumnQuestion)
+	}
+	if answerColumn < 0 {
+		return nil, fmt.Errorf("failed to parse questions CSV: missing required column %q", csvColumnAnswerIndex)
+	}
+	for lang, cols := range texts {
+		for n := 1; n <= cols.maxChoice; n++ {
+			if _, ok := cols.choices[n]; !ok {
+				name := fmt.Sprintf("%s%d", csvChoicePrefix, n)
+				if lang != "" {
+					name += csvLanguageSeparator + lang
+				}
+				return nil, fmt.Errorf("failed to parse questions CSV: missing column %s", name)
+			}
+		}
+	}
+
+	cell := func(record []string, i int) string {
+		if i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	questions := make([]Question, 0, len(records)-1)
+	for line, record := range records[1:] {
+		answer := strings.TrimSpace(cell(record, answerColumn))
+		answerIndex, err := strconv.Atoi(answer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse questions CSV: row %d has invalid answer_index %q", line+2, answer)
+		}
+
+		text, choices, explanation := texts[""].read(record)
+		q := Question{
+			ID:          cell(record, idColumn),
+			Question:    text,
+			Choices:     choices,
+			AnswerIndex: answerIndex,
+			Explanation: explanation,
+		}
+		for lang, cols := range texts {
+			if lang == "" {
+				continue
+			}
+			text, choices, explanation := cols.read(record)
+			if text == "" && len(choices) == 0 && explanation == "" {
+				continue
+			}
+			if len(choices) == 0 {
+				choices = nil // the translation leaves choices to the original
+			}
+			if q.Translations == nil {
+				q.Translations = make(map[string]QuestionTranslation)
+			}
+			q.Translations[lang] = QuestionTranslation{Question: text, Choices: choices, Explanation: explanation}
+		}
+		questions = append(questions, q)
+	}
+	return questions, nil
+}
+
+// encodeQuestionsCSV renders a question bank in the documented CSV column layout
+func encodeQuestionsCSV(questions []Question) ([]byte, error) {
+	maxChoices := 0
+	maxTranslatedChoices := make(map[string]int)
+	for _, q := range questions {
+		if len(q.Choices) > maxChoices {
+			maxChoices = len(q.Choices)
+		}
+		for lang, t := range q.Translations {
+			maxTranslatedChoices[lang] = max(maxTranslatedChoices[lang], len(t.Choices))
+		}
+	}
+	langs := make([]string, 0, len(maxTranslatedChoices))
+	for lang := range maxTranslatedChoices {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	header := []string{csvColumnID, csvColumnQuestion, csvColumnAnswerIndex, csvColumnExplanation}
+	for n := 1; n <= maxChoices; n++ {
+		header = append(header, fmt.Sprintf("%s%d", csvChoicePrefix, n))
+	}
+	for _, lang := range langs {
+		suffix := csvLanguageSeparator + lang
+		header = append(header, csvColumnQuestion+suffix, csvColumnExplanation+suffix)
+		for n := 1; n <= maxTranslatedChoices[lang]; n++ {
+			header = append(header, fmt.Sprintf("%s%d%s", csvChoicePrefix, n, suffix))
+		}
+	}
+
+	padded := func(record []string, choices []string, width int) []string {
+		for n := 0; n < width; n++ {
+			if n < len(choices) {
+				record = append(record, choices[n])
+			} else {
+				record = append(record, "")
+			}
+		}
+		return record
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to encode questions CSV: %w", err)
+	}
+	for _, q := range questions {
+		record := padded([]string{q.ID, q.Question, strconv.Itoa(q.AnswerIndex), q.Explanation}, q.Choices, maxChoices)
+		for _, lang := range langs {
+			t := q.Translations[lang]
+			record = padded(append(record, t.Question, t.Explanation), t.Choices, maxTranslatedChoices[lang])
+		}
+		if err := w.Write(record); err != nil {
+			return nil, fmt.Errorf("failed to encode questions CSV: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to encode questions CSV: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// runConvertQuestions implements `helloworld convert-questions [flags] input`
+// It reads a bank in any supported format and writes it in another.
+func runConvertQuestions(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("convert-questions", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	to := fs.String("to", "", "Output format: json, csv or yaml (defaults to the -o extension)")
+	output := fs.String("o", "", "Output file (defaults to stdout)")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: helloworld convert-questions [-to json|csv|yaml] [-o output] input")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	outFormat := *to
+	if outFormat == "yml" {
+		outFormat = formatYAML
+	}
+	if outFormat == "" {
+		if *output == "" {
+			fmt.Fprintln(stderr, "either -to or -o must be given")
+			return 2
+		}
+		inferred, err := questionFormatFromPath(*output)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		outFormat = inferred
+	}
+	if outFormat != formatJSON && outFormat != formatCSV && outFormat != formatYAML {
+		fmt.Fprintf(stderr, "unknown format %q (must be json, csv or yaml)\n", outFormat)
+		return 2
+	}
+
+	// loadQuestions applies the same validation the server uses
+	questions, err := loadQuestions(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	data, err := encodeQuestions(questions, outFormat)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if *output == "" {
+		if _, err := stdout.Write(data); err != nil {
+			fmt.Fprintf(stderr, "failed to write output: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		fmt.Fprintf(stderr, "failed to write %s: %v\n", *output, err)
+		return 1
+	}
+	fmt.Fprintf(stderr, "wrote %d question(s) to %s\n", len(questions), *output)
+	return 0
+}
+
+// globQuestionBanks lists every supported question bank directly inside dir
+func globQuestionBanks(dir string) ([]string, error) {
+	var files []string
+	for _, ext := range questionBankExtensions {
+		matches, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// formatFixtureQuestions exercises the awkward cases for each format
+var formatFixtureQuestions = []Question{
+	{ID: "q1", Question: "Which element, if any, rules \"Leo\"?", Choices: []string{"Fire", "Earth, mostly", "Air", "Water"}, AnswerIndex: 0, Explanation: "Leo is a Fire sign.\nIt is ruled by the Sun."},
+	{ID: "q2", Question: "Vrai ou faux : 🌙 gouverne le Cancer ?", Choices: []string{"Vrai", "Faux"}, AnswerIndex: 0, Explanation: ""},
+	{ID: "q3", Question: "- yes: this looks like YAML", Choices: []string{"true", "null", "0x1F"}, AnswerIndex: 2, Explanation: "# not a comment"},
+	{ID: "q4", Question: "Which planet rules Cancer?", Choices: []string{"Mars", "Moon", "Venus"}, AnswerIndex: 1, Explanation: "The Moon.", Translations: map[string]QuestionTranslation{
+		"es": {Question: "¿Qué planeta rige Cáncer?", Choices: []string{"Marte", "Luna", "Venus"}},
+		"fr": {Explanation: "La Lune, \"évidemment\"."},
+	}},
+}
+
+// TestQuestionFormats_RoundTrip tests lossless conversion through every supported format
+func TestQuestionFormats_RoundTrip(t *testing.T) {
+	bundled, err := loadQuestions("questions.json")
+	if err != nil {
+		t.Fatalf("failed to load bundled questions: %v", err)
+	}
+
+	banks := map[string][]Question{
+		"bundled": bundled,
+		"fixture": formatFixtureQuestions,
+	}
+
+	for bankName, questions := range banks {
+		for _, format := range []string{formatJSON, formatCSV, formatYAML} {
+			t.Run(bankName+"/"+format, func(t *testing.T) {
+				data, err := encodeQuestions(questions, format)
+				if err != nil {
+					t.Fatalf("encodeQuestions() failed: %v", err)
+				}
+
+				decoded, err := decodeQuestions(data, format, true)
+				if err != nil {
+					t.Fatalf("decodeQuestions() failed: %v\n%s", err, data)
+				}
+
+				if !reflect.DeepEqual(decoded, questions) {
+					t.Errorf("round-trip mismatch:\nwant %+v\ngot  %+v", questions, decoded)
+				}
+
+				// Encoding the decoded bank again must be byte-for-byte stable
+				again, err := encodeQuestions(decoded, format)
+				if err != nil {
+					t.Fatalf("second encodeQuestions() failed: %v", err)
+				}
+				if !bytes.Equal(data, again) {
+					t.Errorf("encoding is not canonical:\nfirst:\n%s\nsecond:\n%s", data, again)
+				}
+			})
+		}
+	}
+}
+
+// TestDecodeQuestionsCSV_Layout tests header handling of the CSV format
+func TestDecodeQuestionsCSV_Layout(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []Question
+		errorMsg string
+	}{
+		{
+			name:    "columns in any order with ragged choices",
+			content: "choice_1,choice_2,choice_3,answer_index,id,question,explanation\nA,B,C,2,q1,Q1?,E1\nX,Y,,1,q2,Q2?,E2\n",
+			expected: []Question{
+				{ID: "q1", Question: "Q1?", Choices: []string{"A", "B", "C"}, AnswerIndex: 2, Explanation: "E1"},
+				{ID: "q2", Question: "Q2?", Choices: []string{"X", "Y"}, AnswerIndex: 1, Explanation: "E2"},
+			},
+		},
+		{
+			name:    "byte order mark",
+			content: "\ufeffid,question,answer_index,choice_1,choice_2\nq1,Q1?,1,A,B\n",
+			expected: []Question{
+				{ID: "q1", Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 1},
+			},
+		},
+		{
+			name:     "header only",
+			content:  "id,question,answer_index,explanation,choice_1\n",
+			expected: []Question{},
+		},
+		{
+			name:     "unknown column",
+			content:  "id,question,answer_index,explanation,choice_1,difficulty\nq1,Q?,0,E,A,hard\n",
+			errorMsg: `unknown column "difficulty"`,
+		},
+		{
+			name:     "missing required column",
+			content:  "id,question,choice_1\nq1,Q?,A\n",
+			errorMsg: `missing required column "answer_index"`,
+		},
+		{
+			name:     "gap in choice columns",
+			content:  "id,question,answer_index,choice_1,choice_3\nq1,Q?,0,A,C\n",
+			errorMsg: "missing column choice_2",
+		},
+		{
+			name:    "translation columns",
+			content: "id,question,answer_index,choice_1,choice_2,question@es,choice_1@es,choice_2@es,explanation@es\nq1,Q1?,0,A,B,P1?,A-es,B-es,\nq2,Q2?,1,C,D,,,,\n",
+			expected: []Question{
+				{ID: "q1", Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 0, Translations: map[string]QuestionTranslation{
+					"es": {Question: "P1?", Choices: []string{"A-es", "B-es"}},
+				}},
+				{ID: "q2", Question: "Q2?", Choices: []string{"C", "D"}, AnswerIndex: 1},
+			},
+		},
+		{
+			name:     "translation choice gap",
+			content:  "id,question,answer_index,choice_1,choice_1@es,choice_3@es\nq1,Q?,0,A,B,C\n",
+			errorMsg: "missing column choice_2@es",
+		},
+		{
+			name:     "empty language code",
+			content:  "id,question,answer_index,choice_1,question@\nq1,Q?,0,A,B\n",
+			errorMsg: `column "question@" has an empty language code`,
+		},
+		{
+			name:     "unknown translated column",
+			content:  "id,question,answer_index,choice_1,answer_index@es\nq1,Q?,0,A,1\n",
+			errorMsg: `unknown column "answer_index@es"`,
+		},
+		{
+			name:     "non-numeric answer index",
+			content:  "id,question,answer_index,choice_1,choice_2\nq1,Q?,first,A,B\n",
+			errorMsg: `row 2 has invalid answer_index "first"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			questions, err := decodeQuestions([]byte(tt.content), formatCSV, true)
+			if tt.errorMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+					t.Fatalf("expected error containing %q, got %v", tt.errorMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(questions, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, questions)
+			}
+		})
+	}
+}
+
+// TestDecodeQuestionsYAML_Strict tests that unknown YAML keys are only rejected in strict mode
+func TestDecodeQuestionsYAML_Strict(t *testing.T) {
+	content := []byte("- id: q1\n  question: Q?\n  choices: [A, B]\n  answer_index: 1\n  difficulty: hard\n")
+
+	if _, err := decodeQuestions(content, formatYAML, true); err == nil {
+		t.Error("expected strict decoding to reject unknown key")
+	}
+
+	questions, err := decodeQuestions(content, formatYAML, false)
+	if err != nil {
+		t.Fatalf("lenient decoding failed: %v", err)
+	}
+	if len(questions) != 1 || questions[0].AnswerIndex != 1 || len(questions[0].Choices) != 2 {
+		t.Errorf("unexpected questions: %+v", questions)
+	}
+}
+
+// TestLoadQuestions_Formats tests that loadQuestions picks the parser from the extension and validates
+func TestLoadQuestions_Formats(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		errorMsg string
+	}{
+		{
+			name:    "csv",
+			file:    "bank.csv",
+			content: "id,question,answer_index,explanation,choice_1,choice_2\nq1,Q?,1,E,A,B\n",
+		},
+		{
+			name:    "yaml",
+			file:    "bank.yaml",
+			content: "- id: q1\n  question: Q?\n  choices: [A, B]\n  answer_index: 1\n  explanation: E\n",
+		},
+		{
+			name:    "yml",
+			file:    "bank.yml",
+			content: "- id: q1\n  question: Q?\n  choices: [A, B]\n  answer_index: 1\n  explanation: E\n",
+		},
+		{
+			name:     "csv validation",
+			file:     "bank.csv",
+			content:  "id,question,answer_index,explanation,choice_1,choice_2\nq1,Q?,5,E,A,B\n",
+			errorMsg: "must be < 2 choices",
+		},
+		{
+			name:     "invalid yaml",
+			file:     "bank.yaml",
+			content:  "- id: [unterminated\n",
+			errorMsg: "failed to parse questions YAML",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("failed to create test file: %v", err)
+			}
+
+			questions, err := loadQuestions(path)
+			if tt.errorMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+					t.Fatalf("expected error containing %q, got %v", tt.errorMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadQuestions() failed: %v", err)
+			}
+			expected := []Question{{ID: "q1", Question: "Q?", Choices: []string{"A", "B"}, AnswerIndex: 1, Explanation: "E"}}
+			if !reflect.DeepEqual(questions, expected) {
+				t.Errorf("expected %+v, got %+v", expected, questions)
+			}
+		})
+	}
+}
+
+// TestFindQuestionBank tests extension lookup order for question banks
+func TestFindQuestionBank(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "questions")
+
+	if _, err := findQuestionBank(base); err == nil {
+		t.Error("expected error when no bank exists")
+	}
+
+	for _, ext := range []string{".csv", ".yaml"} {
+		if err := os.WriteFile(base+ext, []byte(""), 0644); err != nil {
+			t.Fatalf("failed to create bank: %v", err)
+		}
+	}
+
+	found, err := findQuestionBank(base)
+	if err != nil {
+		t.Fatalf("findQuestionBank() failed: %v", err)
+	}
+	if found != base+".yaml" {
+		t.Errorf("expected YAML to win over CSV, got %s", found)
+	}
+}
+
+// TestConvertQuestions tests the convert-questions subcommand
+func TestConvertQuestions(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "tarot.csv")
+	jsonPath := filepath.Join(dir, "tarot.json")
+
+	var stdout, stderr bytes.Buffer
+	if code := runConvertQuestions([]string{"-o", csvPath, "tarot_questions.json"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("JSON to CSV failed with code %d: %s", code, stderr.String())
+	}
+	if code := runConvertQuestions([]string{"-o", jsonPath, csvPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("CSV to JSON failed with code %d: %s", code, stderr.String())
+	}
+
+	original, err := os.ReadFile("tarot_questions.json")
+	if err != nil {
+		t.Fatalf("failed to read original: %v", err)
+	}
+	converted, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatalf("failed to read converted: %v", err)
+	}
+	if !bytes.Equal(original, converted) {
+		t.Error("JSON -> CSV -> JSON conversion is not lossless")
+	}
+
+	// -to writes to stdout
+	stdout.Reset()
+	if code := runConvertQuestions([]string{"-to", "yaml", csvPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("CSV to YAML failed with code %d: %s", code, stderr.String())
+	}
+	if !strings.HasPrefix(stdout.String(), "- id: tarot1\n") {
+		t.Errorf("unexpected YAML output: %.80s", stdout.String())
+	}
+
+	// Usage errors
+	for _, args := range [][]string{{}, {"tarot_questions.json"}, {"-to", "xml", "tarot_questions.json"}} {
+		if code := runConvertQuestions(args, &stdout, &stderr); code != 2 {
+			t.Errorf("expected exit code 2 for %v, got %d", args, code)
+		}
+	}
+}
@@ -0,0 +1,695 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Constants for multiplayer room configuration
+const (
+	DefaultRoomRounds    = 10
+	MaxRoomPlayers       = 100
+	roomCodeLength       = 5
+	roomCodeAlphabet     = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // no 0/O or 1/I lookalikes
+	roomIdleTimeout      = 2 * time.Hour
+	roomSubscriberBuffer = 8
+	roomSweepEvery       = 5 * time.Minute
+	MaxOpenRooms         = 1000 // across all clients
+	MaxRoomsPerClient    = 5    // open rooms created from one IP address
+)
+
+// roomRoundDuration is how long players have to answer each question
+// It is a variable so tests can shorten it.
+var roomRoundDuration = 20 * time.Second
+
+// roomPhase is a state of the room state machine:
+// lobby -> question -> reveal -> question ... -> finished
+type roomPhase string
+
+const (
+	phaseLobby    roomPhase = "lobby"
+	phaseQuestion roomPhase = "question"
+	phaseReveal   roomPhase = "reveal"
+	phaseFinished roomPhase = "finished"
+)
+
+// Room errors reported back to clients
+var (
+	errRoomNotFound    = errors.New("room not found")
+	errRoomStarted     = errors.New("the game has already started")
+	errRoomFull        = errors.New("the room is full")
+	errRoomNoPlayers   = errors.New("at least one player must join before starting")
+	errNotAcceptingAns = errors.New("answers are not being accepted right now")
+	errAlreadyAnswered = errors.New("you have already answered this question")
+	errUnknownPlayer   = errors.New("you are not a player in this room")
+	errRoomFinished    = errors.New("the game is over")
+	errTooManyRooms    = errors.New("too many rooms are open, please try again later")
+	errClientRooms     = errors.New("you already have too many open rooms")
+)
+
+// roomPlayer is a participant in a room
+type roomPlayer struct {
+	ID     string // public identifier shown to other clients
+	Name   string
+	Score  int
+	token  string // secret stored in the player's cookie
+	joined int    // join order, used to break scoreboard ties
+}
+
+// Room is a live multiplayer quiz shared by a host and its players
+type Room struct {
+	mu sync.Mutex
+
+	Code        string
+	QuizType    string
+	QuestionIDs []string
+	Phase       roomPhase
+	Round       int // index into QuestionIDs of the current or last question
+
+	hostToken  string
+	creator    string // IP address that created the room, for the per-client cap
+	questions  []Question
+	players    map[string]*roomPlayer // keyed by token
+	answers    map[string]string      // token -> submitted answer for the current round
+	lastRound  map[string]bool        // player ID -> answered correctly in the last revealed round
+	deadline   time.Time
+	timer      *time.Timer
+	updatedAt  time.Time
+	events     *broadcaster
+	joinNumber int
+}
+
+// roomQuestionView is the question as shown to players; the answer is only included once revealed
+type roomQuestionView struct {
+	Text        string   `json:"text"`
+	Choices     []string `json:"choices"`
+	AnswerIndex *int     `json:"answer_index,omitempty"`
+	Explanation string   `json:"explanation,omitempty"`
+}
+
+// roomPlayerView is a scoreboard row
+type roomPlayerView struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Score    int    `json:"score"`
+	Rank     int    `json:"rank"`
+	Answered bool   `json:"answered"`
+	Correct  *bool  `json:"correct,omitempty"`
+}
+
+// roomSnapshot is the state broadcast to every client after each change
+type roomSnapshot struct {
+	Code     string            `json:"code"`
+	QuizType string            `json:"quiz_type"`
+	Phase    roomPhase         `json:"phase"`
+	Round    int               `json:"round"` // 1-indexed, 0 in the lobby
+	Rounds   int               `json:"rounds"`
+	Question *roomQuestionView `json:"question,omitempty"`
+	Deadline int64             `json:"deadline,omitempty"` // Unix milliseconds
+	Answered int               `json:"answered"`
+	Players  []roomPlayerView  `json:"players"`
+}
+
+// roomManager tracks every open room
+type roomManager struct {
+	mu    sync.Mutex
+	rooms map[string]*Room
+}
+
+// rooms is the global room registry
+var rooms = &roomManager{rooms: make(map[string]*Room)}
+
+// randomToken returns n random bytes encoded as hex
+func randomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand never fails on supported platforms
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// randomRoomCode returns a short human-friendly room code
+func randomRoomCode() string {
+	b := make([]byte, roomCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	for i := range b {
+		b[i] = roomCodeAlphabet[int(b[i])%len(roomCodeAlphabet)]
+	}
+	return string(b)
+}
+
+// create opens a new room for the quiz type with up to the given number of rounds, on behalf of
+// the client at the given address
+func (m *roomManager) create(quizType string, rounds int, creator string, now time.Time) (*Room, error) {
+	questions, exists := questionSets[quizType]
+	if !exists || len(questions) == 0 {
+		return nil, errors.New("quiz type not found")
+	}
+	if rounds < 1 {
+		rounds = DefaultRoomRounds
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pruneLocked(now)
+	if len(m.rooms) >= MaxOpenRooms {
+		return nil, errTooManyRooms
+	}
+	owned := 0
+	for _, room := range m.rooms {
+		if room.creator == creator {
+			owned++
+		}
+	}
+	if owned >= MaxRoomsPerClient {
+		return nil, errClientRooms
+	}
+
+	code := randomRoomCode()
+	for m.rooms[code] != nil {
+		code = randomRoomCode()
+	}
+
+	room := &Room{
+		Code:        code,
+		QuizType:    quizType,
+		QuestionIDs: selectQuestionIDs(questions, rounds),
+		Phase:       phaseLobby,
+		hostToken:   randomToken(16),
+		creator:     creator,
+		questions:   questions,
+		players:     make(map[string]*roomPlayer),
+		answers:     make(map[string]string),
+		updatedAt:   now,
+		events:      newSnapshotBroadcaster(roomSubscriberBuffer),
+	}
+	m.rooms[code] = room
+	return room, nil
+}
+
+// get looks up a room by its (case-insensitive) code
+func (m *roomManager) get(code string) (*Room, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	room, ok := m.rooms[strings.ToUpper(strings.TrimSpace(code))]
+	return room, ok
+}
+
+// prune drops rooms that have been idle for longer than roomIdleTimeout
+func (m *roomManager) prune(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked(now)
+}
+
+// sweepRooms prunes idle rooms every interval until stop is closed, so abandoned rooms are
+// freed even when nobody creates a new one
+func sweepRooms(m *roomManager, every time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.prune(time.Now())
+		case <-stop:
+			return
+		}
+	}
+}
+
+// pruneLocked drops rooms that have been idle for longer than roomIdleTimeout
+func (m *roomManager) pruneLocked(now time.Time) {
+	for code, room := range m.rooms {
+		room.mu.Lock()
+		idle := now.Sub(room.updatedAt) > roomIdleTimeout
+		if idle && room.timer != nil {
+			room.timer.Stop()
+		}
+		room.mu.Unlock()
+		if idle {
+			room.events.closeAll()
+			delete(m.rooms, code)
+		}
+	}
+}
+
+// join adds a player to a room that is still in its lobby
+func (room *Room) join(name string, now time.Time) (*roomPlayer, error) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if room.Phase != phaseLobby {
+		return nil, errRoomStarted
+	}
+	if len(room.players) >= MaxRoomPlayers {
+		return nil, errRoomFull
+	}
+
+	room.joinNumber++
+	player := &roomPlayer{
+		ID:     strconv.Itoa(room.joinNumber),
+		Name:   name,
+		token:  randomToken(16),
+		joined: room.joinNumber,
+	}
+	room.players[player.token] = player
+	room.touchLocked(now)
+	return player, nil
+}
+
+// player returns the player holding the given token
+func (room *Room) player(token string) (*roomPlayer, bool) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	p, ok := room.players[token]
+	return p, ok
+}
+
+// isHost reports whether the token belongs to the room's host
+func (room *Room) isHost(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(room.hostToken)) == 1
+}
+
+// advance moves the state machine forward on the host's command:
+// lobby -> first question, question -> reveal, reveal -> next question or finished
+func (room *Room) advance(now time.Time) error {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	switch room.Phase {
+	case phaseLobby:
+		if len(room.players) == 0 {
+			return errRoomNoPlayers
+		}
+		room.startRoundLocked(0, now)
+	case phaseQuestion:
+		room.revealLocked(now)
+	case phaseReveal:
+		if room.Round+1 < len(room.QuestionIDs) {
+			room.startRoundLocked(room.Round+1, now)
+		} else {
+			room.Phase = phaseFinished
+			room.touchLocked(now)
+		}
+	case phaseFinished:
+		return errRoomFinished
+	}
+	return nil
+}
+
+// answer records a player's choice for the current question.
+// Once every player has answered the round is revealed immediately.
+func (room *Room) answer(token, answer string, now time.Time) error {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if _, ok := room.players[token]; !ok {
+		return errUnknownPlayer
+	}
+	if room.Phase != phaseQuestion {
+		return errNotAcceptingAns
+	}
+	if _, done := room.answers[token]; done {
+		return errAlreadyAnswered
+	}
+
+	room.answers[token] = answer
+	if len(room.answers) == len(room.players) {
+		room.revealLocked(now)
+	} else {
+		room.touchLocked(now)
+	}
+	return nil
+}
+
+// startRoundLocked shows question i and arms the round timer
+func (room *Room) startRoundLocked(i int, now time.Time) {
+	room.Phase = phaseQuestion
+	room.Round = i
+	room.answers = make(map[string]string)
+	room.deadline = now.Add(roomRoundDuration)
+
+	if room.timer != nil {
+		room.timer.Stop()
+	}
+	room.timer = time.AfterFunc(roomRoundDuration, func() {
+		room.expire(i)
+	})
+	room.touchLocked(now)
+}
+
+// expire reveals round i if it is still open when its timer fires
+func (room *Room) expire(i int) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	if room.Phase == phaseQuestion && room.Round == i {
+		room.revealLocked(time.Now())
+	}
+}
+
+// revealLocked scores the current round and shows the answer
+func (room *Room) revealLocked(now time.Time) {
+	if room.timer != nil {
+		room.timer.Stop()
+		room.timer = nil
+	}
+
+	question, _ := findQuestion(room.questions, room.QuestionIDs[room.Round])
+	room.lastRound = make(map[string]bool, len(room.players))
+	for token, player := range room.players {
+		correct := isCorrectAnswer(question, room.answers[token])
+		if correct {
+			player.Score++
+		}
+		room.lastRound[player.ID] = correct
+	}
+
+	room.Phase = phaseReveal
+	room.touchLocked(now)
+}
+
+// touchLocked records activity and broadcasts the new state
+func (room *Room) touchLocked(now time.Time) {
+	room.updatedAt = now
+	data, err := json.Marshal(room.snapshotLocked())
+	if err != nil {
+		log.Printf("Error marshaling room snapshot: %v", err)
+		return
+	}
+	room.events.publish(sseEvent{Name: "state", Data: data})
+}
+
+// snapshot returns the current room state as seen by clients
+func (room *Room) snapshot() roomSnapshot {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	return room.snapshotLocked()
+}
+
+// snapshotLocked builds the client view; the caller must hold room.mu
+func (room *Room) snapshotLocked() roomSnapshot {
+	snap := roomSnapshot{
+		Code:     room.Code,
+		QuizType: room.QuizType,
+		Phase:    room.Phase,
+		Rounds:   len(room.QuestionIDs),
+		Answered: len(room.answers),
+		Players:  make([]roomPlayerView, 0, len(room.players)),
+	}
+
+	if room.Phase != phaseLobby {
+		snap.Round = room.Round + 1
+	}
+	if room.Phase == phaseQuestion || room.Phase == phaseReveal {
+		q, _ := findQuestion(room.questions, room.QuestionIDs[room.Round])
+		view := &roomQuestionView{Text: q.Question, Choices: q.Choices}
+		if room.Phase == phaseReveal {
+			answerIndex := q.AnswerIndex
+			view.AnswerIndex = &answerIndex
+			view.Explanation = q.Explanation
+		}
+		snap.Question = view
+	}
+	if room.Phase == phaseQuestion {
+		snap.Deadline = room.deadline.UnixMilli()
+	}
+
+	players := make([]*roomPlayer, 0, len(room.players))
+	for _, p := range room.players {
+		players = append(players, p)
+	}
+	sort.Slice(players, func(i, j int) bool {
+		if players[i].Score != players[j].Score {
+			return players[i].Score > players[j].Score
+		}
+		return players[i].joined < players[j].joined
+	})
+
+	for i, p := range players {
+		view := roomPlayerView{ID: p.ID, Name: p.Name, Score: p.Score, Rank: i + 1}
+		if i > 0 && p.Score == players[i-1].Score {
+			view.Rank = snap.Players[i-1].Rank
+		}
+		_, view.Answered = room.answers[p.token]
+		if room.Phase == phaseReveal || room.Phase == phaseFinished {
+			if correct, ok := room.lastRound[p.ID]; ok {
+				view.Correct = &correct
+			}
+		}
+		snap.Players = append(snap.Players, view)
+	}
+	return snap
+}
+
+// roomCookieName is the cookie holding a player's token for a room
+func roomCookieName(code string) string {
+	return "room_" + code
+}
+
+// roomHostCookieName is the cookie holding the host's token for a room
+func roomHostCookieName(code string) string {
+	return "room_host_" + code
+}
+
+// setRoomCookie stores a room token scoped to that room's URLs
+func setRoomCookie(w http.ResponseWriter, name, code, value string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/rooms/" + code,
+		MaxAge:   int(roomIdleTimeout.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// cookieValue returns the named cookie's value or "" when absent
+func cookieValue(r *http.Request, name string) string {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+// RoomsPageData represents the data passed to the rooms.html template
+type RoomsPageData struct {
+	QuizTypes []string
+	Error     string
+}
+
+// RoomPageData represents the data passed to the room.html template
+type RoomPageData struct {
+	Code       string
+	QuizType   string
+	IsHost     bool
+	PlayerID   string
+	PlayerName string
+	Error      string
+}
+
+// clientIP returns the address of the connecting client. Forwarding headers are ignored because
+// clients could set them to dodge the per-client room cap.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// renderRoomsPage renders the create/join page with an optional error
+func renderRoomsPage(w http.ResponseWriter, status int, errMsg string) {
+	quizTypes := make([]string, 0, len(questionSets))
+	for quizType, questions := range questionSets {
+		if len(questions) > 0 {
+			quizTypes = append(quizTypes, quizType)
+		}
+	}
+	sort.Strings(quizTypes)
+
+	pageTemplates.render(w, status, "rooms", defaultLanguage, RoomsPageData{QuizTypes: quizTypes, Error: errMsg})
+}
+
+// roomsHandler handles /rooms: GET shows the create/join page, POST creates a room
+func roomsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		renderRoomsPage(w, http.StatusOK, "")
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		rounds, _ := strconv.Atoi(r.FormValue("rounds"))
+		room, err := rooms.create(r.FormValue("type"), rounds, clientIP(r), time.Now())
+		if err != nil {
+			status := http.StatusBadRequest
+			if err == errTooManyRooms || err == errClientRooms {
+				status = http.StatusTooManyRequests
+			}
+			renderRoomsPage(w, status, err.Error())
+			return
+		}
+
+		setRoomCookie(w, roomHostCookieName(room.Code), room.Code, room.hostToken)
+		http.Redirect(w, r, "/rooms/"+room.Code, http.StatusSeeOther)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// roomJoinHandler handles POST /rooms/join with a room code and player name
+func roomJoinHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	room, ok := rooms.get(r.FormValue("code"))
+	if !ok {
+		renderRoomsPage(w, http.StatusNotFound, "No room with that code")
+		return
+	}
+
+	name, err := validatePlayerName(r.FormValue("name"))
+	if err != nil {
+		renderRoomsPage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Rejoining with an existing cookie keeps the original player
+	if _, joined := room.player(cookieValue(r, roomCookieName(room.Code))); !joined {
+		player, err := room.join(name, time.Now())
+		if err != nil {
+			renderRoomsPage(w, http.StatusConflict, err.Error())
+			return
+		}
+		setRoomCookie(w, roomCookieName(room.Code), room.Code, player.token)
+	}
+
+	http.Redirect(w, r, "/rooms/"+room.Code, http.StatusSeeOther)
+}
+
+// roomPageHandler handles GET /rooms/{code} and renders the live room page
+func roomPageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	room, ok := rooms.get(r.PathValue("code"))
+	if !ok {
+		renderRoomsPage(w, http.StatusNotFound, "No room with that code")
+		return
+	}
+
+	data := RoomPageData{
+		Code:     room.Code,
+		QuizType: room.QuizType,
+		IsHost:   room.isHost(cookieValue(r, roomHostCookieName(room.Code))),
+	}
+	if player, ok := room.player(cookieValue(r, roomCookieName(room.Code))); ok {
+		data.PlayerID = player.ID
+		data.PlayerName = player.Name
+	}
+
+	renderPage(w, "room", defaultLanguage, data)
+}
+
+// roomEventsHandler handles GET /rooms/{code}/events, streaming room snapshots over SSE
+func roomEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	room, ok := rooms.get(r.PathValue("code"))
+	if !ok {
+		http.Error(w, errRoomNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	events, unsubscribe := room.events.subscribe()
+	defer unsubscribe()
+
+	data, err := json.Marshal(room.snapshot())
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Error marshaling room snapshot: %v", err)
+		return
+	}
+	serveSSE(w, r, events, sseEvent{Name: "state", Data: data})
+}
+
+// roomAnswerHandler handles POST /rooms/{code}/answer from a joined player
+func roomAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	room, ok := rooms.get(r.PathValue("code"))
+	if !ok {
+		http.Error(w, errRoomNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	err := room.answer(cookieValue(r, roomCookieName(room.Code)), r.FormValue("answer"), time.Now())
+	switch {
+	case errors.Is(err, errUnknownPlayer):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// roomAdvanceHandler handles POST /rooms/{code}/advance from the host
+func roomAdvanceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	room, ok := rooms.get(r.PathValue("code"))
+	if !ok {
+		http.Error(w, errRoomNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if !room.isHost(cookieValue(r, roomHostCookieName(room.Code))) {
+		http.Error(w, "Only the host can control the game", http.StatusForbidden)
+		return
+	}
+
+	if err := room.advance(time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,554 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// roomTestQuestions installs a small question set for room tests
+func roomTestQuestions(t *testing.T) {
+	t.Helper()
+	oldQuestionSets := questionSets
+	questionSets = map[string][]Question{
+		"astrology": {
+			{ID: "q1", Question: "Test Q1?", Choices: []string{"A", "B", "C"}, AnswerIndex: 0, Explanation: "Exp1"},
+			{ID: "q2", Question: "Test Q2?", Choices: []string{"X", "Y", "Z"}, AnswerIndex: 1, Explanation: "Exp2"},
+			{ID: "q3", Question: "Test Q3?", Choices: []string{"1", "2", "3"}, AnswerIndex: 2, Explanation: "Exp3"},
+		},
+	}
+	t.Cleanup(func() { questionSets = oldQuestionSets })
+}
+
+// correctAnswerFor returns the correct choice index for the room's current question
+func correctAnswerFor(room *Room) string {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	q, _ := findQuestion(room.questions, room.QuestionIDs[room.Round])
+	return fmt.Sprint(q.AnswerIndex)
+}
+
+// TestRoom_StateMachine tests lobby -> question -> reveal -> finished transitions and scoring
+func TestRoom_StateMachine(t *testing.T) {
+	roomTestQuestions(t)
+	now := time.Now()
+
+	room, err := rooms.create("astrology", 2, "192.0.2.1", now)
+	if err != nil {
+		t.Fatalf("create() failed: %v", err)
+	}
+	if room.Phase != phaseLobby || len(room.QuestionIDs) != 2 {
+		t.Fatalf("unexpected new room: phase=%s questions=%d", room.Phase, len(room.QuestionIDs))
+	}
+
+	// The host cannot start an empty room
+	if err := room.advance(now); err != errRoomNoPlayers {
+		t.Errorf("expected errRoomNoPlayers, got %v", err)
+	}
+
+	alice, err := room.join("Alice", now)
+	if err != nil {
+		t.Fatalf("join() failed: %v", err)
+	}
+	bob, err := room.join("Bob", now)
+	if err != nil {
+		t.Fatalf("join() failed: %v", err)
+	}
+
+	// Answers are rejected in the lobby
+	if err := room.answer(alice.token, "0", now); err != errNotAcceptingAns {
+		t.Errorf("expected errNotAcceptingAns, got %v", err)
+	}
+
+	if err := room.advance(now); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	if room.Phase != phaseQuestion {
+		t.Fatalf("expected question phase, got %s", room.Phase)
+	}
+
+	// Late joiners are turned away once the game starts
+	if _, err := room.join("Carol", now); err != errRoomStarted {
+		t.Errorf("expected errRoomStarted, got %v", err)
+	}
+
+	correct := correctAnswerFor(room)
+	if err := room.answer(alice.token, correct, now); err != nil {
+		t.Fatalf("answer() failed: %v", err)
+	}
+	if err := room.answer(alice.token, correct, now); err != errAlreadyAnswered {
+		t.Errorf("expected errAlreadyAnswered, got %v", err)
+	}
+	if err := room.answer("not-a-player", correct, now); err != errUnknownPlayer {
+		t.Errorf("expected errUnknownPlayer, got %v", err)
+	}
+
+	// The round is revealed as soon as the last player answers
+	if err := room.answer(bob.token, "", now); err != nil {
+		t.Fatalf("answer() failed: %v", err)
+	}
+	snap := room.snapshot()
+	if snap.Phase != phaseReveal {
+		t.Fatalf("expected reveal phase, got %s", snap.Phase)
+	}
+	if snap.Question == nil || snap.Question.AnswerIndex == nil {
+		t.Fatal("expected the answer to be revealed")
+	}
+	if snap.Players[0].Name != "Alice" || snap.Players[0].Score != 1 || snap.Players[1].Score != 0 {
+		t.Errorf("unexpected scoreboard: %+v", snap.Players)
+	}
+	if snap.Players[0].Correct == nil || !*snap.Players[0].Correct {
+		t.Error("expected Alice to be marked correct")
+	}
+
+	// Second round: host reveals before anyone answers
+	if err := room.advance(now); err != nil {
+		t.Fatalf("next failed: %v", err)
+	}
+	snap = room.snapshot()
+	if snap.Round != 2 || snap.Question.AnswerIndex != nil {
+		t.Errorf("expected round 2 with hidden answer, got round %d", snap.Round)
+	}
+	if err := room.advance(now); err != nil {
+		t.Fatalf("reveal failed: %v", err)
+	}
+	if err := room.advance(now); err != nil {
+		t.Fatalf("finish failed: %v", err)
+	}
+	if room.Phase != phaseFinished {
+		t.Errorf("expected finished phase, got %s", room.Phase)
+	}
+	if err := room.advance(now); err != errRoomFinished {
+		t.Errorf("expected errRoomFinished, got %v", err)
+	}
+}
+
+// TestRoom_TimerReveals tests that an unanswered round is revealed when its timer expires
+func TestRoom_TimerReveals(t *testing.T) {
+	roomTestQuestions(t)
+	oldDuration := roomRoundDuration
+	roomRoundDuration = 20 * time.Millisecond
+	defer func() { roomRoundDuration = oldDuration }()
+
+	room, err := rooms.create("astrology", 1, "192.0.2.1", time.Now())
+	if err != nil {
+		t.Fatalf("create() failed: %v", err)
+	}
+	if _, err := room.join("Alice", time.Now()); err != nil {
+		t.Fatalf("join() failed: %v", err)
+	}
+
+	events, unsubscribe := room.events.subscribe()
+	defer unsubscribe()
+
+	if err := room.advance(time.Now()); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case ev := <-events:
+			var snap roomSnapshot
+			if err := json.Unmarshal(ev.Data, &snap); err != nil {
+				t.Fatalf("invalid snapshot: %v", err)
+			}
+			if snap.Phase == phaseReveal {
+				return
+			}
+		case <-deadline:
+			t.Fatal("timer did not reveal the round")
+		}
+	}
+}
+
+// TestRoom_Prune tests that idle rooms are removed
+func TestRoom_Prune(t *testing.T) {
+	roomTestQuestions(t)
+	start := time.Now()
+
+	room, err := rooms.create("astrology", 1, "192.0.2.1", start)
+	if err != nil {
+		t.Fatalf("create() failed: %v", err)
+	}
+	if _, ok := rooms.get(strings.ToLower(room.Code)); !ok {
+		t.Fatal("expected room lookup to be case-insensitive")
+	}
+
+	if _, err := rooms.create("astrology", 1, "192.0.2.1", start.Add(roomIdleTimeout+time.Minute)); err != nil {
+		t.Fatalf("create() failed: %v", err)
+	}
+	if _, ok := rooms.get(room.Code); ok {
+		t.Error("expected idle room to be pruned")
+	}
+}
+
+// TestBroadcaster_DropsSlowSubscribers tests that a full subscriber is disconnected instead of blocking
+func TestBroadcaster_DropsSlowSubscribers(t *testing.T) {
+	b := newBroadcaster(2)
+	slow, _ := b.subscribe()
+	fast, unsubscribe := b.subscribe()
+	defer unsubscribe()
+
+	for i := 0; i < 3; i++ {
+		b.publish(sseEvent{Name: "tick", Data: []byte(fmt.Sprint(i))})
+		<-fast
+	}
+
+	if b.subscriberCount() != 1 {
+		t.Errorf("expected slow subscriber to be dropped, got %d subscribers", b.subscriberCount())
+	}
+
+	received := 0
+	for range slow {
+		received++
+	}
+	if received != 2 {
+		t.Errorf("expected slow subscriber to keep its 2 buffered events, got %d", received)
+	}
+}
+
+// TestBroadcaster_CoalescesSnapshots tests that a snapshot broadcaster keeps slow subscribers on the latest events
+func TestBroadcaster_CoalescesSnapshots(t *testing.T) {
+	b := newSnapshotBroadcaster(2)
+	slow, unsubscribe := b.subscribe()
+	defer unsubscribe()
+
+	for i := 0; i < 5; i++ {
+		b.publish(sseEvent{Name: "state", Data: []byte(fmt.Sprint(i))})
+	}
+
+	if b.subscriberCount() != 1 {
+		t.Fatalf("expected slow subscriber to stay connected, got %d subscribers", b.subscriberCount())
+	}
+	if first, second := string((<-slow).Data), string((<-slow).Data); first != "3" || second != "4" {
+		t.Errorf("expected the two newest events (3, 4), got %s, %s", first, second)
+	}
+}
+
+// roomHarnessClient is a headless browser for one room participant
+type roomHarnessClient struct {
+	t      *testing.T
+	base   string
+	client *http.Client
+}
+
+// newRoomHarnessClient creates a client with its own cookie jar that does not follow redirects
+func newRoomHarnessClient(t *testing.T, base string) *roomHarnessClient {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("cookiejar.New failed: %v", err)
+	}
+	return &roomHarnessClient{
+		t:    t,
+		base: base,
+		client: &http.Client{
+			Jar: jar,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// post submits a form and returns the status code
+func (c *roomHarnessClient) post(path string, form url.Values) int {
+	resp, err := c.client.PostForm(c.base+path, form)
+	if err != nil {
+		c.t.Errorf("POST %s failed: %v", path, err)
+		return 0
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// subscribe opens the room's event stream and delivers decoded snapshots on the returned channel
+func (c *roomHarnessClient) subscribe(code string) (<-chan roomSnapshot, func()) {
+	req, err := http.NewRequest(http.MethodGet, c.base+"/rooms/"+code+"/events", nil)
+	if err != nil {
+		c.t.Fatalf("failed to create events request: %v", err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.t.Fatalf("GET events failed: %v", err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		c.t.Fatalf("expected text/event-stream, got %s", ct)
+	}
+
+	snapshots := make(chan roomSnapshot, 64)
+	go func() {
+		defer close(snapshots)
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			var snap roomSnapshot
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &snap); err == nil {
+				snapshots <- snap
+			}
+		}
+	}()
+	return snapshots, func() { resp.Body.Close() }
+}
+
+// waitForPhase reads snapshots until one matches the phase and round
+func waitForPhase(t *testing.T, snapshots <-chan roomSnapshot, phase roomPhase, round int) roomSnapshot {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case snap, ok := <-snapshots:
+			if !ok {
+				t.Fatalf("event stream closed while waiting for %s round %d", phase, round)
+			}
+			if snap.Phase == phase && snap.Round == round {
+				return snap
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s round %d", phase, round)
+		}
+	}
+}
+
+// TestRoom_HeadlessHarness simulates a host and many players over HTTP and SSE
+func TestRoom_HeadlessHarness(t *testing.T) {
+	roomTestQuestions(t)
+
+	// Run through the logging middleware so streaming works with the wrapped ResponseWriter
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	server := httptest.NewServer(loggingMiddleware(setupRoutes()))
+	defer server.Close()
+
+	const numPlayers = 40
+
+	// Host creates the room
+	host := newRoomHarnessClient(t, server.URL)
+	resp, err := host.client.PostForm(server.URL+"/rooms", url.Values{"type": {"astrology"}, "rounds": {"3"}})
+	if err != nil {
+		t.Fatalf("create room failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected 303 from room creation, got %d", resp.StatusCode)
+	}
+	code := strings.TrimPrefix(resp.Header.Get("Location"), "/rooms/")
+	room, ok := rooms.get(code)
+	if !ok {
+		t.Fatalf("room %q was not created", code)
+	}
+
+	hostEvents, closeHost := host.subscribe(code)
+	defer closeHost()
+	waitForPhase(t, hostEvents, phaseLobby, 0)
+
+	// Players join concurrently and open their own event streams
+	players := make([]*roomHarnessClient, numPlayers)
+	streams := make([]<-chan roomSnapshot, numPlayers)
+	var wg sync.WaitGroup
+	for i := range players {
+		players[i] = newRoomHarnessClient(t, server.URL)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			status := players[i].post("/rooms/join", url.Values{"code": {code}, "name": {fmt.Sprintf("Player%d", i)}})
+			if status != http.StatusSeeOther {
+				t.Errorf("player %d join returned %d", i, status)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i, p := range players {
+		var closeStream func()
+		streams[i], closeStream = p.subscribe(code)
+		defer closeStream()
+	}
+
+	// Only the host may advance the game
+	if status := players[0].post("/rooms/"+code+"/advance", nil); status != http.StatusForbidden {
+		t.Errorf("expected 403 when a player advances, got %d", status)
+	}
+
+	for round := 1; round <= 3; round++ {
+		if status := host.post("/rooms/"+code+"/advance", nil); status != http.StatusNoContent {
+			t.Fatalf("advance to round %d returned %d", round, status)
+		}
+
+		// Everyone sees the same question at the same time
+		question := waitForPhase(t, hostEvents, phaseQuestion, round).Question
+		for i := range players {
+			snap := waitForPhase(t, streams[i], phaseQuestion, round)
+			if snap.Question.Text != question.Text {
+				t.Fatalf("player %d saw %q, host saw %q", i, snap.Question.Text, question.Text)
+			}
+		}
+
+		// Even-numbered players answer correctly; the last answer triggers the reveal
+		correct := correctAnswerFor(room)
+		for i := range players {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				answer := correct
+				if i%2 == 1 {
+					answer = "-1"
+				}
+				if status := players[i].post("/rooms/"+code+"/answer", url.Values{"answer": {answer}}); status != http.StatusNoContent {
+					t.Errorf("player %d answer returned %d", i, status)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		waitForPhase(t, hostEvents, phaseReveal, round)
+		for i := range players {
+			waitForPhase(t, streams[i], phaseReveal, round)
+		}
+	}
+
+	if status := host.post("/rooms/"+code+"/advance", nil); status != http.StatusNoContent {
+		t.Fatalf("finish returned %d", status)
+	}
+	final := waitForPhase(t, hostEvents, phaseFinished, 3)
+
+	if len(final.Players) != numPlayers {
+		t.Fatalf("expected %d players on the scoreboard, got %d", numPlayers, len(final.Players))
+	}
+	for i, p := range final.Players {
+		expected := 0
+		rank := numPlayers/2 + 1
+		if i < numPlayers/2 {
+			expected = 3
+			rank = 1
+		}
+		if p.Score != expected || p.Rank != rank {
+			t.Errorf("scoreboard row %d: expected score %d rank %d, got %+v", i, expected, rank, p)
+		}
+	}
+}
+
+// TestRoomHandlers tests the room HTTP endpoints' error handling
+func TestRoomHandlers(t *testing.T) {
+	roomTestQuestions(t)
+	mux := setupRoutes()
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		form           string
+		expectedStatus int
+		bodyContains   string
+	}{
+		{name: "rooms page", method: http.MethodGet, path: "/rooms", expectedStatus: http.StatusOK, bodyContains: "Quiz Night"},
+		{name: "rooms method not allowed", method: http.MethodPut, path: "/rooms", expectedStatus: http.StatusMethodNotAllowed, bodyContains: "Method Not Allowed"},
+		{name: "create unknown type", method: http.MethodPost, path: "/rooms", form: "type=unknown", expectedStatus: http.StatusBadRequest, bodyContains: "quiz type not found"},
+		{name: "join unknown room", method: http.MethodPost, path: "/rooms/join", form: "code=ZZZZZ&name=Al", expectedStatus: http.StatusNotFound, bodyContains: "No room with that code"},
+		{name: "unknown room page", method: http.MethodGet, path: "/rooms/ZZZZZ", expectedStatus: http.StatusNotFound, bodyContains: "No room with that code"},
+		{name: "unknown room events", method: http.MethodGet, path: "/rooms/ZZZZZ/events", expectedStatus: http.StatusNotFound, bodyContains: "room not found"},
+		{name: "answer method not allowed", method: http.MethodGet, path: "/rooms/ZZZZZ/answer", expectedStatus: http.StatusMethodNotAllowed, bodyContains: "Method Not Allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.form))
+			if tt.form != "" {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			rec := httptest.NewRecorder()
+
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), tt.bodyContains) {
+				t.Errorf("expected body to contain %q, got %q", tt.bodyContains, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestRoom_Caps tests the per-client and global limits on open rooms
+func TestRoom_Caps(t *testing.T) {
+	roomTestQuestions(t)
+	m := &roomManager{rooms: make(map[string]*Room)}
+	now := time.Now()
+
+	for i := 0; i < MaxRoomsPerClient; i++ {
+		if _, err := m.create("astrology", 1, "198.51.100.7", now); err != nil {
+			t.Fatalf("create() %d failed: %v", i, err)
+		}
+	}
+	if _, err := m.create("astrology", 1, "198.51.100.7", now); err != errClientRooms {
+		t.Errorf("expected errClientRooms, got %v", err)
+	}
+	if _, err := m.create("astrology", 1, "198.51.100.8", now); err != nil {
+		t.Errorf("expected another client to create a room, got %v", err)
+	}
+
+	// Idle rooms no longer count against the client
+	if _, err := m.create("astrology", 1, "198.51.100.7", now.Add(roomIdleTimeout+time.Minute)); err != nil {
+		t.Errorf("expected pruned rooms to free the client's quota, got %v", err)
+	}
+
+	for i := len(m.rooms); i < MaxOpenRooms; i++ {
+		if _, err := m.create("astrology", 1, fmt.Sprintf("client-%d", i), now); err != nil {
+			t.Fatalf("create() %d failed: %v", i, err)
+		}
+	}
+	if _, err := m.create("astrology", 1, "203.0.113.1", now); err != errTooManyRooms {
+		t.Errorf("expected errTooManyRooms, got %v", err)
+	}
+}
+
+// TestSweepRooms tests that idle rooms are pruned without waiting for a new room
+func TestSweepRooms(t *testing.T) {
+	roomTestQuestions(t)
+	m := &roomManager{rooms: make(map[string]*Room)}
+	room, err := m.create("astrology", 1, "198.51.100.7", time.Now().Add(-roomIdleTimeout-time.Minute))
+	if err != nil {
+		t.Fatalf("create() failed: %v", err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go sweepRooms(m, time.Millisecond, stop)
+
+	deadline := time.After(2 * time.Second)
+	for {
+		if _, ok := m.get(room.Code); !ok {
+			return
+		}
+		select {
+		case <-deadline:
+			t.Fatal("expected the sweeper to prune the idle room")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+// TestRoom_IsHost tests host token checks
+func TestRoom_IsHost(t *testing.T) {
+	room := &Room{hostToken: "secret-token"}
+	for token, want := range map[string]bool{"secret-token": true, "": false, "secret-toke": false, "secret-tokens": false, "Secret-token": false} {
+		if got := room.isHost(token); got != want {
+			t.Errorf("isHost(%q) = %v, want %v", token, got, want)
+		}
+	}
+}
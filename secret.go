@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+)
+
+// Server secret configuration
+const (
+	serverSecretEnv         = "QUIZ_SECRET"
+	defaultServerSecretFile = "server.key"
+	minServerSecretLength   = 32
+	serverKeySize           = 32
+)
+
+// serverKeys holds one key per purpose, each derived from the server secret so a MAC or
+// ciphertext made for one purpose is never accepted for another
+type serverKeys struct {
+	session []byte // account session cookies
+	share   []byte // share links and cards
+
+	stateMAC  []byte      // signed (version 1) quiz state tokens
+	stateAEAD cipher.AEAD // sealed (version 2) quiz state tokens
+}
+
+// keys are the keys in use. Until main loads the configured secret they derive from a random
+// one, so nothing is ever signed with a key that is known outside this process.
+var keys = deriveServerKeys(newServerSecret())
+
+// newServerSecret returns a fresh random secret, hex encoded
+func newServerSecret() []byte {
+	return []byte(randomToken(serverKeySize))
+}
+
+// deriveKey derives the key for one purpose from the server secret with HKDF-SHA256
+func deriveKey(secret []byte, purpose string) []byte {
+	key, err := hkdf.Key(sha256.New, secret, nil, "quiz|"+purpose, serverKeySize)
+	if err != nil {
+		// Only an oversized key length can fail
+		panic(err)
+	}
+	return key
+}
+
+// deriveServerKeys derives every purpose's key from the server secret
+func deriveServerKeys(secret []byte) serverKeys {
+	return serverKeys{
+		session: deriveKey(secret, "session"),
+		share:   deriveKey(secret, "share"),
+
+		stateMAC:  deriveKey(secret, "state-mac"),
+		stateAEAD: newStateTokenAEAD(deriveKey(secret, "state-aead")),
+	}
+}
+
+// loadServerSecret returns the secret from the QUIZ_SECRET environment variable or else from the
+// key file, generating the file with a random secret on first start. It refuses secrets that
+// are too short and key files other users can read.
+func loadServerSecret(path string) ([]byte, error) {
+	if secret := strings.TrimSpace(os.Getenv(serverSecretEnv)); secret != "" {
+		if len(secret) < minServerSecretLength {
+			return nil, fmt.Errorf("%s must be at least %d characters", serverSecretEnv, minServerSecretLength)
+		}
+		return []byte(secret), nil
+	}
+	if path == "" {
+		return nil, fmt.Errorf("no secret configured: set %s or -secret-file", serverSecretEnv)
+	}
+
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		secret := newServerSecret()
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create secret file: %w", err)
+		}
+		if _, err := file.Write(append(secret, '\n')); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to write secret file: %w", err)
+		}
+		if err := file.Close(); err != nil {
+			return nil, fmt.Errorf("failed to write secret file: %w", err)
+		}
+		log.Printf("Generated a new server secret in %s", path)
+		return secret, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret file: %w", err)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("secret file %s must not be accessible by other users (mode %v, want 0600)", path, info.Mode().Perm())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret file: %w", err)
+	}
+	secret := strings.TrimSpace(string(data))
+	if len(secret) < minServerSecretLength {
+		return nil, fmt.Errorf("secret in %s must be at least %d characters", path, minServerSecretLength)
+	}
+	return []byte(secret), nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadServerSecret(t *testing.T) {
+	dir := t.TempDir()
+	longSecret := strings.Repeat("s", minServerSecretLength)
+
+	t.Run("generates a private key file once", func(t *testing.T) {
+		t.Setenv(serverSecretEnv, "")
+		path := filepath.Join(dir, "generated.key")
+		first, err := loadServerSecret(path)
+		if err != nil {
+			t.Fatalf("loadServerSecret() failed: %v", err)
+		}
+		info, err := os.Stat(path)
+		if err != nil || info.Mode().Perm() != 0600 {
+			t.Fatalf("expected a 0600 key file, got %v, %v", info, err)
+		}
+		second, err := loadServerSecret(path)
+		if err != nil || !bytes.Equal(first, second) || len(first) < minServerSecretLength {
+			t.Errorf("expected the same secret on restart, got %q and %q (%v)", first, second, err)
+		}
+	})
+
+	t.Run("environment overrides the file", func(t *testing.T) {
+		t.Setenv(serverSecretEnv, longSecret)
+		secret, err := loadServerSecret(filepath.Join(dir, "unused.key"))
+		if err != nil || string(secret) != longSecret {
+			t.Errorf("expected the environment secret, got %q (%v)", secret, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "unused.key")); !os.IsNotExist(err) {
+			t.Error("expected no key file when the environment sets the secret")
+		}
+	})
+
+	tests := []struct {
+		name    string
+		env     string
+		file    string
+		mode    os.FileMode
+		noPath  bool
+		wantErr string
+	}{
+		{name: "short environment secret", env: "short", wantErr: "at least"},
+		{name: "short file secret", file: "short\n", mode: 0600, wantErr: "at least"},
+		{name: "readable by others", file: longSecret, mode: 0644, wantErr: "other users"},
+		{name: "nowhere to keep it", noPath: true, wantErr: "no secret configured"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(serverSecretEnv, tt.env)
+			path := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "-")+".key")
+			if tt.file != "" {
+				if err := os.WriteFile(path, []byte(tt.file), tt.mode); err != nil {
+					t.Fatal(err)
+				}
+				os.Chmod(path, tt.mode)
+			}
+			if tt.noPath {
+				path = ""
+			}
+			if _, err := loadServerSecret(path); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestDeriveServerKeys tests that keys differ per secret and are stable for one secret
+func TestDeriveServerKeys(t *testing.T) {
+	a := deriveServerKeys([]byte(strings.Repeat("a", minServerSecretLength)))
+	b := deriveServerKeys([]byte(strings.Repeat("b", minServerSecretLength)))
+	again := deriveServerKeys([]byte(strings.Repeat("a", minServerSecretLength)))
+
+	if bytes.Equal(a.session, b.session) || !bytes.Equal(a.session, again.session) {
+		t.Error("expected keys to depend only on the secret")
+	}
+	if bytes.Equal(deriveKey([]byte("secret"), "session"), deriveKey([]byte("secret"), "share")) {
+		t.Error("expected each purpose to get its own key")
+	}
+}
+
+// TestSessionCookie_ForgedWithPublicKey tests that a cookie signed with the old built-in
+// constant no longer logs anyone in
+func TestSessionCookie_ForgedWithPublicKey(t *testing.T) {
+	setupAccountsTest(t)
+	if _, err := createAccount("admin", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	setAdmins("admin")
+	defer setAdmins("")
+
+	expires := time.Now().Add(time.Hour).Unix()
+	mac := hmac.New(sha256.New, []byte("astrology-quiz-secret-key-change-in-production"))
+	fmt.Fprintf(mac, "session|%s|%d|%d", "admin", expires, 0)
+	forged := &http.Cookie{Name: sessionCookieName, Value: fmt.Sprintf("admin.%d.%s", expires, hex.EncodeToString(mac.Sum(nil)))}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/funnel", nil)
+	req.AddCookie(forged)
+	w := httptest.NewRecorder()
+	setupRoutes().ServeHTTP(w, req)
+	if w.Code == http.StatusOK {
+		t.Error("expected a forged session cookie to be rejected")
+	}
+	if _, ok := currentAccount(req); ok {
+		t.Error("expected no account for a forged session cookie")
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Content Security Policy modes for -csp
+const (
+	cspEnforce    = "enforce"
+	cspReportOnly = "report-only"
+	cspOff        = "off"
+)
+
+// Frame options for -frame-options
+const (
+	frameDeny       = "deny"
+	frameSameOrigin = "sameorigin"
+	frameOff        = "off"
+)
+
+// cspNonceBytes is the size of the random nonce each response's inline scripts and styles carry
+const cspNonceBytes = 16
+
+// referrerPolicies are the values browsers accept for Referrer-Policy
+var referrerPolicies = []string{
+	"no-referrer", "no-referrer-when-downgrade", "origin", "origin-when-cross-origin",
+	"same-origin", "strict-origin", "strict-origin-when-cross-origin", "unsafe-url",
+}
+
+// SecurityConfig controls the headers securityHeaders adds to every response
+type SecurityConfig struct {
+	CSP            string        // cspEnforce, cspReportOnly or cspOff
+	CSPReportURI   string        // where browsers report policy violations (empty for none)
+	FrameOptions   string        // frameDeny, frameSameOrigin or frameOff
+	ReferrerPolicy string        // Referrer-Policy value (empty omits the header)
+	HSTSMaxAge     time.Duration // Strict-Transport-Security max-age (0 omits the header)
+	HSTSSubdomains bool          // extend Strict-Transport-Security to subdomains
+	AssetOrigins   []string      // other origins theme images, stylesheets and fonts load from
+}
+
+// defaultSecurityConfig returns the headers used unless a deployment configures otherwise
+func defaultSecurityConfig() SecurityConfig {
+	return SecurityConfig{
+		CSP:            cspEnforce,
+		FrameOptions:   frameDeny,
+		ReferrerPolicy: "strict-origin-when-cross-origin",
+	}
+}
+
+// validate reports a setting browsers would not understand
+func (c SecurityConfig) validate() error {
+	switch c.CSP {
+	case cspEnforce, cspReportOnly, cspOff:
+	default:
+		return fmt.Errorf("unknown CSP mode %q (want %s, %s or %s)", c.CSP, cspEnforce, cspReportOnly, cspOff)
+	}
+	if strings.ContainsAny(c.CSPReportURI, "; ,\t") {
+		return fmt.Errorf("invalid CSP report URI %q", c.CSPReportURI)
+	}
+	switch c.FrameOptions {
+	case frameDeny, frameSameOrigin, frameOff:
+	default:
+		return fmt.Errorf("unknown frame option %q (want %s, %s or %s)", c.FrameOptions, frameDeny, frameSameOrigin, frameOff)
+	}
+	if c.ReferrerPolicy != "" && !slices.Contains(referrerPolicies, c.ReferrerPolicy) {
+		return fmt.Errorf("unknown referrer policy %q", c.ReferrerPolicy)
+	}
+	if c.HSTSMaxAge < 0 {
+		return fmt.Errorf("negative HSTS max-age %v", c.HSTSMaxAge)
+	}
+	for _, origin := range c.AssetOrigins {
+		if origin == "" || strings.ContainsAny(origin, "; ,\t'\"") {
+			return fmt.Errorf("invalid asset origin %q", origin)
+		}
+	}
+	return nil
+}
+
+// contentSecurityPolicy returns the policy for one response. Inline scripts and styles run only
+// if they carry the nonce, and inline event handlers and style attributes never do. Images,
+// stylesheets and fonts may also come from the configured asset origins.
+func (c SecurityConfig) contentSecurityPolicy(nonce string) string {
+	assets := strings.Join(append([]string{"'self'"}, c.AssetOrigins...), " ")
+	directives := []string{
+		"default-src 'self'",
+		"script-src 'nonce-" + nonce + "'",
+		"style-src " + assets + " 'nonce-" + nonce + "'",
+		"object-src 'none'",
+		"base-uri 'none'",
+		"form-action 'self'",
+	}
+	if len(c.AssetOrigins) > 0 {
+		directives = append(directives, "img-src "+assets, "font-src "+assets)
+	}
+	// Browsers ignore frame-ancestors in a report-only policy, so X-Frame-Options covers that mode
+	if c.CSP == cspEnforce {
+		switch c.FrameOptions {
+		case frameDeny:
+			directives = append(directives, "frame-ancestors 'none'")
+		case frameSameOrigin:
+			directives = append(directives, "frame-ancestors 'self'")
+		}
+	}
+	if c.CSPReportURI != "" {
+		directives = append(directives, "report-uri "+c.CSPReportURI)
+	}
+	return strings.Join(directives, "; ")
+}
+
+// securityHeaders adds the configured security headers to every response, with a fresh CSP
+// nonce per request that rendered pages put on their inline scripts and styles
+func securityHeaders(config SecurityConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		if config.ReferrerPolicy != "" {
+			h.Set("Referrer-Policy", config.ReferrerPolicy)
+		}
+		switch config.FrameOptions {
+		case frameDeny:
+			h.Set("X-Frame-Options", "DENY")
+		case frameSameOrigin:
+			h.Set("X-Frame-Options", "SAMEORIGIN")
+		}
+		switch config.CSP {
+		case cspEnforce:
+			h.Set("Content-Security-Policy", config.contentSecurityPolicy(randomToken(cspNonceBytes)))
+		case cspReportOnly:
+			h.Set("Content-Security-Policy-Report-Only", config.contentSecurityPolicy(randomToken(cspNonceBytes)))
+		}
+		if config.HSTSMaxAge > 0 {
+			hsts := "max-age=" + strconv.FormatInt(int64(config.HSTSMaxAge/time.Second), 10)
+			if config.HSTSSubdomains {
+				hsts += "; includeSubDomains"
+			}
+			h.Set("Strict-Transport-Security", hsts)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cspNonce returns the nonce the response's policy allows, or "" when there is no policy.
+// Pages read it back from the header so they always carry exactly what the browser will check.
+func cspNonce(w http.ResponseWriter) string {
+	for _, name := range []string{"Content-Security-Policy", "Content-Security-Policy-Report-Only"} {
+		for _, directive := range strings.Split(w.Header().Get(name), ";") {
+			for _, source := range strings.Fields(directive) {
+				if nonce, ok := strings.CutPrefix(source, "'nonce-"); ok {
+					return strings.TrimSuffix(nonce, "'")
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// withDirective returns policy with the named directive replaced by directive, or directive
+// alone when there is no policy
+func withDirective(policy, directive string) string {
+	name, _, _ := strings.Cut(directive, " ")
+	var directives []string
+	for _, d := range strings.Split(policy, ";") {
+		d = strings.TrimSpace(d)
+		if fields := strings.Fields(d); len(fields) == 0 || strings.EqualFold(fields[0], name) {
+			continue
+		}
+		directives = append(directives, d)
+	}
+	return strings.Join(append(directives, directive), "; ")
+}
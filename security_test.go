@@ -0,0 +1,222 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	// inlineTag matches inline script and style tags with their attributes
+	inlineTag = regexp.MustCompile(`<(script|style)\b([^>]*)>`)
+	// inlineAttribute matches event handler and style attributes, which no nonce can allow
+	inlineAttribute = regexp.MustCompile(`<[^>]*\s(on[a-z]+|style)\s*=`)
+)
+
+func TestSecurityConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(c *SecurityConfig)
+		wantErr bool
+	}{
+		{"defaults", func(c *SecurityConfig) {}, false},
+		{"report only with a report URI", func(c *SecurityConfig) { c.CSP, c.CSPReportURI = cspReportOnly, "https://reports.example/csp" }, false},
+		{"everything off", func(c *SecurityConfig) { c.CSP, c.FrameOptions, c.ReferrerPolicy = cspOff, frameOff, "" }, false},
+		{"hsts", func(c *SecurityConfig) { c.HSTSMaxAge, c.HSTSSubdomains = 365*24*time.Hour, true }, false},
+		{"unknown CSP mode", func(c *SecurityConfig) { c.CSP = "strict" }, true},
+		{"report URI injecting a directive", func(c *SecurityConfig) { c.CSPReportURI = "/csp; script-src *" }, true},
+		{"unknown frame option", func(c *SecurityConfig) { c.FrameOptions = "allow-from" }, true},
+		{"unknown referrer policy", func(c *SecurityConfig) { c.ReferrerPolicy = "never" }, true},
+		{"negative hsts", func(c *SecurityConfig) { c.HSTSMaxAge = -time.Second }, true},
+		{"asset origins", func(c *SecurityConfig) { c.AssetOrigins = []string{"https://cdn.example"} }, false},
+		{"asset origin injecting a directive", func(c *SecurityConfig) { c.AssetOrigins = []string{"https://cdn.example; script-src *"} }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := defaultSecurityConfig()
+			tt.change(&config)
+			if err := config.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestSecurityHeaders_AllRoutes tests that every route gets the headers, and that every HTML page
+// carries the response's nonce on its inline scripts and styles and has no inline attributes
+func TestSecurityHeaders_AllRoutes(t *testing.T) {
+	setupAccountsTest(t)
+	practiceQuestions()
+	handler := securityHeaders(defaultSecurityConfig(), setupRoutes())
+
+	// The event streams stay open and are covered by the same middleware
+	routes := []string{
+		"/", "/health", "/missing",
+		"/quiz?type=astrology", "/quiz/play", "/quiz/results", "/quiz/leaderboard",
+		"/leaderboard", "/leaderboard?window=day", "/leaderboard.json", "/leaderboard.csv", "/leaderboard.atom",
+		"/share/bogus", "/share/bogus/card.svg",
+		"/signup", "/login", "/logout", "/profile", "/study",
+		"/admin/questions", "/admin/questions.csv", "/admin/funnel",
+		"/rooms", "/rooms/join", "/rooms/NOPE",
+		"/embed/astrology", "/embed/astrology/play", "/embed/astrology/results", "/embed/palmistry",
+	}
+
+	nonces := make(map[string]bool)
+	for _, route := range routes {
+		t.Run(route, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, route, nil))
+			h := w.Header()
+
+			if h.Get("X-Content-Type-Options") != "nosniff" || h.Get("Referrer-Policy") != "strict-origin-when-cross-origin" {
+				t.Errorf("missing headers: %v", h)
+			}
+			policy := h.Get("Content-Security-Policy")
+			nonce := cspNonce(w)
+			if !strings.Contains(policy, "script-src 'nonce-"+nonce+"'") || len(nonce) != 2*cspNonceBytes {
+				t.Fatalf("expected a nonce-based policy, got %q", policy)
+			}
+			if nonces[nonce] {
+				t.Errorf("nonce %s was reused", nonce)
+			}
+			nonces[nonce] = true
+
+			// Only embed pages may be framed, and only by the allowlist
+			if strings.HasPrefix(route, "/embed/") && w.Code == http.StatusOK {
+				if h.Get("X-Frame-Options") != "" || !strings.Contains(policy, embedFrameAncestors()) {
+					t.Errorf("expected the embed allowlist, got X-Frame-Options %q and policy %q", h.Get("X-Frame-Options"), policy)
+				}
+			} else if h.Get("X-Frame-Options") != "DENY" || !strings.Contains(policy, "frame-ancestors 'none'") {
+				t.Errorf("expected framing to be denied, got X-Frame-Options %q and policy %q", h.Get("X-Frame-Options"), policy)
+			}
+
+			if !strings.HasPrefix(h.Get("Content-Type"), "text/html") {
+				return
+			}
+			body := w.Body.String()
+			for _, tag := range inlineTag.FindAllStringSubmatch(body, -1) {
+				if !strings.Contains(tag[2], `nonce="`+nonce+`"`) {
+					t.Errorf("inline %s without the response nonce: %s", tag[1], tag[0])
+				}
+			}
+			if attr := inlineAttribute.FindString(body); attr != "" {
+				t.Errorf("inline attribute the policy blocks: %s", attr)
+			}
+		})
+	}
+}
+
+func TestSecurityHeaders_Config(t *testing.T) {
+	setupAccountsTest(t)
+	practiceQuestions()
+
+	tests := []struct {
+		name       string
+		change     func(c *SecurityConfig)
+		wantHeader map[string]string // "" means absent
+		wantPolicy string            // a fragment of the enforced or report-only policy
+	}{
+		{
+			name:       "report only keeps X-Frame-Options for framing",
+			change:     func(c *SecurityConfig) { c.CSP, c.CSPReportURI = cspReportOnly, "/csp-reports" },
+			wantHeader: map[string]string{"Content-Security-Policy": "", "X-Frame-Options": "DENY"},
+			wantPolicy: "form-action 'self'; report-uri /csp-reports",
+		},
+		{
+			name:       "same origin framing",
+			change:     func(c *SecurityConfig) { c.FrameOptions = frameSameOrigin },
+			wantHeader: map[string]string{"X-Frame-Options": "SAMEORIGIN"},
+			wantPolicy: "frame-ancestors 'self'",
+		},
+		{
+			name:   "everything off",
+			change: func(c *SecurityConfig) { c.CSP, c.FrameOptions, c.ReferrerPolicy = cspOff, frameOff, "" },
+			wantHeader: map[string]string{
+				"Content-Security-Policy": "", "Content-Security-Policy-Report-Only": "", "X-Frame-Options": "",
+				"Referrer-Policy": "", "Strict-Transport-Security": "", "X-Content-Type-Options": "nosniff",
+			},
+		},
+		{
+			name:       "hsts",
+			change:     func(c *SecurityConfig) { c.HSTSMaxAge, c.HSTSSubdomains = 180*24*time.Hour, true },
+			wantHeader: map[string]string{"Strict-Transport-Security": "max-age=15552000; includeSubDomains"},
+		},
+		{
+			name:       "theme asset origins",
+			change:     func(c *SecurityConfig) { c.AssetOrigins = []string{"https://cdn.example", "https://fonts.example"} },
+			wantPolicy: "style-src 'self' https://cdn.example https://fonts.example 'nonce-",
+		},
+		{
+			name:       "theme asset images and fonts",
+			change:     func(c *SecurityConfig) { c.AssetOrigins = []string{"https://cdn.example"} },
+			wantPolicy: "img-src 'self' https://cdn.example; font-src 'self' https://cdn.example",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := defaultSecurityConfig()
+			tt.change(&config)
+			w := httptest.NewRecorder()
+			securityHeaders(config, setupRoutes()).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/quiz?type=astrology", nil))
+
+			for name, want := range tt.wantHeader {
+				if got := w.Header().Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			policy := w.Header().Get("Content-Security-Policy") + w.Header().Get("Content-Security-Policy-Report-Only")
+			if !strings.Contains(policy, tt.wantPolicy) {
+				t.Errorf("policy %q does not contain %q", policy, tt.wantPolicy)
+			}
+			if nonce := cspNonce(w); !strings.Contains(w.Body.String(), `<script nonce="`+nonce+`">`) {
+				t.Errorf("expected the page's script to carry nonce %q", nonce)
+			}
+		})
+	}
+}
+
+// TestSecurityHeaders_Embed tests that embed pages keep the site policy but take the embed allowlist
+func TestSecurityHeaders_Embed(t *testing.T) {
+	setupAccountsTest(t)
+	practiceQuestions()
+	defer func() { embedOrigins = nil }()
+	if err := setEmbedOrigins("https://blog.example"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		change     func(c *SecurityConfig)
+		wantPolicy string
+	}{
+		{"enforced", func(c *SecurityConfig) {}, "default-src 'self'; script-src 'nonce-N'; style-src 'self' 'nonce-N'; object-src 'none'; base-uri 'none'; form-action 'self'; frame-ancestors 'self' https://blog.example"},
+		{"report only", func(c *SecurityConfig) { c.CSP = cspReportOnly }, "frame-ancestors 'self' https://blog.example"},
+		{"off", func(c *SecurityConfig) { c.CSP = cspOff }, "frame-ancestors 'self' https://blog.example"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := defaultSecurityConfig()
+			tt.change(&config)
+			w := httptest.NewRecorder()
+			securityHeaders(config, setupRoutes()).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/embed/astrology", nil))
+
+			if w.Code != http.StatusOK || w.Header().Get("X-Frame-Options") != "" {
+				t.Fatalf("expected a frameable page, got %d with X-Frame-Options %q", w.Code, w.Header().Get("X-Frame-Options"))
+			}
+			nonce := cspNonce(w)
+			want := strings.ReplaceAll(tt.wantPolicy, "'nonce-N'", "'nonce-"+nonce+"'")
+			if got := w.Header().Get("Content-Security-Policy"); got != want {
+				t.Errorf("Content-Security-Policy = %q, want %q", got, want)
+			}
+			if !strings.Contains(w.Body.String(), `<script nonce="`+nonce+`">`) {
+				t.Error("expected the embed's timer script to carry the nonce")
+			}
+		})
+	}
+}
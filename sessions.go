@@ -0,0 +1,370 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QuizSessionStore keeps quiz state on the server, keyed by the opaque ID in the player's session cookie.
+// Implementations must be safe for concurrent use.
+type QuizSessionStore interface {
+	// Load returns the session's state, or false if it does not exist or expired before now
+	Load(id string, now time.Time) (QuizState, bool, error)
+	// Save creates or replaces a session, which stays valid until expires
+	Save(id string, state QuizState, expires time.Time) error
+	// Delete removes a session; deleting a missing session is not an error
+	Delete(id string) error
+	// Cleanup removes every session that expired before now and reports how many were removed
+	Cleanup(now time.Time) (int, error)
+}
+
+// Quiz session configuration
+const (
+	quizSessionCookieName = "quiz_session"
+	quizRunCookieName     = "quiz_run" // holds the latest state token when state is client-carried
+	quizSessionIDBytes    = 32
+	quizSessionLifetime   = 2 * time.Hour // refreshed on every answer, so only abandoned runs expire; also bounds quiz_run
+	quizSessionSweepEvery = 10 * time.Minute
+)
+
+// quizSessionIDPattern matches IDs from newQuizSessionID, which keeps cookie values out of file paths
+var quizSessionIDPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// quizSessions is the server-side state store; nil means state travels in client-carried tokens
+var quizSessions QuizSessionStore
+
+// newQuizSessionStore builds the store selected by the -quiz-sessions flag
+func newQuizSessionStore(kind, dir string) (QuizSessionStore, error) {
+	switch kind {
+	case "", "off":
+		return nil, nil
+	case "memory":
+		return newMemorySessionStore(), nil
+	case "file":
+		return newFileSessionStore(dir)
+	default:
+		return nil, fmt.Errorf("unknown quiz session store %q (want off, memory or file)", kind)
+	}
+}
+
+// newQuizSessionID returns a random, unguessable session ID
+func newQuizSessionID() string {
+	return randomToken(quizSessionIDBytes)
+}
+
+// storeQuizState saves the state for the next request and returns the token to embed in the page.
+// Without sessions the token is also kept in the quiz_run cookie so the run can be resumed.
+// In session mode the state is kept server-side and the token is empty; starting a new quiz
+// rotates the session ID so an old cookie can never reach a new run.
+func storeQuizState(w http.ResponseWriter, r *http.Request, state QuizState, newRun bool) (string, error) {
+	if quizSessions == nil {
+		token, err := encodeStateToken(state)
+		if err != nil {
+			return "", err
+		}
+		setQuizCookie(w, quizRunCookieName, token, time.Now().Add(quizSessionLifetime))
+		return token, nil
+	}
+
+	id := cookieValue(r, quizSessionCookieName)
+	if newRun || !quizSessionIDPattern.MatchString(id) {
+		if quizSessionIDPattern.MatchString(id) {
+			if err := quizSessions.Delete(id); err != nil {
+				log.Printf("Error deleting quiz session: %v", err)
+			}
+		}
+		id = newQuizSessionID()
+	}
+
+	expires := time.Now().Add(quizSessionLifetime)
+	if err := quizSessions.Save(id, state, expires); err != nil {
+		return "", fmt.Errorf("failed to save quiz session: %w", err)
+	}
+	setQuizCookie(w, quizSessionCookieName, id, expires)
+	return "", nil
+}
+
+// setQuizCookie sets a quiz state cookie; an empty value deletes it
+func setQuizCookie(w http.ResponseWriter, name, value string, expires time.Time) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if value == "" {
+		cookie.Expires = time.Time{}
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+}
+
+// loadQuizState returns the verified quiz state for a request, from the session store in
+// session mode and from the submitted token otherwise
+func loadQuizState(r *http.Request) (*QuizState, bool) {
+	if quizSessions == nil {
+		return quizStateFromRequest(r)
+	}
+
+	id := cookieValue(r, quizSessionCookieName)
+	if !quizSessionIDPattern.MatchString(id) {
+		return nil, false
+	}
+	state, ok, err := quizSessions.Load(id, time.Now())
+	if err != nil {
+		log.Printf("Error loading quiz session: %v", err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+	return &state, true
+}
+
+// loadQuizRun returns the run this browser is playing: the session in session mode, otherwise
+// the token in the quiz_run cookie. Unlike a token posted with a form, the cookie always holds
+// the latest state, so an old page cannot rewind the run. Forms post their run's ID as "run":
+// when the cookie holds another run (one started in another tab) the form's own state is used,
+// and a session holding another run is refused, so an answer is never graded against another
+// run's question. Pages served before the cookie existed fall back to their submitted state.
+func loadQuizRun(r *http.Request) (*QuizState, bool) {
+	posted := r.FormValue("run")
+	if quizSessions != nil {
+		state, ok := loadQuizState(r)
+		if ok && posted != "" && state.RunID != posted {
+			return nil, false
+		}
+		return state, ok
+	}
+	if token := cookieValue(r, quizRunCookieName); token != "" {
+		if state, ok := decodeStateToken(token); ok && (posted == "" || state.RunID == posted) {
+			return state, true
+		}
+	}
+	state, ok := quizStateFromRequest(r)
+	if ok && posted != "" && state.RunID != posted {
+		return nil, false
+	}
+	return state, ok
+}
+
+// endQuizState discards a finished run so it cannot be resumed. The step ledger is what stops
+// a run's results token from being submitted twice.
+func endQuizState(w http.ResponseWriter, r *http.Request) {
+	if quizSessions == nil {
+		setQuizCookie(w, quizRunCookieName, "", time.Time{})
+		return
+	}
+	if id := cookieValue(r, quizSessionCookieName); quizSessionIDPattern.MatchString(id) {
+		if err := quizSessions.Delete(id); err != nil {
+			log.Printf("Error deleting quiz session: %v", err)
+		}
+	}
+	setQuizCookie(w, quizSessionCookieName, "", time.Time{})
+}
+
+// sweepQuizSessions periodically removes abandoned runs until stop is closed
+func sweepQuizSessions(store QuizSessionStore, every time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			removed, err := store.Cleanup(time.Now())
+			if err != nil {
+				log.Printf("Error cleaning up quiz sessions: %v", err)
+			} else if removed > 0 {
+				log.Printf("Removed %d expired quiz sessions", removed)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// storedQuizSession is a session with its expiry
+type storedQuizSession struct {
+	State   QuizState `json:"state"`
+	Expires time.Time `json:"expires"`
+}
+
+// memorySessionStore keeps sessions in memory; they are lost on restart
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]storedQuizSession
+}
+
+// newMemorySessionStore creates an empty in-memory store
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{sessions: make(map[string]storedQuizSession)}
+}
+
+// Load returns the session's state unless it is missing or expired
+func (s *memorySessionStore) Load(id string, now time.Time) (QuizState, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || now.After(session.Expires) {
+		return QuizState{}, false, nil
+	}
+	return session.State, true, nil
+}
+
+// Save stores the state in memory until it expires
+func (s *memorySessionStore) Save(id string, state QuizState, expires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[id] = storedQuizSession{State: state, Expires: expires}
+	return nil
+}
+
+// Delete forgets a session
+func (s *memorySessionStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, id)
+	return nil
+}
+
+// Cleanup removes expired sessions and reports how many there were
+func (s *memorySessionStore) Cleanup(now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for id, session := range s.sessions {
+		if now.After(session.Expires) {
+			delete(s.sessions, id)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// fileSessionStore keeps one JSON file per session in a directory, so runs survive restarts
+type fileSessionStore struct {
+	dir string
+}
+
+// newFileSessionStore creates the session directory if needed
+func newFileSessionStore(dir string) (*fileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create quiz session directory: %w", err)
+	}
+	return &fileSessionStore{dir: dir}, nil
+}
+
+// path returns the file for a session, rejecting IDs that are not ours
+func (s *fileSessionStore) path(id string) (string, error) {
+	if !quizSessionIDPattern.MatchString(id) {
+		return "", fmt.Errorf("invalid quiz session ID")
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// Load reads the session's file unless it is missing or expired
+func (s *fileSessionStore) Load(id string, now time.Time) (QuizState, bool, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return QuizState{}, false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return QuizState{}, false, nil
+		}
+		return QuizState{}, false, fmt.Errorf("failed to read quiz session: %w", err)
+	}
+
+	var session storedQuizSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return QuizState{}, false, fmt.Errorf("failed to parse quiz session: %w", err)
+	}
+	if now.After(session.Expires) {
+		return QuizState{}, false, nil
+	}
+	return session.State, true, nil
+}
+
+// Save writes to a temporary file and renames it so a crash never leaves a half-written session
+func (s *fileSessionStore) Save(id string, state QuizState, expires time.Time) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(storedQuizSession{State: state, Expires: expires})
+	if err != nil {
+		return fmt.Errorf("failed to marshal quiz session: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, id+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create quiz session file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write quiz session file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write quiz session file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save quiz session file: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a session's file
+func (s *fileSessionStore) Delete(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete quiz session: %w", err)
+	}
+	return nil
+}
+
+// Cleanup removes expired session files and reports how many there were
+func (s *fileSessionStore) Cleanup(now time.Time) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list quiz sessions: %w", err)
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !quizSessionIDPattern.MatchString(id) {
+			continue
+		}
+		_, valid, err := s.Load(id, now)
+		if err != nil || valid {
+			// Unreadable sessions are left for an operator to inspect rather than silently deleted
+			continue
+		}
+		if err := s.Delete(id); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
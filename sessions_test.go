@@ -0,0 +1,334 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useQuizSessions switches the handlers to server-side state for one test
+func useQuizSessions(t *testing.T, store QuizSessionStore) {
+	t.Helper()
+	original := quizSessions
+	quizSessions = store
+	t.Cleanup(func() { quizSessions = original })
+}
+
+func TestQuizSessionStores(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	state := QuizState{QuestionIDs: []string{"q1", "q2"}, CurrentIndex: 1, Score: 1, QuizType: "tarot"}
+
+	stores := map[string]func(t *testing.T) QuizSessionStore{
+		"memory": func(t *testing.T) QuizSessionStore { return newMemorySessionStore() },
+		"file": func(t *testing.T) QuizSessionStore {
+			store, err := newFileSessionStore(filepath.Join(t.TempDir(), "sessions"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return store
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			live, expired := newQuizSessionID(), newQuizSessionID()
+
+			if err := store.Save(live, state, now.Add(time.Hour)); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+			if err := store.Save(expired, state, now.Add(-time.Minute)); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+
+			if got, ok, err := store.Load(live, now); err != nil || !ok || !reflect.DeepEqual(got, state) {
+				t.Errorf("Load(live) = %+v, %v, %v", got, ok, err)
+			}
+			if _, ok, err := store.Load(expired, now); err != nil || ok {
+				t.Errorf("Load(expired) = %v, %v; want not found", ok, err)
+			}
+			if _, ok, err := store.Load(newQuizSessionID(), now); err != nil || ok {
+				t.Errorf("Load(unknown) = %v, %v; want not found", ok, err)
+			}
+
+			// Saving again replaces the state
+			advanced := state
+			advanced.CurrentIndex = 2
+			store.Save(live, advanced, now.Add(time.Hour))
+			if got, _, _ := store.Load(live, now); got.CurrentIndex != 2 {
+				t.Errorf("Save should replace the state, got index %d", got.CurrentIndex)
+			}
+
+			if removed, err := store.Cleanup(now); err != nil || removed != 1 {
+				t.Errorf("Cleanup() = %d, %v; want 1 removed", removed, err)
+			}
+			if _, ok, _ := store.Load(live, now); !ok {
+				t.Error("Cleanup must keep live sessions")
+			}
+
+			if err := store.Delete(live); err != nil {
+				t.Errorf("Delete failed: %v", err)
+			}
+			if err := store.Delete(live); err != nil {
+				t.Errorf("deleting a missing session should not fail: %v", err)
+			}
+			if _, ok, _ := store.Load(live, now); ok {
+				t.Error("deleted session should not load")
+			}
+		})
+	}
+}
+
+func TestFileSessionStore_Persistence(t *testing.T) {
+	dir := t.TempDir()
+	id := newQuizSessionID()
+	state := QuizState{QuestionIDs: []string{"q1"}, QuizType: "astrology"}
+
+	first, _ := newFileSessionStore(dir)
+	if err := first.Save(id, state, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// A new store over the same directory, as after a restart, sees the session
+	second, _ := newFileSessionStore(dir)
+	if _, ok, err := second.Load(id, time.Now()); err != nil || !ok {
+		t.Errorf("session did not survive a restart: %v, %v", ok, err)
+	}
+
+	// Cookie values never become file paths unless they look like our IDs
+	os.WriteFile(filepath.Join(dir, "secret.json"), []byte(`{}`), 0600)
+	for _, bad := range []string{"../secret", "secret", strings.ToUpper(id)} {
+		if _, ok, err := second.Load(bad, time.Now()); ok || err != nil {
+			t.Errorf("Load(%q) = %v, %v; want not found", bad, ok, err)
+		}
+		if err := second.Save(bad, state, time.Now()); err == nil {
+			t.Errorf("Save(%q) should be rejected", bad)
+		}
+	}
+
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".tmp") {
+			t.Errorf("temporary file %s left behind", entry.Name())
+		}
+	}
+}
+
+func TestNewQuizSessionStore(t *testing.T) {
+	tests := []struct {
+		kind    string
+		wantNil bool
+		wantErr bool
+	}{
+		{"", true, false},
+		{"off", true, false},
+		{"memory", false, false},
+		{"file", false, false},
+		{"redis", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			store, err := newQuizSessionStore(tt.kind, t.TempDir())
+			if (err != nil) != tt.wantErr || (store == nil) != tt.wantNil {
+				t.Errorf("newQuizSessionStore(%q) = %v, %v", tt.kind, store, err)
+			}
+		})
+	}
+}
+
+// TestQuizFlow_Sessions plays a quiz in session mode: the state never reaches the page or URL
+func TestQuizFlow_Sessions(t *testing.T) {
+	setupAccountsTest(t)
+	store := newMemorySessionStore()
+	useQuizSessions(t, store)
+	questionSets = map[string][]Question{
+		"astrology": {
+			{ID: "q1", Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+			{ID: "q2", Question: "Q2?", Choices: []string{"A", "B"}, AnswerIndex: 1},
+		},
+	}
+
+	// send performs a request with the current session cookie and keeps any cookie it sets
+	var cookie *http.Cookie
+	send := func(handler http.HandlerFunc, method, target string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		for _, c := range w.Result().Cookies() {
+			if c.Name == quizSessionCookieName {
+				cookie = c
+			}
+		}
+		return w
+	}
+
+	w := send(quizGetHandler, http.MethodGet, "/quiz", nil)
+	if cookie == nil || !cookie.HttpOnly {
+		t.Fatal("starting a quiz should set an HttpOnly session cookie")
+	}
+	if !strings.Contains(w.Body.String(), `name="state" value=""`) {
+		t.Error("page should carry no state token in session mode")
+	}
+	firstID := cookie.Value
+
+	for i := 0; i < 2; i++ {
+		state, ok, _ := store.Load(cookie.Value, time.Now())
+		if !ok {
+			t.Fatalf("step %d: session missing", i)
+		}
+		question, _ := findQuestion(questionSets["astrology"], state.QuestionIDs[state.CurrentIndex])
+		w = send(quizPostHandler, http.MethodPost, "/quiz", url.Values{"answer": {strconv.Itoa(question.AnswerIndex)}})
+	}
+	if location := w.Header().Get("Location"); location != "/quiz/results" {
+		t.Fatalf("results redirect = %q, want /quiz/results with no state", location)
+	}
+
+	w = send(quizResultsGetHandler, http.MethodGet, "/quiz/results", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "2 / 2") {
+		t.Fatalf("results status %d, want a 2 / 2 score", w.Code)
+	}
+
+	// Submitting ends the session, so the same run cannot be posted twice
+	w = send(quizLeaderboardPostHandler, http.MethodPost, "/quiz/leaderboard", url.Values{"name": {"Ana"}})
+	if w.Code != http.StatusSeeOther || len(getLeaderboard()) != 1 {
+		t.Fatalf("first submission: status %d, %d entries", w.Code, len(getLeaderboard()))
+	}
+	cookie = &http.Cookie{Name: quizSessionCookieName, Value: firstID}
+	w = send(quizLeaderboardPostHandler, http.MethodPost, "/quiz/leaderboard", url.Values{"name": {"Ana"}})
+	if w.Header().Get("Location") != "/quiz" || len(getLeaderboard()) != 1 {
+		t.Errorf("replayed submission should restart the quiz, got %q with %d entries", w.Header().Get("Location"), len(getLeaderboard()))
+	}
+
+	// Forged and client-carried state are ignored in session mode
+	token, _ := encodeStateToken(QuizState{QuestionIDs: []string{"q1"}, CurrentIndex: 1, Score: 1, QuizType: "astrology"})
+	cookie = &http.Cookie{Name: quizSessionCookieName, Value: newQuizSessionID()}
+	w = send(quizResultsGetHandler, http.MethodGet, "/quiz/results?state="+token, nil)
+	if w.Header().Get("Location") != "/quiz" {
+		t.Errorf("unknown session should restart the quiz, got status %d", w.Code)
+	}
+}
+
+// TestQuizSessions_NewRunRotatesID tests that starting over issues a new session and drops the old one
+func TestQuizSessions_NewRunRotatesID(t *testing.T) {
+	store := newMemorySessionStore()
+	useQuizSessions(t, store)
+	questionSets = map[string][]Question{"astrology": {{ID: "q1", Question: "Q1?", Choices: []string{"A", "B"}}}}
+
+	oldID := newQuizSessionID()
+	store.Save(oldID, QuizState{QuestionIDs: []string{"q1"}, QuizType: "astrology"}, time.Now().Add(time.Hour))
+
+	req := httptest.NewRequest(http.MethodGet, "/quiz?new=1", nil)
+	req.AddCookie(&http.Cookie{Name: quizSessionCookieName, Value: oldID})
+	w := httptest.NewRecorder()
+	quizGetHandler(w, req)
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value == oldID {
+		t.Fatalf("expected a new session cookie, got %v", cookies)
+	}
+	if _, ok, _ := store.Load(oldID, time.Now()); ok {
+		t.Error("the previous run's session should be deleted")
+	}
+}
+
+// TestQuizResume tests that GET /quiz picks an unfinished run back up in both storage modes
+func TestQuizResume(t *testing.T) {
+	questionSets = map[string][]Question{
+		"astrology": {
+			{ID: "q1", Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+			{ID: "q2", Question: "Q2?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+		},
+		"tarot": {{ID: "t1", Question: "T1?", Choices: []string{"A", "B"}}},
+	}
+
+	for _, mode := range []string{"tokens", "sessions"} {
+		t.Run(mode, func(t *testing.T) {
+			if mode == "sessions" {
+				useQuizSessions(t, newMemorySessionStore())
+			}
+			mux := setupRoutes()
+			cookies := map[string]*http.Cookie{}
+			// send performs a request like a browser would, keeping the cookies between requests
+			send := func(method, target string, form url.Values) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				for _, c := range cookies {
+					req.AddCookie(c)
+				}
+				w := httptest.NewRecorder()
+				mux.ServeHTTP(w, req)
+				for _, c := range w.Result().Cookies() {
+					cookies[c.Name] = c
+				}
+				return w
+			}
+
+			send(http.MethodGet, "/quiz", nil)
+			w := send(http.MethodPost, "/quiz", url.Values{"step": {"0"}, "answer": {"0"}})
+			if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/quiz/play" {
+				t.Fatalf("answer should redirect to /quiz/play, got %d %q", w.Code, w.Header().Get("Location"))
+			}
+
+			// Refreshing the question page shows the same question without resubmitting
+			for i := 0; i < 2; i++ {
+				if body := send(http.MethodGet, "/quiz/play", nil).Body.String(); !strings.Contains(body, "Question 2 of 2") || !strings.Contains(body, "Score: 1") {
+					t.Fatalf("refresh %d: expected question 2 with score 1", i)
+				}
+			}
+
+			// Going back and submitting the first question's form again changes nothing
+			w = send(http.MethodPost, "/quiz", url.Values{"step": {"0"}, "answer": {"0"}})
+			if w.Header().Get("Location") != "/quiz/play" {
+				t.Fatalf("stale form should redirect to the current question, got %q", w.Header().Get("Location"))
+			}
+			if body := send(http.MethodGet, "/quiz/play", nil).Body.String(); !strings.Contains(body, "Question 2 of 2") || !strings.Contains(body, "Score: 1") {
+				t.Fatal("stale form must not advance the run or add to the score")
+			}
+
+			// Coming back to /quiz offers the unfinished run at its current question
+			body := send(http.MethodGet, "/quiz?type=astrology", nil).Body.String()
+			if !strings.Contains(body, "continuing your quiz") || !strings.Contains(body, "Question 2 of 2") {
+				t.Error("GET /quiz should resume the unfinished run")
+			}
+			if !strings.Contains(body, `href="/quiz?type=astrology&new=1"`) {
+				t.Error("resumed page should link to starting over")
+			}
+
+			// Another quiz type or an explicit restart begins a new run
+			if body := send(http.MethodGet, "/quiz?type=tarot", nil).Body.String(); !strings.Contains(body, "Question 1 of 1") || strings.Contains(body, "continuing your quiz") {
+				t.Error("a different quiz type should start a new run")
+			}
+			if body := send(http.MethodGet, "/quiz?type=tarot&new=1", nil).Body.String(); !strings.Contains(body, "Question 1 of 1") || strings.Contains(body, "continuing your quiz") {
+				t.Error("new=1 should start a new run")
+			}
+
+			// A finished run is not resumed; its page goes to the results
+			send(http.MethodPost, "/quiz", url.Values{"step": {"0"}, "answer": {"0"}})
+			if w := send(http.MethodGet, "/quiz/play", nil); w.Code != http.StatusSeeOther || !strings.HasPrefix(w.Header().Get("Location"), "/quiz/results") {
+				t.Errorf("finished run: /quiz/play = %d %q, want results redirect", w.Code, w.Header().Get("Location"))
+			}
+			if body := send(http.MethodGet, "/quiz?type=tarot", nil).Body.String(); strings.Contains(body, "continuing your quiz") {
+				t.Error("a finished run should not be resumed")
+			}
+		})
+	}
+
+	// Without any run, /quiz/play sends the player to start one
+	w := httptest.NewRecorder()
+	quizPlayHandler(w, httptest.NewRequest(http.MethodGet, "/quiz/play", nil))
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/quiz" {
+		t.Errorf("/quiz/play without a run = %d %q, want redirect to /quiz", w.Code, w.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ShareCard is the publicly shareable summary of a finished quiz
+type ShareCard struct {
+	QuizType string
+	Score    int
+	Total    int
+	When     time.Time // completion time, stored with second precision
+}
+
+// Share card configuration
+const (
+	shareSignatureSize = 16 // bytes of HMAC kept in the token; 128 bits is plenty to stop forgery
+	shareCardWidth     = 1200
+	shareCardHeight    = 630 // the Open Graph recommended 1.91:1 image size
+	shareCardMaxAge    = 365 * 24 * time.Hour
+)
+
+// errInvalidShareToken is returned for malformed, tampered or nonsensical share tokens
+var errInvalidShareToken = errors.New("invalid share token")
+
+// Percentage returns the card's score as a percentage of its total
+func (c ShareCard) Percentage() float64 {
+	if c.Total == 0 {
+		return 0
+	}
+	return float64(c.Score) / float64(c.Total) * 100.0
+}
+
+// newShareToken encodes a card as "payload.signature", both base64url without padding.
+// The payload is "quizType|score|total|unixSeconds", short enough for a readable URL.
+func newShareToken(card ShareCard) string {
+	payload := fmt.Sprintf("%s|%d|%d|%d", card.QuizType, card.Score, card.Total, card.When.Unix())
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(signSharePayload(payload))
+}
+
+// signSharePayload computes the truncated share MAC with the share key
+func signSharePayload(payload string) []byte {
+	mac := hmac.New(sha256.New, keys.share)
+	fmt.Fprintf(mac, "share|%s", payload)
+	return mac.Sum(nil)[:shareSignatureSize]
+}
+
+// parseShareToken verifies a share token and decodes its card
+func parseShareToken(token string) (ShareCard, error) {
+	encodedPayload, encodedSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return ShareCard{}, errInvalidShareToken
+	}
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return ShareCard{}, errInvalidShareToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return ShareCard{}, errInvalidShareToken
+	}
+	payload := string(payloadBytes)
+	if !hmac.Equal(sig, signSharePayload(payload)) {
+		return ShareCard{}, errInvalidShareToken
+	}
+
+	fields := strings.Split(payload, "|")
+	if len(fields) != 4 || fields[0] == "" {
+		return ShareCard{}, errInvalidShareToken
+	}
+	score, err1 := strconv.Atoi(fields[1])
+	total, err2 := strconv.Atoi(fields[2])
+	when, err3 := strconv.ParseInt(fields[3], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil || score < 0 || total <= 0 || score > total {
+		return ShareCard{}, errInvalidShareToken
+	}
+	return ShareCard{QuizType: fields[0], Score: score, Total: total, When: time.Unix(when, 0).UTC()}, nil
+}
+
+// shareCardFromRequest verifies the {token} path value, answering 404 when it is not a valid card
+func shareCardFromRequest(w http.ResponseWriter, r *http.Request) (ShareCard, bool) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return ShareCard{}, false
+	}
+	card, err := parseShareToken(r.PathValue("token"))
+	if err != nil {
+		http.NotFound(w, r)
+		return ShareCard{}, false
+	}
+	return card, true
+}
+
+// absoluteURL builds an absolute URL for path on the host the request was made to.
+// Open Graph scrapers require absolute image and page URLs.
+func absoluteURL(r *http.Request, path string) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + path
+}
+
+// SharePageData represents the data passed to the share.html template
+type SharePageData struct {
+	Theme       Theme
+	Card        ShareCard
+	Date        string
+	Description string
+	PageURL     string
+	ImageURL    string
+}
+
+// sharePageHandler handles GET /share/{token}, the public page for a shared result
+func sharePageHandler(w http.ResponseWriter, r *http.Request) {
+	card, ok := shareCardFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	lang := requestLanguage(w, r)
+	theme := themeFor(card.QuizType, lang)
+	token := r.PathValue("token")
+	renderPage(w, "share", lang, SharePageData{
+		Theme:       theme,
+		Card:        card,
+		Date:        card.When.Format("2006-01-02"),
+		Description: translate(lang, "share.description", card.Score, card.Total, card.Percentage(), theme.Title),
+		PageURL:     absoluteURL(r, "/share/"+token),
+		ImageURL:    absoluteURL(r, "/share/"+token+"/card.png"),
+	})
+}
+
+// shareCardSVGHandler handles GET /share/{token}/card.svg and draws the result card as SVG
+func shareCardSVGHandler(w http.ResponseWriter, r *http.Request) {
+	card, ok := shareCardFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	lang := requestLanguage(w, r)
+	theme := themeFor(card.QuizType, lang)
+	barWidth := int(card.Percentage() / 100 * 1000)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, shareCardWidth, shareCardHeight, shareCardWidth, shareCardHeight)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="%s"/>`, theme.BackgroundColor)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="24" fill="%s"/>`, theme.PrimaryColor)
+	fmt.Fprintf(&buf, `<text x="600" y="170" font-family="Arial, sans-serif" font-size="64" font-weight="bold" text-anchor="middle" fill="%s">%s</text>`, theme.PrimaryColor, html.EscapeString(theme.Title))
+	fmt.Fprintf(&buf, `<text x="600" y="340" font-family="Arial, sans-serif" font-size="150" font-weight="bold" text-anchor="middle" fill="#333333">%d / %d</text>`, card.Score, card.Total)
+	fmt.Fprintf(&buf, `<rect x="100" y="400" width="1000" height="40" rx="20" fill="#e0e0e0"/>`)
+	fmt.Fprintf(&buf, `<rect x="100" y="400" width="%d" height="40" rx="20" fill="%s"/>`, barWidth, theme.PrimaryColor)
+	fmt.Fprintf(&buf, `<text x="600" y="530" font-family="Arial, sans-serif" font-size="40" text-anchor="middle" fill="#666666">%s</text>`,
+		html.EscapeString(translate(lang, "share.card_footer", card.Percentage(), card.When.Format("2006-01-02"))))
+	buf.WriteString(`</svg>`)
+
+	writeShareImage(w, "image/svg+xml", buf.Bytes())
+}
+
+// shareCardPNGHandler handles GET /share/{token}/card.png, the Open Graph image.
+// Most link previews do not render SVG, so the PNG draws the score with a built-in pixel font.
+func shareCardPNGHandler(w http.ResponseWriter, r *http.Request) {
+	card, ok := shareCardFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	theme := themeFor(card.QuizType, defaultLanguage)
+	background := parseHexColor(theme.BackgroundColor)
+	primary := parseHexColor(theme.PrimaryColor)
+	text := color.RGBA{0x33, 0x33, 0x33, 0xff}
+	track := color.RGBA{0xe0, 0xe0, 0xe0, 0xff}
+
+	img := image.NewRGBA(image.Rect(0, 0, shareCardWidth, shareCardHeight))
+	fillRect(img, img.Bounds(), background)
+	fillRect(img, image.Rect(0, 0, shareCardWidth, 24), primary)
+
+	score := fmt.Sprintf("%d/%d", card.Score, card.Total)
+	drawPixelText(img, score, 180, 24, text)
+	drawPixelText(img, fmt.Sprintf("%.0f%%", card.Percentage()), 480, 10, primary)
+
+	fillRect(img, image.Rect(100, 400, 1100, 440), track)
+	fillRect(img, image.Rect(100, 400, 100+int(card.Percentage()/100*1000), 440), primary)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Error encoding share card: %v", err)
+		return
+	}
+	writeShareImage(w, "image/png", buf.Bytes())
+}
+
+// writeShareImage writes a card image; a token's card never changes, so it is cached for a long time
+func writeShareImage(w http.ResponseWriter, contentType string, data []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", int(shareCardMaxAge.Seconds())))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// parseHexColor converts a validated #rgb, #rrggbb or #rrggbbaa theme color
+func parseHexColor(s string) color.RGBA {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 8 {
+		return color.RGBA{0, 0, 0, 0xff}
+	}
+	return color.RGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}
+}
+
+// fillRect paints a solid rectangle
+func fillRect(img *image.RGBA, rect image.Rectangle, c color.RGBA) {
+	rect = rect.Intersect(img.Bounds())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+// pixelFont is a 5x7 bitmap font covering the characters a score needs.
+// Each row is 5 bits wide, most significant bit on the left.
+var pixelFont = map[rune][7]uint8{
+	'0': {0x0e, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0e},
+	'1': {0x04, 0x0c, 0x04, 0x04, 0x04, 0x04, 0x0e},
+	'2': {0x0e, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1f},
+	'3': {0x1f, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0e},
+	'4': {0x02, 0x06, 0x0a, 0x12, 0x1f, 0x02, 0x02},
+	'5': {0x1f, 0x10, 0x1e, 0x01, 0x01, 0x11, 0x0e},
+	'6': {0x06, 0x08, 0x10, 0x1e, 0x11, 0x11, 0x0e},
+	'7': {0x1f, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0e, 0x11, 0x11, 0x0e, 0x11, 0x11, 0x0e},
+	'9': {0x0e, 0x11, 0x11, 0x0f, 0x01, 0x02, 0x0c},
+	'/': {0x01, 0x01, 0x02, 0x04, 0x08, 0x10, 0x10},
+	'%': {0x18, 0x19, 0x02, 0x04, 0x08, 0x13, 0x03},
+}
+
+// drawPixelText draws s horizontally centered with its top at y, each font pixel scale px square
+func drawPixelText(img *image.RGBA, s string, y, scale int, c color.RGBA) {
+	const glyphWidth, glyphSpacing = 5, 1
+	width := (len(s)*(glyphWidth+glyphSpacing) - glyphSpacing) * scale
+	x := (img.Bounds().Dx() - width) / 2
+	for _, ch := range s {
+		glyph := pixelFont[ch]
+		for row, bits := range glyph {
+			for col := 0; col < glyphWidth; col++ {
+				if bits&(1<<(glyphWidth-1-col)) != 0 {
+					px, py := x+col*scale, y+row*scale
+					fillRect(img, image.Rect(px, py, px+scale, py+scale), c)
+				}
+			}
+		}
+		x += (glyphWidth + glyphSpacing) * scale
+	}
+}
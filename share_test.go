@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShareToken_RoundTrip(t *testing.T) {
+	card := ShareCard{QuizType: "tarot", Score: 4, Total: 5, When: time.Date(2026, 3, 14, 15, 9, 26, 0, time.UTC)}
+	token := newShareToken(card)
+
+	got, err := parseShareToken(token)
+	if err != nil {
+		t.Fatalf("parseShareToken failed: %v", err)
+	}
+	if got != card {
+		t.Errorf("parseShareToken() = %+v, want %+v", got, card)
+	}
+	if len(token) > 80 {
+		t.Errorf("token is %d characters, want a compact token", len(token))
+	}
+}
+
+func TestShareToken_Invalid(t *testing.T) {
+	valid := newShareToken(ShareCard{QuizType: "tarot", Score: 4, Total: 5, When: time.Unix(1700000000, 0)})
+	payload, sig, _ := strings.Cut(valid, ".")
+
+	// forge swaps in a new payload under the original signature
+	forge := func(p string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(p)) + "." + sig
+	}
+	// sign produces a validly signed token for an arbitrary payload
+	sign := func(p string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(p)) + "." + base64.RawURLEncoding.EncodeToString(signSharePayload(p))
+	}
+
+	// publicSign signs with the key that used to be committed to the repository
+	publicSign := func(p string) string {
+		mac := hmac.New(sha256.New, []byte("astrology-quiz-secret-key-change-in-production"))
+		mac.Write([]byte("share|" + p))
+		return base64.RawURLEncoding.EncodeToString([]byte(p)) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:shareSignatureSize])
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"signed with the old public key", publicSign("tarot|5|5|1700000000")},
+		{"no signature", payload},
+		{"bad base64", "!!!." + sig},
+		{"raised score", forge("tarot|5|5|1700000000")},
+		{"truncated signature", payload + "." + sig[:10]},
+		{"score above total", sign("tarot|6|5|1700000000")},
+		{"zero total", sign("tarot|0|0|1700000000")},
+		{"missing field", sign("tarot|4|5")},
+		{"non-numeric", sign("tarot|four|5|1700000000")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseShareToken(tt.token); err != errInvalidShareToken {
+				t.Errorf("parseShareToken(%q) error = %v, want %v", tt.token, err, errInvalidShareToken)
+			}
+		})
+	}
+}
+
+func TestShareHandlers(t *testing.T) {
+	resetThemes(t)
+	token := newShareToken(ShareCard{QuizType: "tarot", Score: 4, Total: 5, When: time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/share/{token}", sharePageHandler)
+	mux.HandleFunc("/share/{token}/card.svg", shareCardSVGHandler)
+	mux.HandleFunc("/share/{token}/card.png", shareCardPNGHandler)
+
+	tests := []struct {
+		name            string
+		method          string
+		path            string
+		wantStatus      int
+		wantContentType string
+		contains        []string
+	}{
+		{"page", http.MethodGet, "/share/" + token, http.StatusOK, "text/html; charset=utf-8", []string{
+			"Tarot Quiz result</title>", "4 / 5", "Played on 2026-03-14", `href="/quiz?type=tarot"`,
+			`property="og:title" content="Tarot Quiz result"`,
+			`property="og:description" content="Scored 4/5 (80%) on the Tarot Quiz."`,
+			`property="og:image" content="http://example.com/share/` + token + `/card.png"`,
+		}},
+		{"svg", http.MethodGet, "/share/" + token + "/card.svg", http.StatusOK, "image/svg+xml", []string{
+			"<svg", "Tarot Quiz", "4 / 5", "80% correct · 2026-03-14", `fill="#6a1b9a"`,
+		}},
+		{"png", http.MethodGet, "/share/" + token + "/card.png", http.StatusOK, "image/png", nil},
+		{"forged page", http.MethodGet, "/share/" + token + "x", http.StatusNotFound, "", nil},
+		{"forged image", http.MethodGet, "/share/x" + token + "/card.png", http.StatusNotFound, "", nil},
+		{"POST", http.MethodPost, "/share/" + token, http.StatusMethodNotAllowed, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantContentType != "" && w.Header().Get("Content-Type") != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", w.Header().Get("Content-Type"), tt.wantContentType)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("body missing %q", want)
+				}
+			}
+		})
+	}
+
+	// The PNG decodes at the Open Graph image size
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/share/"+token+"/card.png", nil))
+	img, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("card.png does not decode: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != shareCardWidth || b.Dy() != shareCardHeight {
+		t.Errorf("card.png is %dx%d, want %dx%d", b.Dx(), b.Dy(), shareCardWidth, shareCardHeight)
+	}
+}
+
+// TestResultsPage_ShareLink tests that the results page links to a share card for the verified result
+func TestResultsPage_ShareLink(t *testing.T) {
+	state := QuizState{QuestionIDs: []string{"q1", "q2"}, CurrentIndex: 2, Score: 1, QuizType: "astrology"}
+	token, _ := encodeStateToken(state)
+
+	w := httptest.NewRecorder()
+	quizResultsGetHandler(w, httptest.NewRequest(http.MethodGet, "/quiz/results?state="+url.QueryEscape(token), nil))
+
+	match := regexp.MustCompile(`href="/share/([A-Za-z0-9_.-]+)"`).FindStringSubmatch(w.Body.String())
+	if match == nil {
+		t.Fatal("results page has no share link")
+	}
+	card, err := parseShareToken(match[1])
+	if err != nil {
+		t.Fatalf("share link token invalid: %v", err)
+	}
+	if card.QuizType != "astrology" || card.Score != 1 || card.Total != 2 {
+		t.Errorf("share card = %+v, want astrology 1/2", card)
+	}
+}
+
+func TestParseHexColor(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected [4]uint8
+	}{
+		{"#6a1b9a", [4]uint8{0x6a, 0x1b, 0x9a, 0xff}},
+		{"#fff", [4]uint8{0xff, 0xff, 0xff, 0xff}},
+		{"#00000080", [4]uint8{0, 0, 0, 0x80}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			c := parseHexColor(tt.input)
+			if got := [4]uint8{c.R, c.G, c.B, c.A}; got != tt.expected {
+				t.Errorf("parseHexColor(%q) = %v, want %v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event stream timing
+const (
+	// sseHeartbeatInterval is how often an idle stream sends a comment to keep proxies from closing it
+	sseHeartbeatInterval = 15 * time.Second
+	// sseWriteTimeout bounds each write so a client that stops reading is disconnected
+	// instead of pinning its handler goroutine forever
+	sseWriteTimeout = 10 * time.Second
+)
+
+// sseEvent is a single Server-Sent Events message
+type sseEvent struct {
+	Name string
+	Data []byte
+}
+
+// broadcaster fans out events to any number of subscribers over buffered channels.
+// Publishing never blocks. When a subscriber's buffer is full, a coalescing broadcaster
+// discards that subscriber's oldest pending event (right for streams of full snapshots);
+// otherwise the subscriber is disconnected and its EventSource reconnects for a fresh start.
+type broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan sseEvent]struct{}
+	bufferSize  int
+	coalesce    bool
+}
+
+// newBroadcaster creates a broadcaster whose subscribers buffer up to bufferSize events
+func newBroadcaster(bufferSize int) *broadcaster {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	return &broadcaster{
+		subscribers: make(map[chan sseEvent]struct{}),
+		bufferSize:  bufferSize,
+	}
+}
+
+// newSnapshotBroadcaster creates a coalescing broadcaster for streams where each event
+// supersedes the previous one, so slow subscribers skip straight to the latest state
+func newSnapshotBroadcaster(bufferSize int) *broadcaster {
+	b := newBroadcaster(bufferSize)
+	b.coalesce = true
+	return b
+}
+
+// subscribe registers a new subscriber and returns its channel and an unsubscribe function
+func (b *broadcaster) subscribe() (<-chan sseEvent, func()) {
+	ch := make(chan sseEvent, b.bufferSize)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// publish delivers an event to every subscriber without blocking
+func (b *broadcaster) publish(ev sseEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- ev:
+			continue
+		default:
+		}
+
+		if b.coalesce {
+			// Make room by discarding the oldest pending snapshot; only publish sends,
+			// and we hold the lock, so the slot is still free when we send
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- ev
+			continue
+		}
+
+		// Slow subscriber: drop it so one stalled client can't hold up everyone else
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// subscriberCount returns the number of connected subscribers
+func (b *broadcaster) subscriberCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// closeAll disconnects every subscriber
+func (b *broadcaster) closeAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// serveSSE streams events to the client until it disconnects or the channel is closed.
+// Any initial events are written before waiting on the channel.
+func serveSSE(w http.ResponseWriter, r *http.Request, events <-chan sseEvent, initial ...sseEvent) {
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// Not every ResponseWriter supports deadlines (e.g. httptest.ResponseRecorder); streaming still works
+	extendDeadline := func() error {
+		err := rc.SetWriteDeadline(time.Now().Add(sseWriteTimeout))
+		if errors.Is(err, http.ErrNotSupported) {
+			return nil
+		}
+		return err
+	}
+
+	if err := extendDeadline(); err != nil {
+		return
+	}
+	for _, ev := range initial {
+		writeSSEEvent(w, ev)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-events:
+			if err := extendDeadline(); err != nil {
+				return
+			}
+			if !ok {
+				return
+			}
+			writeSSEEvent(w, ev)
+		case <-heartbeat.C:
+			if err := extendDeadline(); err != nil {
+				return
+			}
+			fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeSSEEvent writes one event in text/event-stream framing
+func writeSSEEvent(w http.ResponseWriter, ev sseEvent) {
+	if ev.Name != "" {
+		fmt.Fprintf(w, "event: %s\n", ev.Name)
+	}
+	for _, line := range strings.Split(string(ev.Data), "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// A state token is one opaque, URL-safe string carrying a QuizState: base64url (no padding)
+// of a version byte followed by a version-specific body.
+//
+//	version 1 (signed): payload JSON || first 16 bytes of HMAC-SHA256(version || payload)
+//	version 2 (sealed): 12-byte nonce || AES-256-GCM(payload JSON), with the version byte as additional data
+//
+// Both versions are always accepted, so switching encryption on or off never breaks quizzes in progress.
+const (
+	stateTokenSigned  byte = 1
+	stateTokenSealed  byte = 2
+	stateTokenMACSize      = 16
+)
+
+var (
+	// encryptStateTokens selects sealed tokens, which hide the question IDs and score from players
+	encryptStateTokens = true
+)
+
+// stateTokenPayload is the compact wire form of QuizState
+type stateTokenPayload struct {
+	QuizType     string   `json:"t"`
+	QuestionIDs  []string `json:"q"`
+	CurrentIndex int      `json:"i"`
+	Score        int      `json:"s"`
+	RunID        string   `json:"r,omitempty"`
+	IssuedAt     int64    `json:"at"` // Unix seconds; tokens expire after quizSessionLifetime
+
+	Practice       bool     `json:"p,omitempty"`
+	Missed         []string `json:"m,omitempty"`
+	LastQuestionID string   `json:"lq,omitempty"`
+	LastAnswer     string   `json:"la,omitempty"`
+	Study          bool     `json:"st,omitempty"`
+	AskedAt        int64    `json:"aa,omitempty"`
+	StartedAt      int64    `json:"sa,omitempty"`
+	FinishedAt     int64    `json:"fa,omitempty"`
+	EmbedOrigin    string   `json:"eo,omitempty"`
+}
+
+// newStateTokenAEAD returns the AES-256-GCM cipher that seals tokens under the given key
+func newStateTokenAEAD(key []byte) cipher.AEAD {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+// stateTokenMAC signs a version 1 token's version byte and payload
+func stateTokenMAC(version byte, payload []byte) []byte {
+	mac := hmac.New(sha256.New, keys.stateMAC)
+	fmt.Fprintf(mac, "state|%d|", version)
+	mac.Write(payload)
+	return mac.Sum(nil)[:stateTokenMACSize]
+}
+
+// encodeStateToken serializes and protects a quiz state as a single URL-safe token
+func encodeStateToken(state QuizState) (string, error) {
+	payload, err := json.Marshal(stateTokenPayload{
+		QuizType:     state.QuizType,
+		QuestionIDs:  state.QuestionIDs,
+		CurrentIndex: state.CurrentIndex,
+		Score:        state.Score,
+		RunID:        state.RunID,
+		IssuedAt:     time.Now().Unix(),
+
+		Practice:       state.Practice,
+		Missed:         state.Missed,
+		LastQuestionID: state.LastQuestionID,
+		LastAnswer:     state.LastAnswer,
+		Study:          state.Study,
+		AskedAt:        state.AskedAt,
+		StartedAt:      state.StartedAt,
+		FinishedAt:     state.FinishedAt,
+		EmbedOrigin:    state.EmbedOrigin,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	var token []byte
+	if encryptStateTokens {
+		nonce := make([]byte, keys.stateAEAD.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", fmt.Errorf("failed to generate nonce: %w", err)
+		}
+		token = append([]byte{stateTokenSealed}, nonce...)
+		token = keys.stateAEAD.Seal(token, nonce, payload, []byte{stateTokenSealed})
+	} else {
+		token = append([]byte{stateTokenSigned}, payload...)
+		token = append(token, stateTokenMAC(stateTokenSigned, payload)...)
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// decodeStateToken verifies a state token of any supported version and returns its state
+func decodeStateToken(token string) (*QuizState, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) < 1 {
+		return nil, false
+	}
+
+	switch version, body := raw[0], raw[1:]; version {
+	case stateTokenSigned:
+		if len(body) < stateTokenMACSize {
+			return nil, false
+		}
+		payload, mac := body[:len(body)-stateTokenMACSize], body[len(body)-stateTokenMACSize:]
+		if !hmac.Equal(mac, stateTokenMAC(version, payload)) {
+			return nil, false
+		}
+		return unmarshalStatePayload(payload)
+	case stateTokenSealed:
+		nonceSize := keys.stateAEAD.NonceSize()
+		if len(body) < nonceSize {
+			return nil, false
+		}
+		payload, err := keys.stateAEAD.Open(nil, body[:nonceSize], body[nonceSize:], []byte{version})
+		if err != nil {
+			return nil, false
+		}
+		return unmarshalStatePayload(payload)
+	default:
+		return nil, false
+	}
+}
+
+// unmarshalStatePayload decodes a verified token payload, rejecting expired tokens so an old
+// state cannot be replayed after the step ledger has forgotten its run
+func unmarshalStatePayload(payload []byte) (*QuizState, bool) {
+	var p stateTokenPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, false
+	}
+	if time.Since(time.Unix(p.IssuedAt, 0)) > quizSessionLifetime {
+		return nil, false
+	}
+	return &QuizState{
+		QuestionIDs:  p.QuestionIDs,
+		CurrentIndex: p.CurrentIndex,
+		Score:        p.Score,
+		QuizType:     p.QuizType,
+		RunID:        p.RunID,
+
+		Practice:       p.Practice,
+		Missed:         p.Missed,
+		LastQuestionID: p.LastQuestionID,
+		LastAnswer:     p.LastAnswer,
+		Study:          p.Study,
+		AskedAt:        p.AskedAt,
+		StartedAt:      p.StartedAt,
+		FinishedAt:     p.FinishedAt,
+		EmbedOrigin:    p.EmbedOrigin,
+	}, true
+}
+
+// quizStateFromRequest reads and verifies the quiz state carried by a form or query string as a
+// "state" token. The old quizState/signature pair is not accepted: it was signed with a key
+// published in the source, so runs in progress when the server was upgraded must start over.
+func quizStateFromRequest(r *http.Request) (*QuizState, bool) {
+	return decodeStateToken(r.FormValue("state"))
+}
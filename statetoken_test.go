@@ -0,0 +1,281 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withStateEncryption runs a test with state token encryption switched on or off
+func withStateEncryption(t *testing.T, encrypt bool) {
+	t.Helper()
+	original := encryptStateTokens
+	encryptStateTokens = encrypt
+	t.Cleanup(func() { encryptStateTokens = original })
+}
+
+func TestStateToken_RoundTrip(t *testing.T) {
+	state := QuizState{QuestionIDs: []string{"aries-ruler", "cancer-ruler", "houses-7"}, CurrentIndex: 2, Score: 1, QuizType: "astrology"}
+
+	for _, encrypt := range []bool{false, true} {
+		t.Run(map[bool]string{false: "signed", true: "sealed"}[encrypt], func(t *testing.T) {
+			withStateEncryption(t, encrypt)
+
+			token, err := encodeStateToken(state)
+			if err != nil {
+				t.Fatalf("encodeStateToken failed: %v", err)
+			}
+			if strings.ContainsAny(token, "+/=?&") {
+				t.Errorf("token %q is not URL-safe", token)
+			}
+
+			got, ok := decodeStateToken(token)
+			if !ok {
+				t.Fatal("decodeStateToken rejected a fresh token")
+			}
+			if !reflect.DeepEqual(*got, state) {
+				t.Errorf("decodeStateToken() = %+v, want %+v", *got, state)
+			}
+
+			// Sealed tokens hide the question IDs; signed ones only prevent tampering
+			if revealed := strings.Contains(string(mustDecode(t, token)), "aries-ruler"); revealed == encrypt {
+				t.Errorf("encrypt=%v but question IDs revealed=%v", encrypt, revealed)
+			}
+		})
+	}
+
+	// A token minted with one setting stays valid after the setting changes
+	withStateEncryption(t, true)
+	sealed, _ := encodeStateToken(state)
+	encryptStateTokens = false
+	if _, ok := decodeStateToken(sealed); !ok {
+		t.Error("sealed token should still verify with encryption switched off")
+	}
+}
+
+func TestStateToken_Compact(t *testing.T) {
+	withStateEncryption(t, true)
+	state := QuizState{QuestionIDs: []string{"q1", "q2", "q3"}, CurrentIndex: 1, Score: 1, QuizType: "astrology"}
+
+	token, _ := encodeStateToken(state)
+	stateJSON, _ := json.Marshal(state)
+	legacy := url.Values{"state": {string(stateJSON)}, "signature": {legacyStateSignature(stateJSON)}}.Encode()
+
+	if len("state="+token) >= len(legacy) {
+		t.Errorf("token query (%d bytes) should be shorter than the legacy pair (%d bytes)", len("state="+token), len(legacy))
+	}
+}
+
+func TestStateToken_Invalid(t *testing.T) {
+	state := QuizState{QuestionIDs: []string{"q1"}, Score: 0, QuizType: "astrology"}
+
+	withStateEncryption(t, false)
+	signed, _ := encodeStateToken(state)
+	encryptStateTokens = true
+	sealed, _ := encodeStateToken(state)
+
+	// flip changes one byte of a token's decoded form
+	flip := func(token string, index int) string {
+		raw, _ := base64.RawURLEncoding.DecodeString(token)
+		if index < 0 {
+			index += len(raw)
+		}
+		raw[index] ^= 0x01
+		return base64.RawURLEncoding.EncodeToString(raw)
+	}
+	// signedWithPayload builds a version 1 token whose payload was replaced but whose MAC was not
+	signedWithPayload := func(payload string) string {
+		raw, _ := base64.RawURLEncoding.DecodeString(signed)
+		mac := raw[len(raw)-stateTokenMACSize:]
+		return base64.RawURLEncoding.EncodeToString(append(append([]byte{stateTokenSigned}, payload...), mac...))
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"not base64", "not a token!"},
+		{"unknown version", base64.RawURLEncoding.EncodeToString([]byte{9, '{', '}'})},
+		{"signed payload tampered", flip(signed, 5)},
+		{"signed MAC tampered", flip(signed, -1)},
+		{"raised score", signedWithPayload(`{"t":"astrology","q":["q1"],"i":0,"s":100}`)},
+		{"signed too short", base64.RawURLEncoding.EncodeToString([]byte{stateTokenSigned, 1, 2})},
+		{"sealed ciphertext tampered", flip(sealed, -1)},
+		{"sealed nonce tampered", flip(sealed, 1)},
+		{"sealed relabelled as signed", base64.RawURLEncoding.EncodeToString(append([]byte{stateTokenSigned}, mustDecode(t, sealed)[1:]...))},
+		{"sealed too short", base64.RawURLEncoding.EncodeToString([]byte{stateTokenSealed, 1, 2})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if state, ok := decodeStateToken(tt.token); ok || state != nil {
+				t.Errorf("decodeStateToken(%q) = %+v, %v; want rejection", tt.token, state, ok)
+			}
+		})
+	}
+}
+
+func TestStateToken_Expiry(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		withStateEncryption(t, encrypt)
+		payload := []byte(`{"t":"astrology","q":["q1"],"i":0,"s":0,"r":"run","at":` + strconv.FormatInt(time.Now().Add(-quizSessionLifetime-time.Minute).Unix(), 10) + `}`)
+
+		var raw []byte
+		if encrypt {
+			nonce := make([]byte, keys.stateAEAD.NonceSize())
+			raw = keys.stateAEAD.Seal(append([]byte{stateTokenSealed}, nonce...), nonce, payload, []byte{stateTokenSealed})
+		} else {
+			raw = append(append([]byte{stateTokenSigned}, payload...), stateTokenMAC(stateTokenSigned, payload)...)
+		}
+		if _, ok := decodeStateToken(base64.RawURLEncoding.EncodeToString(raw)); ok {
+			t.Errorf("encrypt=%v: expired token should be rejected", encrypt)
+		}
+	}
+}
+
+// TestStateToken_PublicKeyForgery tests that tokens made with the key that used to be committed
+// to the repository are rejected, so players cannot re-seal their own state
+func TestStateToken_PublicKeyForgery(t *testing.T) {
+	const publicSecret = "astrology-quiz-secret-key-change-in-production"
+	payload, _ := json.Marshal(stateTokenPayload{QuizType: "tarot", QuestionIDs: []string{"q1", "q2"}, CurrentIndex: 2, Score: 2, IssuedAt: time.Now().Unix()})
+
+	key := sha256.Sum256([]byte("quiz-state-aead|" + publicSecret))
+	block, _ := aes.NewCipher(key[:])
+	aead, _ := cipher.NewGCM(block)
+	nonce := make([]byte, aead.NonceSize())
+	sealed := aead.Seal(append([]byte{stateTokenSealed}, nonce...), nonce, payload, []byte{stateTokenSealed})
+
+	mac := hmac.New(sha256.New, []byte(publicSecret))
+	mac.Write([]byte("state|1|"))
+	mac.Write(payload)
+	signed := append(append([]byte{stateTokenSigned}, payload...), mac.Sum(nil)[:stateTokenMACSize]...)
+
+	for name, raw := range map[string][]byte{"sealed": sealed, "signed": signed} {
+		if _, ok := decodeStateToken(base64.RawURLEncoding.EncodeToString(raw)); ok {
+			t.Errorf("%s token made with the public key was accepted", name)
+		}
+	}
+}
+
+// legacyStateSignature signs state JSON the way servers before state tokens did, with the
+// constant that was published in the source
+func legacyStateSignature(stateJSON []byte) string {
+	mac := hmac.New(sha256.New, []byte("astrology-quiz-secret-key-change-in-production"))
+	mac.Write(stateJSON)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestQuizStateFromRequest(t *testing.T) {
+	state := QuizState{QuestionIDs: []string{"q1", "q2"}, CurrentIndex: 2, Score: 2, QuizType: "tarot"}
+	stateJSON, _ := json.Marshal(state)
+	signature := legacyStateSignature(stateJSON)
+	token, _ := encodeStateToken(state)
+
+	tests := []struct {
+		name   string
+		method string
+		values url.Values
+		wantOK bool
+	}{
+		{"token in query", http.MethodGet, url.Values{"state": {token}}, true},
+		{"token in form", http.MethodPost, url.Values{"state": {token}}, true},
+		{"old results link", http.MethodGet, url.Values{"state": {string(stateJSON)}, "signature": {signature}}, false},
+		{"old form", http.MethodPost, url.Values{"quizState": {string(stateJSON)}, "signature": {signature}}, false},
+		{"raw JSON without signature", http.MethodGet, url.Values{"state": {string(stateJSON)}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req *http.Request
+			if tt.method == http.MethodGet {
+				req = httptest.NewRequest(http.MethodGet, "/quiz/results?"+tt.values.Encode(), nil)
+			} else {
+				req = httptest.NewRequest(http.MethodPost, "/quiz", strings.NewReader(tt.values.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+
+			got, ok := quizStateFromRequest(req)
+			if ok != tt.wantOK {
+				t.Fatalf("quizStateFromRequest() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && !reflect.DeepEqual(*got, state) {
+				t.Errorf("quizStateFromRequest() = %+v, want %+v", *got, state)
+			}
+		})
+	}
+}
+
+// TestQuizFlow_StateToken plays a quiz through the routes using only the tokens and cookies the pages hand out
+func TestQuizFlow_StateToken(t *testing.T) {
+	questionSets = map[string][]Question{
+		"astrology": {
+			{ID: "q1", Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+			{ID: "q2", Question: "Q2?", Choices: []string{"A", "B"}, AnswerIndex: 1},
+		},
+	}
+	tokenField := func(body string) string {
+		_, rest, _ := strings.Cut(body, `name="state" value="`)
+		token, _, _ := strings.Cut(rest, `"`)
+		return token
+	}
+
+	w := httptest.NewRecorder()
+	setupRoutes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/quiz?new=1", nil))
+	page := w
+
+	for i := 0; i < 2; i++ {
+		token := tokenField(page.Body.String())
+		state, ok := decodeStateToken(token)
+		if !ok {
+			t.Fatalf("step %d: page token %q does not verify", i, token)
+		}
+		question, _ := findQuestion(questionSets["astrology"], state.QuestionIDs[state.CurrentIndex])
+		form := url.Values{"state": {token}, "step": {strconv.Itoa(i)}, "answer": {strconv.Itoa(question.AnswerIndex)}}
+		req := httptest.NewRequest(http.MethodPost, "/quiz", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range page.Result().Cookies() {
+			req.AddCookie(c)
+		}
+		w = httptest.NewRecorder()
+		quizPostHandler(w, req)
+		if i == 0 {
+			page = followRedirect(t, w)
+		}
+	}
+
+	location, _ := url.Parse(w.Header().Get("Location"))
+	if w.Code != http.StatusSeeOther || location.Path != "/quiz/results" || location.Query().Has("signature") {
+		t.Fatalf("unexpected results redirect %d %q", w.Code, location)
+	}
+
+	results := followRedirect(t, w)
+	if !strings.Contains(results.Body.String(), "2 / 2") {
+		t.Fatal("results page should show a 2 / 2 score")
+	}
+	if final, ok := decodeStateToken(tokenField(results.Body.String())); !ok || final.Score != 2 {
+		t.Error("results page should carry a valid token for leaderboard submission")
+	}
+}
+
+// mustDecode decodes a base64url token or fails the test
+func mustDecode(t *testing.T, token string) []byte {
+	t.Helper()
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		t.Fatalf("token %q is not base64url: %v", token, err)
+	}
+	return raw
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StudyCard is one player's recall schedule for one question, maintained with the SM-2 algorithm
+type StudyCard struct {
+	QuizType     string    `json:"quiz_type"`
+	QuestionID   string    `json:"question_id"`
+	Repetitions  int       `json:"repetitions"`   // consecutive correct recalls
+	IntervalDays int       `json:"interval_days"` // days between the last review and Due
+	EaseFactor   float64   `json:"ease_factor"`
+	Due          time.Time `json:"due"`
+	LastReviewed time.Time `json:"last_reviewed"`
+}
+
+// StudyManager keeps every player's study cards with thread-safe access
+type StudyManager struct {
+	mu    sync.Mutex
+	cards map[string]map[string]*StudyCard // keyed by lower-cased username, then studyCardKey
+}
+
+// Constants for study mode configuration
+const (
+	studyFilename         = "study.json"
+	studySessionSize      = 10 // questions per study session
+	studyMasteredInterval = 21 // cards reviewed at least this many days apart count as mastered
+	studyInitialEase      = 2.5
+	studyMinimumEase      = 1.3
+
+	// SM-2 grades on a 0-5 scale; a multiple-choice answer is either recalled well or forgotten
+	studyQualityCorrect   = 4
+	studyQualityIncorrect = 1
+)
+
+// studyManager is the global study progress store
+var studyManager StudyManager
+
+// studyCardKey identifies a question across quiz types
+func studyCardKey(quizType, questionID string) string {
+	return quizType + "/" + questionID
+}
+
+// loadStudyProgress loads study cards from the JSON file; a missing file means nobody has studied yet
+func loadStudyProgress() error {
+	studyManager.mu.Lock()
+	defer studyManager.mu.Unlock()
+
+	studyManager.cards = make(map[string]map[string]*StudyCard)
+
+	data, err := os.ReadFile(studyFilename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read study file: %w", err)
+	}
+
+	var cards map[string]map[string]*StudyCard
+	if err := json.Unmarshal(data, &cards); err != nil {
+		return fmt.Errorf("failed to parse study JSON: %w", err)
+	}
+	for username, deck := range cards {
+		studyManager.cards[strings.ToLower(username)] = deck
+	}
+	return nil
+}
+
+// saveStudyProgressLocked persists every player's cards; the caller must hold studyManager.mu
+func saveStudyProgressLocked() error {
+	data, err := json.MarshalIndent(studyManager.cards, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal study progress: %w", err)
+	}
+	if err := os.WriteFile(studyFilename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write study file: %w", err)
+	}
+	return nil
+}
+
+// reviewStudyCard applies one SM-2 review with the given 0-5 quality to a card
+func reviewStudyCard(card *StudyCard, quality int, now time.Time) {
+	if card.EaseFactor == 0 {
+		card.EaseFactor = studyInitialEase
+	}
+
+	if quality >= 3 {
+		switch card.Repetitions {
+		case 0:
+			card.IntervalDays = 1
+		case 1:
+			card.IntervalDays = 6
+		default:
+			card.IntervalDays = int(math.Round(float64(card.IntervalDays) * card.EaseFactor))
+		}
+		card.Repetitions++
+	} else {
+		// Forgotten cards start over but keep their (lowered) ease
+		card.Repetitions = 0
+		card.IntervalDays = 1
+	}
+
+	miss := float64(5 - quality)
+	card.EaseFactor += 0.1 - miss*(0.08+miss*0.02)
+	if card.EaseFactor < studyMinimumEase {
+		card.EaseFactor = studyMinimumEase
+	}
+
+	card.LastReviewed = now
+	card.Due = now.AddDate(0, 0, card.IntervalDays)
+}
+
+// recordStudyAnswer reschedules a player's card for a question after they answered it
+func recordStudyAnswer(username, quizType, questionID string, correct bool, now time.Time) error {
+	studyManager.mu.Lock()
+	defer studyManager.mu.Unlock()
+
+	if studyManager.cards == nil {
+		studyManager.cards = make(map[string]map[string]*StudyCard)
+	}
+	user := strings.ToLower(username)
+	deck, ok := studyManager.cards[user]
+	if !ok {
+		deck = make(map[string]*StudyCard)
+		studyManager.cards[user] = deck
+	}
+	key := studyCardKey(quizType, questionID)
+	card, ok := deck[key]
+	if !ok {
+		card = &StudyCard{QuizType: quizType, QuestionID: questionID}
+		deck[key] = card
+	}
+
+	quality := studyQualityIncorrect
+	if correct {
+		quality = studyQualityCorrect
+	}
+	reviewStudyCard(card, quality, now)
+	return saveStudyProgressLocked()
+}
+
+// studyCards returns copies of a player's cards for one quiz type, keyed by question ID
+func studyCards(username, quizType string) map[string]StudyCard {
+	studyManager.mu.Lock()
+	defer studyManager.mu.Unlock()
+
+	cards := make(map[string]StudyCard)
+	for _, card := range studyManager.cards[strings.ToLower(username)] {
+		if card.QuizType == quizType {
+			cards[card.QuestionID] = *card
+		}
+	}
+	return cards
+}
+
+// selectStudyQuestionIDs picks up to n questions for a study session: due cards first, most
+// overdue first, then questions the player has never seen in bank order. Cards that are not
+// yet due are left alone.
+func selectStudyQuestionIDs(username, quizType string, questions []Question, n int, now time.Time) []string {
+	cards := studyCards(username, quizType)
+
+	var due []StudyCard
+	var unseen []string
+	for _, q := range questions {
+		card, seen := cards[q.ID]
+		if !seen {
+			unseen = append(unseen, q.ID)
+		} else if !card.Due.After(now) {
+			due = append(due, card)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].Due.Before(due[j].Due) })
+
+	ids := make([]string, 0, n)
+	for _, card := range due {
+		if len(ids) == n {
+			return ids
+		}
+		ids = append(ids, card.QuestionID)
+	}
+	for _, id := range unseen {
+		if len(ids) == n {
+			break
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// StudyDeckStats summarizes a player's progress on one quiz type for the study dashboard
+type StudyDeckStats struct {
+	QuizType string
+	Theme    Theme
+	Total    int
+	New      int // never studied
+	Learning int // studied but not yet mastered
+	Mastered int
+	Due      int    // studied cards due for review now
+	NextDue  string // when the next card comes due, if none are due now
+}
+
+// StudyPageData represents the data passed to the study.html template
+type StudyPageData struct {
+	Username string
+	Decks    []StudyDeckStats
+}
+
+// buildStudyDecks summarizes a player's cards for every loaded quiz type, sorted by type name.
+// Only questions still in the bank are counted.
+func buildStudyDecks(username, lang string, now time.Time) []StudyDeckStats {
+	var decks []StudyDeckStats
+	for quizType, questions := range questionSets {
+		if len(questions) == 0 {
+			continue
+		}
+		cards := studyCards(username, quizType)
+		deck := StudyDeckStats{QuizType: quizType, Theme: themeFor(quizType, lang), Total: len(questions)}
+		// Types without a theme borrow the default colors but not its copy
+		if _, ok := themes[quizType]; !ok {
+			deck.Theme.Title = quizType
+			deck.Theme.Intro = ""
+		}
+
+		var nextDue time.Time
+		for _, q := range questions {
+			card, seen := cards[q.ID]
+			switch {
+			case !seen:
+				deck.New++
+			case card.IntervalDays >= studyMasteredInterval:
+				deck.Mastered++
+			default:
+				deck.Learning++
+			}
+			if seen {
+				if !card.Due.After(now) {
+					deck.Due++
+				} else if nextDue.IsZero() || card.Due.Before(nextDue) {
+					nextDue = card.Due
+				}
+			}
+		}
+		if deck.Due == 0 && !nextDue.IsZero() {
+			deck.NextDue = nextDue.Format("Jan 02, 2006 15:04")
+		}
+		decks = append(decks, deck)
+	}
+
+	sort.Slice(decks, func(i, j int) bool { return decks[i].QuizType < decks[j].QuizType })
+	return decks
+}
+
+// studyHandler handles GET requests to /study, the logged-in player's study dashboard
+func studyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	account, ok := currentAccount(r)
+	if !ok {
+		http.Redirect(w, r, "/login?next=/study", http.StatusSeeOther)
+		return
+	}
+
+	lang := requestLanguage(w, r)
+	renderPage(w, "study", lang, StudyPageData{
+		Username: account.Username,
+		Decks:    buildStudyDecks(account.Username, lang, time.Now()),
+	})
+}
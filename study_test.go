@@ -0,0 +1,151 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupStudyTest isolates accounts, the leaderboard and study progress in a temporary directory
+func setupStudyTest(t *testing.T) {
+	t.Helper()
+	setupAccountsTest(t)
+	studyManager = StudyManager{cards: make(map[string]map[string]*StudyCard)}
+}
+
+func TestReviewStudyCard(t *testing.T) {
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		qualities    []int
+		wantInterval int
+		wantReps     int
+		wantEase     float64
+	}{
+		{"first recall", []int{studyQualityCorrect}, 1, 1, 2.5},
+		{"second recall", []int{studyQualityCorrect, studyQualityCorrect}, 6, 2, 2.5},
+		{"intervals grow by the ease", []int{studyQualityCorrect, studyQualityCorrect, studyQualityCorrect, studyQualityCorrect}, 38, 4, 2.5},
+		{"forgotten card starts over", []int{studyQualityCorrect, studyQualityCorrect, studyQualityIncorrect}, 1, 0, 1.96},
+		{"ease never drops below the minimum", []int{1, 1, 1, 1, 1}, 1, 0, studyMinimumEase},
+		{"perfect recall raises the ease", []int{5}, 1, 1, 2.6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := &StudyCard{}
+			for _, q := range tt.qualities {
+				reviewStudyCard(card, q, now)
+			}
+			if card.IntervalDays != tt.wantInterval || card.Repetitions != tt.wantReps {
+				t.Errorf("interval %d, repetitions %d; want %d, %d", card.IntervalDays, card.Repetitions, tt.wantInterval, tt.wantReps)
+			}
+			if diff := card.EaseFactor - tt.wantEase; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("ease %.4f, want %.4f", card.EaseFactor, tt.wantEase)
+			}
+			if want := now.AddDate(0, 0, tt.wantInterval); !card.Due.Equal(want) {
+				t.Errorf("due %v, want %v", card.Due, want)
+			}
+		})
+	}
+}
+
+func TestSelectStudyQuestionIDs(t *testing.T) {
+	setupStudyTest(t)
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	questions := []Question{{ID: "q1"}, {ID: "q2"}, {ID: "q3"}, {ID: "q4"}, {ID: "q5"}}
+	studyManager.cards["alice"] = map[string]*StudyCard{
+		"astrology/q1": {QuizType: "astrology", QuestionID: "q1", Due: now.Add(-24 * time.Hour)},
+		"astrology/q2": {QuizType: "astrology", QuestionID: "q2", Due: now.Add(-48 * time.Hour)},
+		"astrology/q3": {QuizType: "astrology", QuestionID: "q3", Due: now.Add(24 * time.Hour)},
+		"tarot/q4":     {QuizType: "tarot", QuestionID: "q4", Due: now.Add(24 * time.Hour)},
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		n        int
+		want     []string
+	}{
+		{"most overdue first, then unseen", "Alice", 3, []string{"q2", "q1", "q4"}},
+		{"session size caps due cards", "alice", 1, []string{"q2"}},
+		{"not-yet-due cards are skipped", "alice", 10, []string{"q2", "q1", "q4", "q5"}},
+		{"new player sees the bank in order", "bob", 2, []string{"q1", "q2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := selectStudyQuestionIDs(tt.username, "astrology", questions, tt.n, now)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectStudyQuestionIDs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStudyFlow(t *testing.T) {
+	setupStudyTest(t)
+	practiceQuestions()
+
+	// Guests are sent to log in first
+	w := newBrowser()(http.MethodGet, "/quiz?type=astrology&study=1", nil)
+	if location := w.Header().Get("Location"); w.Code != http.StatusSeeOther || !strings.HasPrefix(location, "/login?next=") {
+		t.Fatalf("guest study should redirect to login, got %d %q", w.Code, location)
+	}
+
+	send := newBrowser(loginCookie(t, "alice", "password123"))
+
+	// Study the whole (new) bank: the first answer is wrong, the rest right
+	body := send(http.MethodGet, "/quiz?type=astrology&study=1", nil).Body.String()
+	if !strings.Contains(body, "Question 1 of 3") || strings.Contains(body, `id="timer"`) {
+		t.Fatal("study session should be an untimed run of every new question")
+	}
+	for step, answer := range []string{"1", "0", "0"} {
+		w = send(http.MethodPost, "/quiz", url.Values{"step": {strconv.Itoa(step)}, "answer": {answer}})
+	}
+	results, _ := url.Parse(w.Header().Get("Location"))
+	if body := send(http.MethodGet, results.String(), nil).Body.String(); !strings.Contains(body, `href="/study"`) {
+		t.Error("study results should lead back to the dashboard")
+	}
+
+	cards := studyCards("alice", "astrology")
+	if len(cards) != 3 || cards["q1"].Repetitions != 0 || cards["q2"].Repetitions != 1 || cards["q3"].Repetitions != 1 {
+		t.Errorf("cards after the session = %+v", cards)
+	}
+	if err := loadStudyProgress(); err != nil || len(studyCards("alice", "astrology")) != 3 {
+		t.Errorf("study progress should persist, got %v", err)
+	}
+
+	// Study runs never reach the leaderboard
+	w = send(http.MethodPost, "/quiz/leaderboard", url.Values{"state": {results.Query().Get("state")}})
+	if entries := getLeaderboard(); w.Code != http.StatusBadRequest || len(entries) != 0 {
+		t.Errorf("study run submission = %d with entries %+v, want 400 and none", w.Code, entries)
+	}
+
+	// Nothing is due until tomorrow, so a new session goes back to the dashboard
+	w = send(http.MethodGet, "/quiz?type=astrology&study=1&new=1", nil)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/study" {
+		t.Errorf("study with nothing due should redirect to /study, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	body = send(http.MethodGet, "/study", nil).Body.String()
+	for _, want := range []string{"Study Dashboard", "<strong>0</strong>Due now", "<strong>3</strong>Learning", "All caught up! Next review:"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("dashboard should contain %q", want)
+		}
+	}
+}
+
+func TestStudyHandler_RequiresLogin(t *testing.T) {
+	setupStudyTest(t)
+
+	w := httptest.NewRecorder()
+	studyHandler(w, httptest.NewRequest(http.MethodGet, "/study", nil))
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login?next=/study" {
+		t.Errorf("guest dashboard = %d %q, want redirect to login", w.Code, w.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Page templates and the layout and partials they share, compiled into the binary
+//
+//go:embed templates/*.html
+var embeddedTemplates embed.FS
+
+// Shared template files parsed into every page
+const (
+	templateDir      = "templates"
+	layoutTemplate   = "layout.html"
+	partialsTemplate = "partials.html"
+)
+
+// TemplateRegistry holds one parsed template set per page and language, with the language
+// functions bound when the pages are loaded, so a render executes the cached set directly.
+// In reload mode every render re-parses the page from disk so UI edits show up without a restart.
+type TemplateRegistry struct {
+	fsys   fs.FS
+	reload bool
+	pages  map[string]map[string]*template.Template // page name, then language
+
+	// nonceSlot is what the nonce function writes; render swaps in the response's nonce. It is
+	// random, so page content cannot forge it.
+	nonceSlot string
+}
+
+// pageTemplates is the global template registry, parsed from the embedded files
+var pageTemplates = mustLoadTemplates(embeddedTemplates)
+
+// mustLoadTemplates parses the embedded templates; they ship with the binary, so a bad one is a build defect
+func mustLoadTemplates(fsys fs.FS) *TemplateRegistry {
+	sub, err := fs.Sub(fsys, templateDir)
+	if err != nil {
+		panic(err)
+	}
+	registry, err := newTemplateRegistry(sub, false)
+	if err != nil {
+		panic(err)
+	}
+	return registry
+}
+
+// newTemplateRegistry parses every page in fsys up front so template errors fail fast
+func newTemplateRegistry(fsys fs.FS, reload bool) (*TemplateRegistry, error) {
+	registry := &TemplateRegistry{fsys: fsys, reload: reload, nonceSlot: "nonce-" + randomToken(cspNonceBytes)}
+	pages, err := registry.parseAll()
+	if err != nil {
+		return nil, err
+	}
+	registry.pages = pages
+	return registry, nil
+}
+
+// baseTemplateFuncs are the functions every template may call. The language functions are
+// bound to the default language here and rebound for each language in localize.
+func (tr *TemplateRegistry) baseTemplateFuncs() template.FuncMap {
+	funcs := template.FuncMap{
+		"add": func(a, b int) int {
+			return a + b
+		},
+		"mul": func(a, b float64) float64 {
+			return a * b
+		},
+		"div": func(a, b float64) float64 {
+			if b == 0 {
+				return 0
+			}
+			return a / b
+		},
+		"toFloat": func(i int) float64 {
+			return float64(i)
+		},
+		// fill replaces a named placeholder such as {seconds}, for messages scripts also fill in
+		"fill": func(msg, name string, value any) string {
+			return strings.ReplaceAll(msg, "{"+name+"}", fmt.Sprint(value))
+		},
+		"nonce": func() string {
+			return tr.nonceSlot
+		},
+	}
+	for name, fn := range translationFuncs(defaultLanguage) {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// parseAll parses each page file together with the shared layout and partials, once per language
+func (tr *TemplateRegistry) parseAll() (map[string]map[string]*template.Template, error) {
+	files, err := fs.Glob(tr.fsys, "*.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+
+	pages := make(map[string]map[string]*template.Template)
+	for _, file := range files {
+		if file == layoutTemplate || file == partialsTemplate {
+			continue
+		}
+		name := strings.TrimSuffix(file, ".html")
+		tmpl, err := tr.parse(name)
+		if err != nil {
+			return nil, err
+		}
+		pages[name] = make(map[string]*template.Template)
+		for _, lang := range supportedLanguages() {
+			localized, err := tr.localize(name, tmpl, lang)
+			if err != nil {
+				return nil, err
+			}
+			pages[name][lang] = localized
+		}
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no page templates found")
+	}
+	return pages, nil
+}
+
+// parse builds the template set for one page
+func (tr *TemplateRegistry) parse(name string) (*template.Template, error) {
+	tmpl, err := template.New(name+".html").Funcs(tr.baseTemplateFuncs()).ParseFS(tr.fsys, layoutTemplate, partialsTemplate, name+".html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// localize returns a copy of a parsed page with the language functions bound to lang
+func (tr *TemplateRegistry) localize(name string, tmpl *template.Template, lang string) (*template.Template, error) {
+	localized, err := tmpl.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone %s template: %w", name, err)
+	}
+	return localized.Funcs(translationFuncs(lang)), nil
+}
+
+// lookup returns the page's template set for lang, re-parsing it from disk in reload mode.
+// Languages without a catalog get the default language's set.
+func (tr *TemplateRegistry) lookup(name, lang string) (*template.Template, error) {
+	if tr.reload {
+		tmpl, err := tr.parse(name)
+		if err != nil {
+			return nil, err
+		}
+		return tmpl.Funcs(translationFuncs(lang)), nil
+	}
+
+	localized, ok := tr.pages[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown template %q", name)
+	}
+	if tmpl, ok := localized[lang]; ok {
+		return tmpl, nil
+	}
+	return localized[defaultLanguage], nil
+}
+
+// render executes a page in the given language and writes it with the given status.
+// The page is rendered into a buffer first so a template error becomes a clean 500.
+func (tr *TemplateRegistry) render(w http.ResponseWriter, status int, name, lang string, data any) {
+	tmpl, err := tr.lookup(name, lang)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Error loading %s template: %v", name, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Error executing %s template: %v", name, err)
+		return
+	}
+
+	// Inline scripts and styles carry the nonce of this response's Content Security Policy
+	page := bytes.ReplaceAll(buf.Bytes(), []byte(tr.nonceSlot), []byte(cspNonce(w)))
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(page)
+}
+
+// renderPage renders a page from the global registry with status 200
+func renderPage(w http.ResponseWriter, name, lang string, data any) {
+	pageTemplates.render(w, http.StatusOK, name, lang, data)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// testLayout is a minimal layout for registry tests
+const testLayout = `{{define "layout"}}<html lang="{{lang}}"><title>{{template "title" .}}</title>{{template "content" .}}</html>{{end}}`
+
+func TestTemplateRegistry_EmbeddedPages(t *testing.T) {
+	for _, name := range []string{"home", "quiz", "results", "leaderboard", "account", "profile", "rooms", "room", "share", "study", "admin_questions", "admin_funnel", "embed"} {
+		for _, lang := range supportedLanguages() {
+			if tmpl, ok := pageTemplates.pages[name][lang]; !ok || tmpl == nil {
+				t.Errorf("page %s has no %s template", name, lang)
+			}
+		}
+		if _, err := pageTemplates.lookup(name, defaultLanguage); err != nil {
+			t.Errorf("page %s: %v", name, err)
+		}
+	}
+	if _, ok := pageTemplates.pages["layout"]; ok {
+		t.Error("layout should not be registered as a page")
+	}
+}
+
+func TestTemplateRegistry_FailsFast(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		errorMsg string
+	}{
+		{
+			name: "syntax error",
+			files: fstest.MapFS{
+				"layout.html":   {Data: []byte(testLayout)},
+				"partials.html": {Data: []byte(``)},
+				"broken.html":   {Data: []byte(`{{template "layout" .}}{{define "title"}}{{if}}{{end}}`)},
+			},
+			errorMsg: "failed to parse broken template",
+		},
+		{
+			name: "unknown function",
+			files: fstest.MapFS{
+				"layout.html":   {Data: []byte(testLayout)},
+				"partials.html": {Data: []byte(``)},
+				"page.html":     {Data: []byte(`{{template "layout" .}}{{define "title"}}{{nope}}{{end}}`)},
+			},
+			errorMsg: `function "nope" not defined`,
+		},
+		{
+			name: "missing layout",
+			files: fstest.MapFS{
+				"partials.html": {Data: []byte(``)},
+				"page.html":     {Data: []byte(`hello`)},
+			},
+			errorMsg: "failed to parse page template",
+		},
+		{
+			name:     "no pages",
+			files:    fstest.MapFS{"layout.html": {Data: []byte(testLayout)}, "partials.html": {Data: []byte(``)}},
+			errorMsg: "no page templates found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTemplateRegistry(tt.files, false)
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errorMsg, err)
+			}
+		})
+	}
+}
+
+func TestTemplateRegistry_Render(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	registry, err := newTemplateRegistry(fstest.MapFS{
+		"layout.html":   {Data: []byte(testLayout)},
+		"partials.html": {Data: []byte(`{{define "greeting"}}{{T "home.start"}}{{end}}`)},
+		"page.html":     {Data: []byte(`{{template "layout" .}}{{define "title"}}{{.}}{{end}}{{define "content"}}{{template "greeting"}}{{end}}`)},
+		"fails.html":    {Data: []byte(`{{template "layout" .}}{{define "title"}}{{.Missing}}{{end}}{{define "content"}}{{end}}`)},
+	}, false)
+	if err != nil {
+		t.Fatalf("newTemplateRegistry failed: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	registry.render(w, http.StatusCreated, "page", "es", "<Title>")
+	if w.Code != http.StatusCreated {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusCreated)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if want := `<html lang="es"><title>&lt;Title&gt;</title>Empezar quiz</html>`; w.Body.String() != want {
+		t.Errorf("body = %q, want %q", w.Body.String(), want)
+	}
+
+	// Execution errors become a clean 500 with no partial page
+	w = httptest.NewRecorder()
+	registry.render(w, http.StatusOK, "fails", "en", "not a struct")
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "<html") {
+		t.Errorf("expected a bare 500, got %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	registry.render(w, http.StatusOK, "missing", "en", nil)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("unknown page status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+}
+
+// TestTemplateRegistry_ConcurrentLanguages tests that per-request languages never leak between renders
+func TestTemplateRegistry_ConcurrentLanguages(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		lang, want := "en", "Choose a quiz"
+		if i%2 == 0 {
+			lang, want = "es", "Elige un quiz"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			renderPage(w, "home", lang, HomePageData{})
+			if !strings.Contains(w.Body.String(), want) {
+				t.Errorf("%s render missing %q", lang, want)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestTemplateRegistry_Nonce tests that each response carries its own nonce and never the stand-in
+func TestTemplateRegistry_Nonce(t *testing.T) {
+	for _, nonce := range []string{"abc123", ""} {
+		w := httptest.NewRecorder()
+		if nonce != "" {
+			w.Header().Set("Content-Security-Policy", "script-src 'nonce-"+nonce+"'")
+		}
+		renderPage(w, "home", defaultLanguage, HomePageData{})
+		body := w.Body.String()
+		if strings.Contains(body, pageTemplates.nonceSlot) {
+			t.Errorf("nonce %q: page still holds the stand-in", nonce)
+		}
+		if !strings.Contains(body, `<style nonce="`+nonce+`">`) {
+			t.Errorf("nonce %q: style tag does not carry it", nonce)
+		}
+	}
+}
+
+func TestTemplateRegistry_Reload(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("layout.html", testLayout)
+	write("partials.html", ``)
+	write("page.html", `{{template "layout" .}}{{define "title"}}v1{{end}}{{define "content"}}{{end}}`)
+
+	for _, tt := range []struct {
+		reload bool
+		want   string
+	}{
+		{false, "v1"},
+		{true, "v2"},
+	} {
+		write("page.html", `{{template "layout" .}}{{define "title"}}v1{{end}}{{define "content"}}{{end}}`)
+		registry, err := newTemplateRegistry(os.DirFS(dir), tt.reload)
+		if err != nil {
+			t.Fatalf("newTemplateRegistry failed: %v", err)
+		}
+
+		write("page.html", `{{template "layout" .}}{{define "title"}}v2{{end}}{{define "content"}}{{end}}`)
+		w := httptest.NewRecorder()
+		registry.render(w, http.StatusOK, "page", "en", nil)
+		if !strings.Contains(w.Body.String(), "<title>"+tt.want+"</title>") {
+			t.Errorf("reload=%v: body = %q, want title %s", tt.reload, w.Body.String(), tt.want)
+		}
+	}
+}
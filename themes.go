@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Theme configures the branding of one quiz type
+type Theme struct {
+	Title            string               `json:"title"`
+	Intro            string               `json:"intro"`
+	PrimaryColor     string               `json:"primary_color"`
+	PrimaryDarkColor string               `json:"primary_dark_color"` // hover and accent shade
+	BackgroundColor  string               `json:"background_color"`
+	Logo             string               `json:"logo,omitempty"`       // image URL, e.g. /themes/tarot/logo.svg
+	Stylesheet       string               `json:"stylesheet,omitempty"` // extra CSS URL loaded after the built-in styles
+	Localized        map[string]ThemeText `json:"localized,omitempty"`  // title and intro per language code
+}
+
+// ThemeText is the translatable copy of a theme
+type ThemeText struct {
+	Title string `json:"title,omitempty"`
+	Intro string `json:"intro,omitempty"`
+}
+
+// Theme configuration
+const (
+	defaultThemeName   = "astrology"
+	themesFilename     = "themes.json"
+	themeAssetsPrefix  = "/themes/"
+	defaultPrimary     = "#1976d2"
+	defaultPrimaryDark = "#1565c0"
+	defaultBackground  = "#ffffff"
+)
+
+// themeColorPattern accepts #rgb, #rrggbb and #rrggbbaa colors, which are safe to inline into CSS
+var themeColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+
+// themeAssetHostPattern accepts the host[:port] of an https:// theme asset, which is safe to list
+// as a Content-Security-Policy source
+var themeAssetHostPattern = regexp.MustCompile(`^[a-zA-Z0-9.-]+(:[0-9]+)?$`)
+
+// themes maps a quiz type to its theme; quiz types without one use the default theme
+var themes = builtinThemes()
+
+// builtinThemes returns the themes for the bundled quiz types
+func builtinThemes() map[string]Theme {
+	return map[string]Theme{
+		"astrology": {
+			Title:            "Astrology Quiz",
+			Intro:            "Test your knowledge of signs, planets and houses.",
+			PrimaryColor:     defaultPrimary,
+			PrimaryDarkColor: defaultPrimaryDark,
+			BackgroundColor:  defaultBackground,
+			Localized: map[string]ThemeText{
+				"es": {Title: "Quiz de Astrología", Intro: "Pon a prueba lo que sabes de signos, planetas y casas."},
+			},
+		},
+		"tarot": {
+			Title:            "Tarot Quiz",
+			Intro:            "How well do you know the Major and Minor Arcana?",
+			PrimaryColor:     "#6a1b9a",
+			PrimaryDarkColor: "#4a148c",
+			BackgroundColor:  "#fbf8fd",
+			Localized: map[string]ThemeText{
+				"es": {Title: "Quiz de Tarot", Intro: "¿Cuánto sabes de los Arcanos Mayores y Menores?"},
+			},
+		},
+	}
+}
+
+// themeFor returns the theme for a quiz type with its copy in lang.
+// Unknown quiz types use the default theme.
+func themeFor(quizType, lang string) Theme {
+	theme, ok := themes[quizType]
+	if !ok {
+		theme = themes[defaultThemeName]
+	}
+	if text, ok := theme.Localized[lang]; ok {
+		if text.Title != "" {
+			theme.Title = text.Title
+		}
+		if text.Intro != "" {
+			theme.Intro = text.Intro
+		}
+	}
+	return theme
+}
+
+// validateTheme checks a theme's colors and URLs before it is used in pages
+func validateTheme(name string, t Theme) error {
+	if strings.TrimSpace(t.Title) == "" {
+		return fmt.Errorf("theme %q has no title", name)
+	}
+	for field, color := range map[string]string{
+		"primary_color":      t.PrimaryColor,
+		"primary_dark_color": t.PrimaryDarkColor,
+		"background_color":   t.BackgroundColor,
+	} {
+		if !themeColorPattern.MatchString(color) {
+			return fmt.Errorf("theme %q has invalid %s %q (want #rgb, #rrggbb or #rrggbbaa)", name, field, color)
+		}
+	}
+	for field, rawURL := range map[string]string{"logo": t.Logo, "stylesheet": t.Stylesheet} {
+		if rawURL == "" || (strings.HasPrefix(rawURL, "/") && !strings.HasPrefix(rawURL, "//")) {
+			continue
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Scheme != "https" || u.User != nil || !themeAssetHostPattern.MatchString(u.Host) {
+			return fmt.Errorf("theme %q has invalid %s %q (want a /path or https:// URL)", name, field, rawURL)
+		}
+	}
+	return nil
+}
+
+// themeAssetOrigins returns the origins other than the site's own that theme logos and
+// stylesheets load from, for the Content-Security-Policy to allow
+func themeAssetOrigins() []string {
+	seen := make(map[string]bool)
+	var origins []string
+	for _, t := range themes {
+		for _, rawURL := range []string{t.Logo, t.Stylesheet} {
+			u, err := url.Parse(rawURL)
+			if err != nil || u.Scheme != "https" || u.Host == "" {
+				continue
+			}
+			origin := "https://" + strings.ToLower(u.Host)
+			if !seen[origin] {
+				seen[origin] = true
+				origins = append(origins, origin)
+			}
+		}
+	}
+	sort.Strings(origins)
+	return origins
+}
+
+// loadThemes reads dir/themes.json, a JSON object of quiz type to theme, and merges it over
+// the built-in themes. Fields left empty keep the built-in (or default theme) values.
+func loadThemes(dir string) error {
+	data, err := os.ReadFile(filepath.Join(dir, themesFilename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read themes file: %w", err)
+	}
+
+	var custom map[string]Theme
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return fmt.Errorf("failed to parse themes JSON: %w", err)
+	}
+
+	merged := builtinThemes()
+	names := make([]string, 0, len(custom))
+	for name := range custom {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		base, ok := merged[name]
+		if !ok {
+			base = merged[defaultThemeName]
+			base.Localized = nil
+		}
+		theme := mergeTheme(base, custom[name])
+		if err := validateTheme(name, theme); err != nil {
+			return err
+		}
+		merged[name] = theme
+	}
+
+	themes = merged
+	return nil
+}
+
+// mergeTheme overlays the non-empty fields of override onto base
+func mergeTheme(base, override Theme) Theme {
+	set := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	set(&base.Title, override.Title)
+	set(&base.Intro, override.Intro)
+	set(&base.PrimaryColor, override.PrimaryColor)
+	set(&base.PrimaryDarkColor, override.PrimaryDarkColor)
+	set(&base.BackgroundColor, override.BackgroundColor)
+	set(&base.Logo, override.Logo)
+	set(&base.Stylesheet, override.Stylesheet)
+	if len(override.Localized) > 0 {
+		localized := make(map[string]ThemeText, len(base.Localized)+len(override.Localized))
+		for lang, text := range base.Localized {
+			localized[lang] = text
+		}
+		for lang, text := range override.Localized {
+			localized[lang] = text
+		}
+		base.Localized = localized
+	}
+	return base
+}
+
+// themeAssetsHandler serves custom theme CSS, images and fonts from dir under /themes/.
+// Directory listings are disabled and themes.json itself is not served.
+func themeAssetsHandler(dir string) http.Handler {
+	files := http.StripPrefix(themeAssetsPrefix, http.FileServer(http.Dir(dir)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/") || filepath.Base(r.URL.Path) == themesFilename {
+			http.NotFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
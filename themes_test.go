@@ -0,0 +1,224 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// resetThemes restores the built-in themes after a test
+func resetThemes(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() { themes = builtinThemes() })
+}
+
+func TestThemeFor(t *testing.T) {
+	tests := []struct {
+		quizType  string
+		lang      string
+		wantTitle string
+		wantColor string
+	}{
+		{"astrology", "en", "Astrology Quiz", defaultPrimary},
+		{"tarot", "en", "Tarot Quiz", "#6a1b9a"},
+		{"tarot", "es", "Quiz de Tarot", "#6a1b9a"},
+		{"tarot", "fr", "Tarot Quiz", "#6a1b9a"},
+		{"numerology", "en", "Astrology Quiz", defaultPrimary},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.quizType+"/"+tt.lang, func(t *testing.T) {
+			theme := themeFor(tt.quizType, tt.lang)
+			if theme.Title != tt.wantTitle || theme.PrimaryColor != tt.wantColor {
+				t.Errorf("themeFor() = %q %s, want %q %s", theme.Title, theme.PrimaryColor, tt.wantTitle, tt.wantColor)
+			}
+		})
+	}
+
+	for name, theme := range builtinThemes() {
+		if err := validateTheme(name, theme); err != nil {
+			t.Errorf("built-in theme invalid: %v", err)
+		}
+	}
+}
+
+func TestLoadThemes(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		errorMsg string
+		check    func(t *testing.T)
+	}{
+		{
+			name:    "override and add themes",
+			content: `{"tarot": {"primary_color": "#000", "logo": "/themes/tarot/logo.svg"}, "numerology": {"title": "Numerology Quiz", "stylesheet": "/themes/numerology.css"}}`,
+			check: func(t *testing.T) {
+				tarot := themeFor("tarot", "es")
+				if tarot.PrimaryColor != "#000" || tarot.Logo != "/themes/tarot/logo.svg" || tarot.Title != "Quiz de Tarot" {
+					t.Errorf("tarot not merged over built-in: %+v", tarot)
+				}
+				numerology := themeFor("numerology", "es")
+				if numerology.Title != "Numerology Quiz" || numerology.PrimaryColor != defaultPrimary || numerology.Stylesheet != "/themes/numerology.css" {
+					t.Errorf("new theme should inherit default colors but not copy: %+v", numerology)
+				}
+			},
+		},
+		{
+			name:     "invalid color",
+			content:  `{"tarot": {"primary_color": "red; background: url(x)"}}`,
+			errorMsg: "invalid primary_color",
+		},
+		{
+			name:     "invalid stylesheet URL",
+			content:  `{"tarot": {"stylesheet": "javascript:alert(1)"}}`,
+			errorMsg: "invalid stylesheet",
+		},
+		{
+			name:    "remote assets",
+			content: `{"tarot": {"logo": "https://CDN.example/tarot.svg", "stylesheet": "https://fonts.example:8443/tarot.css"}, "astrology": {"logo": "https://cdn.example/astrology.svg"}}`,
+			check: func(t *testing.T) {
+				want := []string{"https://cdn.example", "https://fonts.example:8443"}
+				if got := themeAssetOrigins(); !slices.Equal(got, want) {
+					t.Errorf("themeAssetOrigins() = %v, want %v", got, want)
+				}
+			},
+		},
+		{
+			name:     "asset URL injecting a policy source",
+			content:  `{"tarot": {"logo": "https://cdn.example;script-src/logo.svg"}}`,
+			errorMsg: "invalid logo",
+		},
+		{
+			name:     "protocol-relative asset URL",
+			content:  `{"tarot": {"stylesheet": "//cdn.example/tarot.css"}}`,
+			errorMsg: "invalid stylesheet",
+		},
+		{
+			name:     "invalid JSON",
+			content:  `{"tarot": `,
+			errorMsg: "failed to parse themes JSON",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetThemes(t)
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, themesFilename), []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := loadThemes(dir)
+			if tt.errorMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+					t.Fatalf("expected error containing %q, got %v", tt.errorMsg, err)
+				}
+				if themeFor("tarot", "en").PrimaryColor != "#6a1b9a" {
+					t.Error("a failed load must leave the themes unchanged")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadThemes failed: %v", err)
+			}
+			tt.check(t)
+		})
+	}
+
+	// A directory without themes.json keeps the built-in themes
+	resetThemes(t)
+	if err := loadThemes(t.TempDir()); err != nil {
+		t.Errorf("missing themes.json should not be an error, got %v", err)
+	}
+}
+
+func TestThemeAssetsHandler(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, themesFilename), []byte(`{}`), 0644)
+	os.Mkdir(filepath.Join(dir, "tarot"), 0755)
+	os.WriteFile(filepath.Join(dir, "tarot", "theme.css"), []byte(`body { color: purple; }`), 0644)
+
+	mux := http.NewServeMux()
+	mux.Handle(themeAssetsPrefix, themeAssetsHandler(dir))
+
+	tests := []struct {
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{http.MethodGet, "/themes/tarot/theme.css", http.StatusOK, "color: purple"},
+		{http.MethodGet, "/themes/tarot/", http.StatusNotFound, ""},
+		{http.MethodGet, "/themes/themes.json", http.StatusNotFound, ""},
+		{http.MethodGet, "/themes/missing.css", http.StatusNotFound, ""},
+		{http.MethodPost, "/themes/tarot/theme.css", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+// TestThemedPages tests that quiz, results and leaderboard pages carry their quiz type's branding
+func TestThemedPages(t *testing.T) {
+	resetThemes(t)
+	themes["tarot"] = mergeTheme(themes["tarot"], Theme{Logo: "/themes/tarot/logo.svg", Stylesheet: "/themes/tarot/theme.css"})
+
+	questionSets = map[string][]Question{
+		"tarot": {{ID: "t1", Question: "Which card is numbered 0?", Choices: []string{"The Fool", "The Magician"}, AnswerIndex: 0}},
+	}
+	leaderboardManager = LeaderboardManager{entries: []LeaderboardEntry{}}
+
+	state := QuizState{QuestionIDs: []string{"t1"}, CurrentIndex: 1, Score: 1, QuizType: "tarot"}
+	token, _ := encodeStateToken(state)
+	resultsURL := "/quiz/results?state=" + url.QueryEscape(token)
+
+	tarotMarkers := []string{"<title>", "Tarot Quiz</title>", "--primary: #6a1b9a", `href="/themes/tarot/theme.css"`, `src="/themes/tarot/logo.svg"`}
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		url      string
+		contains []string
+		excludes []string
+	}{
+		{"quiz", quizGetHandler, "/quiz?type=tarot", append(tarotMarkers, "Major and Minor Arcana"), nil},
+		{"results", quizResultsGetHandler, resultsURL, append(tarotMarkers, `href="/quiz?type=tarot"`), nil},
+		{"leaderboard", leaderboardGetHandler, "/leaderboard?type=tarot", tarotMarkers, nil},
+		{"default leaderboard", leaderboardGetHandler, "/leaderboard?type=bogus", []string{"Astrology Quiz</title>", "--primary: #1976d2"}, []string{"theme-logo\" src"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			body := w.Body.String()
+			for _, want := range tt.contains {
+				if !strings.Contains(body, want) {
+					t.Errorf("body missing %q", want)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(body, unwanted) {
+					t.Errorf("body should not contain %q", unwanted)
+				}
+			}
+		})
+	}
+}